@@ -0,0 +1,117 @@
+// Command lot-rbac generates the RBAC manifests of a LOT operator without connecting to a cluster. The package of the
+// operator exports a constructor, which is called with operator.WithOfflineManager() and whose RBAC manifests are
+// written, see operator.WriteRBAC. The constructor has the signature
+//
+//	func(opts ...operator.ConstructorOption) (operator.Operator, error)
+//
+// or returns an operator.DynamicOperator.
+//
+// Usage:
+//
+//	lot-rbac [-o file] [-namespace namespace] [-func constructor] package
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// program calls the constructor of the operator and writes its RBAC manifests to stdout
+var program = template.Must(template.New("main.go").Parse(`package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/SchweizerischeBundesbahnen/lot/pkg/operator"
+	target "{{.Package}}"
+)
+
+func main() {
+	o, err := target.{{.Func}}(operator.WithOfflineManager())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	objs, err := o.RBAC()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if err := operator.WriteRBAC(os.Stdout, objs); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+`))
+
+func main() {
+	output := flag.String("o", "-", "file the manifests are written to, - for stdout")
+	namespace := flag.String("namespace", "", "namespace of the operator, e.g. for leader election or WithOwnNamespace()")
+	constructor := flag.String("func", "NewOperator", "constructor of the operator exported by the package")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [-o file] [-namespace namespace] [-func constructor] package\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if err := run(flag.Arg(0), *constructor, *namespace, *output); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// run generates a program calling the constructor of the package and writes its output
+func run(pkg, constructor, namespace, output string) error {
+	out, err := exec.Command("go", "list", "-f", "{{.ImportPath}} {{.Name}}", pkg).Output()
+	if err != nil {
+		return fmt.Errorf("cannot find the package %s: %w", pkg, err)
+	}
+	fields := strings.Fields(string(out))
+	if len(fields) != 2 {
+		return fmt.Errorf("cannot find the package %s", pkg)
+	}
+	if fields[1] == "main" {
+		return fmt.Errorf("the package %s is a main package, the operator has to be created by an exported constructor", pkg)
+	}
+
+	dir, err := os.MkdirTemp("", "lot-rbac")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	main := filepath.Join(dir, "main.go")
+	var src bytes.Buffer
+	if err := program.Execute(&src, struct{ Package, Func string }{fields[0], constructor}); err != nil {
+		return err
+	}
+	if err := os.WriteFile(main, src.Bytes(), 0o600); err != nil {
+		return err
+	}
+
+	var manifests bytes.Buffer
+	cmd := exec.Command("go", "run", main)
+	cmd.Stdout, cmd.Stderr = &manifests, os.Stderr
+	cmd.Env = os.Environ()
+	if namespace != "" {
+		cmd.Env = append(cmd.Env, "POD_NAMESPACE="+namespace)
+	}
+	if err := cmd.Run(); err != nil {
+		return err
+	}
+	if output == "-" {
+		_, err := os.Stdout.Write(manifests.Bytes())
+		return err
+	}
+	return os.WriteFile(output, manifests.Bytes(), 0o644)
+}
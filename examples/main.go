@@ -4,18 +4,19 @@ import (
 	"context"
 	"os"
 
+	"github.com/SchweizerischeBundesbahnen/lot/pkg/logging"
 	lot_client "github.com/SchweizerischeBundesbahnen/lot/pkg/lot-client"
 	"github.com/SchweizerischeBundesbahnen/lot/pkg/selector"
 
 	"github.com/SchweizerischeBundesbahnen/lot/pkg/operator"
 
+	"go.uber.org/zap/zapcore"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/event"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
-	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
 )
@@ -25,13 +26,10 @@ var (
 )
 
 func init() {
-	opts := zap.Options{
-		Development: true,
-	}
-	logf.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
+	// handlers get a logger identifying the object and the handler with logf.FromContext
+	logging.Setup(logging.WithConsole(), logging.WithLevel(zapcore.DebugLevel))
 }
 func main() {
-	// TODO: Decide if we want to pass around a dedicated logger for the reconciler and its handlers?
 	log := logf.Log.WithName("setup")
 	log.Info("Start...")
 
@@ -44,6 +42,8 @@ func main() {
 	//o, err := operator.NewUntyped("", "v1", "Secret")
 	o, err := operator.New(&v1.Secret{},
 		operator.WithManagerOptions(&mgrOpts),
+		// only list and watch the objects of the given namespaces, see also WithOwnNamespace()
+		operator.WithNamespaces("noexists"),
 		operator.WithOwns(&v1.ServiceAccount{}, _defaultPredicate),
 		operator.WithOwns(&v1.ConfigMap{}, _defaultPredicate))
 	if err != nil {
@@ -70,7 +70,7 @@ func main() {
 	}
 }
 
-var mgrOpts = manager.Options{Namespace: "noexists", MetricsBindAddress: ":9090"}
+var mgrOpts = manager.Options{MetricsBindAddress: ":9090"}
 
 var customPredicates = predicate.Funcs{
 	UpdateFunc: func(event event.UpdateEvent) bool {
@@ -0,0 +1,22 @@
+package defaults
+
+import (
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/cluster"
+)
+
+// InitCluster creates a cluster.Cluster with its own client and cache for a cluster reconciled in addition to the
+// cluster of the manager. Its RESTMapper discovers the API lazily, so that unreachable clusters do not prevent the
+// operator from being created.
+var InitCluster = func(config *rest.Config, options cluster.Options) (cluster.Cluster, error) {
+	if options.MapperProvider == nil {
+		options.MapperProvider = func(c *rest.Config) (meta.RESTMapper, error) {
+			return apiutil.NewDynamicRESTMapper(c, apiutil.WithLazyDiscovery)
+		}
+	}
+	return cluster.New(config, func(o *cluster.Options) {
+		*o = options
+	})
+}
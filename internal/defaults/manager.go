@@ -1,6 +1,9 @@
 package defaults
 
 import (
+	"k8s.io/apimachinery/pkg/api/meta"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
 	"sigs.k8s.io/controller-runtime/pkg/client/config"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 )
@@ -17,3 +20,21 @@ var InitManager = func(options *manager.Options) (manager.Manager, error) {
 
 	return m, nil
 }
+
+// InitOfflineManager creates a manager which never connects to a cluster, e.g. to compute the RBAC manifests of an
+// operator without a kubeconfig. Its RESTMapper only knows the built-in kinds, see OfflineRESTMapper, and it serves
+// no endpoints.
+var InitOfflineManager = func(options *manager.Options) (manager.Manager, error) {
+	opts := manager.Options{}
+	if options != nil {
+		opts = *options
+	}
+	if opts.Scheme == nil {
+		opts.Scheme = clientgoscheme.Scheme
+	}
+	opts.MetricsBindAddress, opts.HealthProbeBindAddress = "0", "0"
+	opts.MapperProvider = func(*rest.Config) (meta.RESTMapper, error) {
+		return OfflineRESTMapper(opts.Scheme), nil
+	}
+	return manager.New(&rest.Config{Host: "https://offline.invalid"}, opts)
+}
@@ -0,0 +1,27 @@
+package defaults
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// serviceAccountNamespaceFile contains the namespace of the service account mounted into a pod
+var serviceAccountNamespaceFile = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+
+// Namespace returns the namespace the operator is running in. It is taken from the POD_NAMESPACE
+// environment variable if set (e.g. via the downward API) and from the service account mounted
+// into the pod otherwise.
+var Namespace = func() (string, error) {
+	if ns, ok := os.LookupEnv("POD_NAMESPACE"); ok && ns != "" {
+		return ns, nil
+	}
+	data, err := os.ReadFile(serviceAccountNamespaceFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", fmt.Errorf("unable to detect namespace: not running in-cluster and POD_NAMESPACE is not set")
+		}
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
@@ -0,0 +1,28 @@
+package kinds
+
+import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// NewListFor returns an empty list for the kind of the given object. Typed lists are used for
+// kinds known to the scheme and unstructured lists for all others.
+func NewListFor(obj client.Object, scheme *runtime.Scheme) (client.ObjectList, error) {
+	gvk, err := apiutil.GVKForObject(obj, scheme)
+	if err != nil {
+		return nil, err
+	}
+	listGVK := gvk.GroupVersion().WithKind(gvk.Kind + "List")
+	if _, ok := obj.(*unstructured.Unstructured); ok || !scheme.Recognizes(listGVK) {
+		list := &unstructured.UnstructuredList{}
+		list.SetGroupVersionKind(listGVK)
+		return list, nil
+	}
+	list, err := scheme.New(listGVK)
+	if err != nil {
+		return nil, err
+	}
+	return list.(client.ObjectList), nil
+}
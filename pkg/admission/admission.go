@@ -0,0 +1,201 @@
+package admission
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	lot_client "github.com/SchweizerischeBundesbahnen/lot/pkg/lot-client"
+	"github.com/SchweizerischeBundesbahnen/lot/pkg/selector"
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	cradmission "sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// Validator decides whether a request to create, update or delete an object is admitted. Returning an error denies
+// the request with the error as reason. The object is the object to be stored, or the object to be deleted for delete
+// requests, while old is the stored object for update requests and nil otherwise. The complete request is available
+// with admission.RequestFromContext of the controller-runtime.
+type Validator func(ctx context.Context, object, old client.Object, cl lot_client.Client) error
+
+// Mutator changes the object of a create or update request before it is validated and stored. Returning an error
+// denies the request with the error as reason.
+type Mutator func(ctx context.Context, object client.Object, cl lot_client.Client) error
+
+// NamedValidator is a Validator registered under a unique name, which is only run for objects matching its Selector.
+// A nil Selector matches all objects.
+type NamedValidator struct {
+	Name      string
+	Validator Validator
+	Selector  selector.Selector
+}
+
+// NamedMutator is a Mutator registered under a unique name, which is only run for objects matching its Selector.
+// A nil Selector matches all objects.
+type NamedMutator struct {
+	Name     string
+	Mutator  Mutator
+	Selector selector.Selector
+}
+
+// ValidatePath returns the path of the validating webhook for objects of the given kind, e.g. "/validate--v1-secret"
+// for Secrets or "/validate-apps-v1-deployment" for Deployments, like the paths generated by kubebuilder
+func ValidatePath(gvk schema.GroupVersionKind) string {
+	return path("validate", gvk)
+}
+
+// MutatePath returns the path of the mutating webhook for objects of the given kind, e.g. "/mutate--v1-secret"
+func MutatePath(gvk schema.GroupVersionKind) string {
+	return path("mutate", gvk)
+}
+
+func path(prefix string, gvk schema.GroupVersionKind) string {
+	return "/" + prefix + "-" + strings.ReplaceAll(gvk.Group, ".", "-") + "-" + gvk.Version + "-" + strings.ToLower(gvk.Kind)
+}
+
+var _ cradmission.Handler = &validatingHandler{}
+
+// validatingHandler runs the matching validators and denies a request if any of them fails
+type validatingHandler struct {
+	decoder    *decoder
+	client     lot_client.Client
+	validators []NamedValidator
+}
+
+// NewValidatingHandler returns an admission.Handler running the validators for requests on objects of the type of obj
+func NewValidatingHandler(obj client.Object, cl lot_client.Client, validators ...NamedValidator) (cradmission.Handler, error) {
+	d, err := newDecoder(obj, cl)
+	if err != nil {
+		return nil, err
+	}
+	return &validatingHandler{decoder: d, client: cl, validators: validators}, nil
+}
+
+func (h *validatingHandler) Handle(ctx context.Context, req cradmission.Request) cradmission.Response {
+	ctx = cradmission.NewContextWithRequest(ctx, req)
+	obj, old, err := h.decoder.decode(req)
+	if err != nil {
+		return cradmission.Errored(http.StatusBadRequest, err)
+	}
+
+	var denied error
+	for _, v := range h.validators {
+		// updates are validated if either the old or the new object matches, like they are reconciled
+		if v.Validator == nil || !(selector.MatchesObject(v.Selector, obj) || old != nil && selector.MatchesObject(v.Selector, old)) {
+			continue
+		}
+		if err := v.Validator(ctx, obj, old, h.client); err != nil {
+			denied = errors.Join(denied, err)
+		}
+	}
+	if denied != nil {
+		return deny(denied)
+	}
+	return cradmission.Allowed("")
+}
+
+var _ cradmission.Handler = &mutatingHandler{}
+
+// mutatingHandler runs the matching mutators one after the other and patches the object with their changes
+type mutatingHandler struct {
+	decoder  *decoder
+	client   lot_client.Client
+	mutators []NamedMutator
+}
+
+// NewMutatingHandler returns an admission.Handler running the mutators for requests on objects of the type of obj
+func NewMutatingHandler(obj client.Object, cl lot_client.Client, mutators ...NamedMutator) (cradmission.Handler, error) {
+	d, err := newDecoder(obj, cl)
+	if err != nil {
+		return nil, err
+	}
+	return &mutatingHandler{decoder: d, client: cl, mutators: mutators}, nil
+}
+
+func (h *mutatingHandler) Handle(ctx context.Context, req cradmission.Request) cradmission.Response {
+	if req.Operation != admissionv1.Create && req.Operation != admissionv1.Update {
+		return cradmission.Allowed("")
+	}
+	ctx = cradmission.NewContextWithRequest(ctx, req)
+	obj, _, err := h.decoder.decode(req)
+	if err != nil {
+		return cradmission.Errored(http.StatusBadRequest, err)
+	}
+
+	for _, m := range h.mutators {
+		if m.Mutator == nil || !selector.MatchesObject(m.Selector, obj) {
+			continue
+		}
+		if err := m.Mutator(ctx, obj, h.client); err != nil {
+			return deny(err)
+		}
+	}
+	mutated, err := json.Marshal(obj)
+	if err != nil {
+		return cradmission.Errored(http.StatusInternalServerError, err)
+	}
+	return cradmission.PatchResponseFromRaw(req.Object.Raw, mutated)
+}
+
+// deny returns a response denying the request with the error as message, which is shown to the client
+func deny(err error) cradmission.Response {
+	resp := cradmission.Denied(err.Error())
+	resp.Result.Message = err.Error()
+	return resp
+}
+
+// decoder decodes the objects of admission requests into new objects of the type of the webhook's object
+type decoder struct {
+	*cradmission.Decoder
+	object client.Object
+	gvk    schema.GroupVersionKind
+}
+
+func newDecoder(obj client.Object, cl lot_client.Client) (*decoder, error) {
+	gvk, err := apiutil.GVKForObject(obj, cl.Scheme())
+	if err != nil {
+		return nil, err
+	}
+	d, err := cradmission.NewDecoder(cl.Scheme())
+	if err != nil {
+		return nil, err
+	}
+	return &decoder{Decoder: d, object: obj, gvk: gvk}, nil
+}
+
+// decode returns the object of the request, which is the object to be deleted for delete requests,
+// and the stored object for update requests
+func (d *decoder) decode(req cradmission.Request) (client.Object, client.Object, error) {
+	if req.Kind.Group != d.gvk.Group || req.Kind.Kind != d.gvk.Kind {
+		return nil, nil, fmt.Errorf("expected a request for %s, got %s", d.gvk.GroupKind(), schema.GroupKind{Group: req.Kind.Group, Kind: req.Kind.Kind})
+	}
+	switch req.Operation {
+	case admissionv1.Delete:
+		obj, err := d.decodeRaw(req.OldObject)
+		return obj, nil, err
+	case admissionv1.Update:
+		obj, err := d.decodeRaw(req.Object)
+		if err != nil {
+			return nil, nil, err
+		}
+		old, err := d.decodeRaw(req.OldObject)
+		return obj, old, err
+	default:
+		obj, err := d.decodeRaw(req.Object)
+		return obj, nil, err
+	}
+}
+
+func (d *decoder) decodeRaw(raw runtime.RawExtension) (client.Object, error) {
+	obj := d.object.DeepCopyObject().(client.Object)
+	if err := d.DecodeRaw(raw, obj); err != nil {
+		return nil, err
+	}
+	return obj, nil
+}
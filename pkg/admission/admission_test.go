@@ -0,0 +1,148 @@
+package admission_test
+
+import (
+	"context"
+	"errors"
+
+	"github.com/SchweizerischeBundesbahnen/lot/pkg/admission"
+	lot_client "github.com/SchweizerischeBundesbahnen/lot/pkg/lot-client"
+	"github.com/SchweizerischeBundesbahnen/lot/pkg/lottest"
+	"github.com/SchweizerischeBundesbahnen/lot/pkg/selector"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	admissionv1 "k8s.io/api/admission/v1"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	cradmission "sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+var _ = Describe("admission", func() {
+	var env *lottest.Environment
+	var secret *v1.Secret
+	var sel selector.Selector
+	BeforeEach(func() {
+		env = lottest.New(nil)
+		secret = &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "biz", Name: "baz", Labels: map[string]string{"foo": "bar"}}}
+		var err error
+		sel, err = selector.NewSelector(map[string]string{"foo": "bar"}, map[string]string{})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("should derive the paths from the kind", func() {
+		Expect(admission.ValidatePath(v1.SchemeGroupVersion.WithKind("Secret"))).To(Equal("/validate--v1-secret"))
+		Expect(admission.MutatePath(appsv1.SchemeGroupVersion.WithKind("Deployment"))).To(Equal("/mutate-apps-v1-deployment"))
+		Expect(admission.ValidatePath(schema.GroupVersionKind{Group: "rbac.authorization.k8s.io", Version: "v1", Kind: "Role"})).
+			To(Equal("/validate-rbac-authorization-k8s-io-v1-role"))
+	})
+
+	Describe("when validating", func() {
+		var validated []string
+		validator := func(name string, err error) admission.NamedValidator {
+			return admission.NamedValidator{Name: name, Selector: sel, Validator: func(ctx context.Context, object, old client.Object, cl lot_client.Client) error {
+				req, reqErr := cradmission.RequestFromContext(ctx)
+				Expect(reqErr).NotTo(HaveOccurred())
+				validated = append(validated, name+"/"+string(req.Operation))
+				return err
+			}}
+		}
+		BeforeEach(func() {
+			validated = nil
+		})
+		It("should deny requests if any matching validator fails", func() {
+			h, err := admission.NewValidatingHandler(&v1.Secret{}, env.Client(), validator("first", errors.New("missing annotation")), validator("second", nil))
+			Expect(err).NotTo(HaveOccurred())
+
+			req, err := env.AdmissionRequest(admissionv1.Create, secret, nil)
+			Expect(err).NotTo(HaveOccurred())
+			resp := h.Handle(context.Background(), req)
+			Expect(resp.Allowed).To(BeFalse())
+			Expect(resp.Result.Message).To(ContainSubstring("missing annotation"))
+			Expect(validated).To(Equal([]string{"first/CREATE", "second/CREATE"}))
+		})
+		It("should allow requests for objects no validator matches", func() {
+			h, err := admission.NewValidatingHandler(&v1.Secret{}, env.Client(), validator("first", errors.New("missing annotation")))
+			Expect(err).NotTo(HaveOccurred())
+
+			secret.Labels = nil
+			req, err := env.AdmissionRequest(admissionv1.Create, secret, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(h.Handle(context.Background(), req).Allowed).To(BeTrue())
+			Expect(validated).To(BeEmpty())
+		})
+		It("should pass the old object of updates and the deleted object of deletes", func() {
+			var objects []client.Object
+			h, err := admission.NewValidatingHandler(&v1.Secret{}, env.Client(), admission.NamedValidator{Name: "first",
+				Validator: func(ctx context.Context, object, old client.Object, cl lot_client.Client) error {
+					objects = append(objects, object, old)
+					return nil
+				}})
+			Expect(err).NotTo(HaveOccurred())
+
+			updated := secret.DeepCopy()
+			updated.Labels = nil
+			req, err := env.AdmissionRequest(admissionv1.Update, updated, secret)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(h.Handle(context.Background(), req).Allowed).To(BeTrue())
+			req, err = env.AdmissionRequest(admissionv1.Delete, secret, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(h.Handle(context.Background(), req).Allowed).To(BeTrue())
+
+			Expect(objects).To(HaveLen(4))
+			Expect(objects[0].GetLabels()).To(BeEmpty())
+			Expect(objects[1].GetLabels()).To(HaveKey("foo"))
+			Expect(objects[2].GetName()).To(Equal("baz"))
+			Expect(objects[3]).To(BeNil())
+		})
+		It("should reject requests for other kinds", func() {
+			h, err := admission.NewValidatingHandler(&v1.ConfigMap{}, env.Client(), validator("first", nil))
+			Expect(err).NotTo(HaveOccurred())
+
+			req, err := env.AdmissionRequest(admissionv1.Create, secret, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(h.Handle(context.Background(), req).Allowed).To(BeFalse())
+			Expect(validated).To(BeEmpty())
+		})
+	})
+
+	Describe("when mutating", func() {
+		It("should patch the object with the changes of the matching mutators", func() {
+			h, err := admission.NewMutatingHandler(&v1.Secret{}, env.Client(),
+				admission.NamedMutator{Name: "first", Selector: sel, Mutator: func(ctx context.Context, object client.Object, cl lot_client.Client) error {
+					object.SetAnnotations(map[string]string{"defaulted": "true"})
+					return nil
+				}},
+				admission.NamedMutator{Name: "second", Mutator: func(ctx context.Context, object client.Object, cl lot_client.Client) error {
+					object.(*v1.Secret).StringData = map[string]string{"key": object.GetAnnotations()["defaulted"]}
+					return nil
+				}})
+			Expect(err).NotTo(HaveOccurred())
+
+			req, err := env.AdmissionRequest(admissionv1.Create, secret, nil)
+			Expect(err).NotTo(HaveOccurred())
+			resp := h.Handle(context.Background(), req)
+			Expect(resp.Allowed).To(BeTrue())
+
+			mutated := &v1.Secret{}
+			Expect(lottest.Mutated(req, resp, mutated)).To(Succeed())
+			Expect(mutated.Annotations).To(HaveKeyWithValue("defaulted", "true"))
+			Expect(mutated.StringData).To(HaveKeyWithValue("key", "true"))
+			Expect(mutated.Kind).To(Equal("Secret"))
+		})
+		It("should deny requests if a mutator fails", func() {
+			h, err := admission.NewMutatingHandler(&v1.Secret{}, env.Client(),
+				admission.NamedMutator{Name: "first", Mutator: func(ctx context.Context, object client.Object, cl lot_client.Client) error {
+					return errors.New("failure")
+				}})
+			Expect(err).NotTo(HaveOccurred())
+
+			req, err := env.AdmissionRequest(admissionv1.Create, secret, nil)
+			Expect(err).NotTo(HaveOccurred())
+			resp := h.Handle(context.Background(), req)
+			Expect(resp.Allowed).To(BeFalse())
+			Expect(resp.Result.Message).To(ContainSubstring("failure"))
+		})
+	})
+})
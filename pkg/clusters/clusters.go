@@ -0,0 +1,79 @@
+// Package clusters loads the clusters an operator reconciles in addition to the cluster of its manager,
+// see operator.WithClusters
+package clusters
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// KubeconfigKey is the key of the kubeconfig in the Secrets read by FromSecrets
+const KubeconfigKey = "kubeconfig"
+
+// Set maps the identifiers of clusters to the configs used to connect to them
+type Set map[string]*rest.Config
+
+// FromKubeconfigDir loads a cluster from every kubeconfig in dir, identified by the name of the file without its
+// extension, e.g. "prod" for "prod.yaml". Directories and hidden files are skipped, so that a mounted Secret or
+// ConfigMap can be used as the directory.
+func FromKubeconfigDir(dir string) (Set, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	set := Set{}
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		id := strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name()))
+		if err := set.add(id, data); err != nil {
+			return nil, fmt.Errorf("invalid kubeconfig %q: %w", entry.Name(), err)
+		}
+	}
+	return set, nil
+}
+
+// FromSecrets loads a cluster from every Secret in the namespace matching the labels, identified by the name of the
+// Secret. The kubeconfig is read from the key "kubeconfig", Secrets without it are skipped.
+func FromSecrets(ctx context.Context, reader client.Reader, namespace string, labels map[string]string) (Set, error) {
+	secrets := &corev1.SecretList{}
+	if err := reader.List(ctx, secrets, client.InNamespace(namespace), client.MatchingLabels(labels)); err != nil {
+		return nil, err
+	}
+	set := Set{}
+	for _, secret := range secrets.Items {
+		data, ok := secret.Data[KubeconfigKey]
+		if !ok {
+			continue
+		}
+		if err := set.add(secret.Name, data); err != nil {
+			return nil, fmt.Errorf("invalid kubeconfig in Secret %s/%s: %w", secret.Namespace, secret.Name, err)
+		}
+	}
+	return set, nil
+}
+
+func (s Set) add(id string, kubeconfig []byte) error {
+	if _, ok := s[id]; ok {
+		return fmt.Errorf("duplicate cluster %q", id)
+	}
+	config, err := clientcmd.RESTConfigFromKubeConfig(kubeconfig)
+	if err != nil {
+		return err
+	}
+	s[id] = config
+	return nil
+}
@@ -0,0 +1,83 @@
+package clusters_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	"github.com/SchweizerischeBundesbahnen/lot/pkg/clusters"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func kubeconfig(server string) []byte {
+	return []byte(`apiVersion: v1
+kind: Config
+clusters:
+- name: cluster
+  cluster:
+    server: ` + server + `
+contexts:
+- name: context
+  context:
+    cluster: cluster
+    user: user
+current-context: context
+users:
+- name: user
+  user:
+    token: secret
+`)
+}
+
+var _ = Describe("clusters", func() {
+	Describe("FromKubeconfigDir", func() {
+		It("should load a cluster from every kubeconfig", func() {
+			dir := GinkgoT().TempDir()
+			Expect(os.WriteFile(filepath.Join(dir, "prod.yaml"), kubeconfig("https://prod:6443"), 0o600)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(dir, "test"), kubeconfig("https://test:6443"), 0o600)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(dir, ".hidden"), []byte("no kubeconfig"), 0o600)).To(Succeed())
+			Expect(os.Mkdir(filepath.Join(dir, "..data"), 0o700)).To(Succeed())
+
+			set, err := clusters.FromKubeconfigDir(dir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(set).To(HaveLen(2))
+			Expect(set["prod"].Host).To(Equal("https://prod:6443"))
+			Expect(set["test"].Host).To(Equal("https://test:6443"))
+		})
+		It("should reject invalid kubeconfigs", func() {
+			dir := GinkgoT().TempDir()
+			Expect(os.WriteFile(filepath.Join(dir, "prod.yaml"), []byte("clusters: {"), 0o600)).To(Succeed())
+			_, err := clusters.FromKubeconfigDir(dir)
+			Expect(err).To(MatchError(ContainSubstring("prod.yaml")))
+		})
+		It("should reject duplicate identifiers", func() {
+			dir := GinkgoT().TempDir()
+			Expect(os.WriteFile(filepath.Join(dir, "prod.yaml"), kubeconfig("https://prod:6443"), 0o600)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(dir, "prod.yml"), kubeconfig("https://prod:6443"), 0o600)).To(Succeed())
+			_, err := clusters.FromKubeconfigDir(dir)
+			Expect(err).To(MatchError(ContainSubstring(`duplicate cluster "prod"`)))
+		})
+	})
+	Describe("FromSecrets", func() {
+		It("should load a cluster from every matching Secret", func() {
+			secret := func(name string, labels map[string]string, data map[string][]byte) *corev1.Secret {
+				return &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "clusters", Name: name, Labels: labels}, Data: data}
+			}
+			cl := fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(
+				secret("prod", map[string]string{"cluster": "true"}, map[string][]byte{clusters.KubeconfigKey: kubeconfig("https://prod:6443")}),
+				secret("other", nil, map[string][]byte{clusters.KubeconfigKey: kubeconfig("https://other:6443")}),
+				secret("empty", map[string]string{"cluster": "true"}, nil),
+			).Build()
+
+			set, err := clusters.FromSecrets(context.Background(), cl, "clusters", map[string]string{"cluster": "true"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(set).To(HaveLen(1))
+			Expect(set["prod"].Host).To(Equal("https://prod:6443"))
+		})
+	})
+})
@@ -0,0 +1,91 @@
+// Package config loads the configuration shared by most operators, i.e. the addresses of the metrics and health
+// endpoints, the namespaces, leader election, dry run, the log level and format and the labels and annotations
+// selecting the objects to handle, and turns it into the options of an Operator and its logger.
+//
+// The configuration is read from a YAML file, environment variables and command line flags, each of them overriding
+// the former:
+//
+//  1. the defaults returned by Defaults
+//  2. the YAML file given with the flag --config or the environment variable LOT_CONFIG
+//  3. the environment variables, e.g. LOT_METRICS_BIND_ADDRESS, see the constants starting with Env
+//  4. the flags registered with Loader.AddFlags which have been set, e.g. --metrics-bind-address
+//
+// Lists, i.e. namespaces, are given as comma-separated values in environment variables, and labels and annotations as
+// comma-separated key=value pairs. The selector returned by Loader.Selector is reloaded by Loader.Watch when the file
+// changes, all other settings are only read once.
+package config
+
+import (
+	"fmt"
+
+	"github.com/SchweizerischeBundesbahnen/lot/pkg/logging"
+	"github.com/SchweizerischeBundesbahnen/lot/pkg/operator"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+// Config is the configuration of an operator. Its fields are named like the keys of the YAML file.
+type Config struct {
+	// MetricsBindAddress is the address the metrics are served on, "0" disables them
+	MetricsBindAddress string `json:"metricsBindAddress,omitempty"`
+	// HealthProbeBindAddress is the address the health probes are served on, "0" disables them
+	HealthProbeBindAddress string `json:"healthProbeBindAddress,omitempty"`
+	// Namespaces restricts the operator to these namespaces, see operator.WithNamespaces
+	Namespaces []string `json:"namespaces,omitempty"`
+	// LeaderElection enables the leader election, see operator.WithLeaderElection
+	LeaderElection bool `json:"leaderElection,omitempty"`
+	// DryRun enables the dry-run mode, see operator.WithDryRun
+	DryRun bool `json:"dryRun,omitempty"`
+	// LogLevel is one of "debug", "info" and "error", or the verbosity of the logs as a number, e.g. "2"
+	LogLevel string `json:"logLevel,omitempty"`
+	// LogFormat is either "json" or "console"
+	LogFormat string `json:"logFormat,omitempty"`
+	// Labels selects the objects to handle by their labels, see operator.WithLabels
+	Labels map[string]string `json:"labels,omitempty"`
+	// Annotations selects the objects to handle by their annotations, see operator.WithAnnotations
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// Defaults returns the configuration used for all settings which are not set otherwise
+func Defaults() Config {
+	return Config{
+		MetricsBindAddress:     ":8080",
+		HealthProbeBindAddress: ":8081",
+		LogLevel:               "info",
+		LogFormat:              "json",
+	}
+}
+
+// ConstructorOptions returns the options to create an Operator with the configuration
+func (c Config) ConstructorOptions() []operator.ConstructorOption {
+	opts := []operator.ConstructorOption{operator.WithManagerOptions(&manager.Options{
+		MetricsBindAddress:     c.MetricsBindAddress,
+		HealthProbeBindAddress: c.HealthProbeBindAddress,
+	})}
+	if len(c.Namespaces) > 0 {
+		opts = append(opts, operator.WithNamespaces(c.Namespaces...))
+	}
+	if c.LeaderElection {
+		opts = append(opts, operator.WithLeaderElection())
+	}
+	if c.DryRun {
+		opts = append(opts, operator.WithDryRun())
+	}
+	return opts
+}
+
+// LoggingOptions returns the options to set up the logger with the configured level and format, see logging.Setup
+func (c Config) LoggingOptions() ([]logging.Option, error) {
+	level, err := logging.ParseLevel(c.LogLevel)
+	if err != nil {
+		return nil, err
+	}
+	opts := []logging.Option{logging.WithLevel(level)}
+	switch c.LogFormat {
+	case "json", "":
+	case "console":
+		opts = append(opts, logging.WithConsole())
+	default:
+		return nil, fmt.Errorf("invalid log format %q, expected json or console", c.LogFormat)
+	}
+	return opts, nil
+}
@@ -0,0 +1,156 @@
+package config_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/SchweizerischeBundesbahnen/lot/pkg/config"
+	lotClient "github.com/SchweizerischeBundesbahnen/lot/pkg/lot-client"
+	"github.com/SchweizerischeBundesbahnen/lot/pkg/lottest"
+	"github.com/SchweizerischeBundesbahnen/lot/pkg/operator"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"github.com/spf13/pflag"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("config", func() {
+	var file string
+	var loader *config.Loader
+	var flags *pflag.FlagSet
+	write := func(content string) {
+		Expect(os.WriteFile(file, []byte(content), 0o600)).To(Succeed())
+	}
+	BeforeEach(func() {
+		file = filepath.Join(GinkgoT().TempDir(), "config.yaml")
+		loader = config.NewLoader()
+		flags = pflag.NewFlagSet("test", pflag.ContinueOnError)
+		loader.AddFlags(flags)
+	})
+	setenv := func(key, value string) {
+		Expect(os.Setenv(key, value)).To(Succeed())
+		DeferCleanup(os.Unsetenv, key)
+	}
+
+	It("should return the defaults without any sources", func() {
+		c, err := loader.Load()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(c).To(Equal(config.Defaults()))
+		Expect(loader.Selector().Matches(map[string]string{"foo": "bar"}, map[string]string{})).To(BeTrue())
+	})
+
+	It("should override the file with environment variables and the environment variables with flags", func() {
+		write("metricsBindAddress: :9090\nhealthProbeBindAddress: :9091\nnamespaces: [biz]\nlogLevel: debug\nlabels:\n  foo: bar\n")
+		setenv(config.EnvHealthProbeBindAddress, "0")
+		setenv(config.EnvNamespaces, "biz, baz")
+		setenv(config.EnvLeaderElection, "true")
+		setenv(config.EnvLabels, "foo=baz,team=ops")
+		Expect(flags.Parse([]string{"--config", file, "--namespaces", "ops", "--log-level", "2", "--log-format", "console", "--dry-run"})).To(Succeed())
+
+		c, err := loader.Load()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(c).To(Equal(config.Config{
+			MetricsBindAddress:     ":9090",
+			HealthProbeBindAddress: "0",
+			Namespaces:             []string{"ops"},
+			LeaderElection:         true,
+			DryRun:                 true,
+			LogLevel:               "2",
+			LogFormat:              "console",
+			Labels:                 map[string]string{"foo": "baz", "team": "ops"},
+		}))
+		opts, err := c.LoggingOptions()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(opts).To(HaveLen(2))
+	})
+
+	It("should read the file given with the environment", func() {
+		write("leaderElection: true\n")
+		setenv(config.EnvConfig, file)
+		c, err := loader.Load()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(c.LeaderElection).To(BeTrue())
+	})
+
+	It("should reject invalid configurations", func() {
+		setenv(config.EnvConfig, file)
+		write("unknown: true\n")
+		_, err := loader.Load()
+		Expect(err).To(MatchError(ContainSubstring("invalid configuration file")))
+
+		write("logLevel: verbose\n")
+		_, err = loader.Load()
+		Expect(err).To(MatchError(ContainSubstring(`invalid log level "verbose"`)))
+
+		write("logFormat: text\n")
+		_, err = loader.Load()
+		Expect(err).To(MatchError(ContainSubstring(`invalid log format "text"`)))
+
+		write("labels:\n  foo: '%^='\n")
+		_, err = loader.Load()
+		Expect(err).To(MatchError(ContainSubstring("invalid selector")))
+
+		write("")
+		setenv(config.EnvAnnotations, "keep")
+		_, err = loader.Load()
+		Expect(err).To(MatchError(ContainSubstring(config.EnvAnnotations)))
+	})
+
+	It("should reload the selector when the file changes", func() {
+		write("labels:\n  foo: bar\n")
+		Expect(flags.Parse([]string{"--config", file})).To(Succeed())
+		_, err := loader.Load()
+		Expect(err).NotTo(HaveOccurred())
+		sel := loader.Selector()
+		Expect(sel.MatchesLabels(map[string]string{"foo": "bar"})).To(BeTrue())
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go loader.Watch(ctx, 10*time.Millisecond)
+
+		write("labels:\n  foo: '%^='\n")
+		Consistently(func() bool { return sel.MatchesLabels(map[string]string{"foo": "bar"}) }, "50ms").Should(BeTrue())
+		write("labels:\n  foo: baz\n")
+		Eventually(func() bool { return sel.MatchesLabels(map[string]string{"foo": "baz"}) }).Should(BeTrue())
+		Expect(loader.Selector()).To(BeIdenticalTo(sel))
+	})
+
+	It("should create an operator whose handlers select the objects with the loaded selector", func() {
+		setenv(config.EnvLabels, "foo=bar")
+		c, err := loader.Load()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(c.ConstructorOptions()).To(HaveLen(1))
+
+		secret := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "biz", Name: "baz", Labels: map[string]string{"foo": "baz"}}}
+		env := lottest.New(nil, secret)
+		o, err := operator.New(&v1.Secret{}, env.WithManager())
+		Expect(err).NotTo(HaveOccurred())
+		handled := 0
+		o.OnCreateOrUpdate(func(ctx context.Context, object client.Object, cl lotClient.Client, scheme *runtime.Scheme) error {
+			handled++
+			return nil
+		}, loader.HandlerOptions()...)
+		Expect(env.Build(o)).To(Succeed())
+
+		Expect(env.Reconcile(context.Background(), client.ObjectKeyFromObject(secret)).Err).NotTo(HaveOccurred())
+		Expect(handled).To(Equal(0))
+
+		Expect(loader.Selector().Set(map[string]string{"foo": "baz"}, nil)).To(Succeed())
+		Expect(env.Reconcile(context.Background(), client.ObjectKeyFromObject(secret)).Err).NotTo(HaveOccurred())
+		Expect(handled).To(Equal(1))
+	})
+
+	It("should fail the operator if the handler options are requested before loading", func() {
+		o, err := operator.New(&v1.Secret{}, lottest.New(nil).WithManager())
+		Expect(err).NotTo(HaveOccurred())
+		o.OnCreateOrUpdate(func(ctx context.Context, object client.Object, cl lotClient.Client, scheme *runtime.Scheme) error {
+			return nil
+		}, loader.HandlerOptions()...)
+		Expect(o.StartWithContext(context.Background())).To(MatchError(ContainSubstring("WithSelector(...) requires a selector")))
+	})
+})
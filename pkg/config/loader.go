@@ -0,0 +1,316 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SchweizerischeBundesbahnen/lot/pkg/operator"
+	"github.com/SchweizerischeBundesbahnen/lot/pkg/selector"
+	"github.com/spf13/pflag"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/yaml"
+)
+
+// Environment variables overriding the settings of the file
+const (
+	EnvConfig                 = "LOT_CONFIG"
+	EnvMetricsBindAddress     = "LOT_METRICS_BIND_ADDRESS"
+	EnvHealthProbeBindAddress = "LOT_HEALTH_PROBE_BIND_ADDRESS"
+	EnvNamespaces             = "LOT_NAMESPACES"
+	EnvLeaderElection         = "LOT_LEADER_ELECTION"
+	EnvDryRun                 = "LOT_DRY_RUN"
+	EnvLogLevel               = "LOT_LOG_LEVEL"
+	EnvLogFormat              = "LOT_LOG_FORMAT"
+	EnvLabels                 = "LOT_LABELS"
+	EnvAnnotations            = "LOT_ANNOTATIONS"
+)
+
+// Flags overriding the settings of the file and the environment variables
+const (
+	FlagConfig                 = "config"
+	FlagMetricsBindAddress     = "metrics-bind-address"
+	FlagHealthProbeBindAddress = "health-probe-bind-address"
+	FlagNamespaces             = "namespaces"
+	FlagLeaderElection         = "leader-elect"
+	FlagDryRun                 = "dry-run"
+	FlagLogLevel               = "log-level"
+	FlagLogFormat              = "log-format"
+	FlagLabels                 = "labels"
+	FlagAnnotations            = "annotations"
+)
+
+// Loader loads the Config of an operator, see the package documentation for the precedence of its sources
+type Loader struct {
+	path  string
+	flags *pflag.FlagSet
+	// values holds the values of the flags, which are only used if they have been set
+	values Config
+	// content is the content of the file when it has been read last
+	content  []byte
+	config   Config
+	mu       sync.Mutex
+	selector *selector.Reloadable
+}
+
+// NewLoader returns a Loader without flags, which reads the file given with LOT_CONFIG
+func NewLoader() *Loader {
+	return &Loader{}
+}
+
+// AddFlags registers the flags of the configuration on fs, e.g. pflag.CommandLine, which have to be parsed before
+// the configuration is loaded
+func (l *Loader) AddFlags(fs *pflag.FlagSet) {
+	l.flags = fs
+	fs.StringVar(&l.path, FlagConfig, "", "path of the YAML configuration file")
+	fs.StringVar(&l.values.MetricsBindAddress, FlagMetricsBindAddress, "", `address the metrics are served on, "0" disables them`)
+	fs.StringVar(&l.values.HealthProbeBindAddress, FlagHealthProbeBindAddress, "", `address the health probes are served on, "0" disables them`)
+	fs.StringSliceVar(&l.values.Namespaces, FlagNamespaces, nil, "namespaces the operator is restricted to")
+	fs.BoolVar(&l.values.LeaderElection, FlagLeaderElection, false, "enable the leader election")
+	fs.BoolVar(&l.values.DryRun, FlagDryRun, false, "send all writes with the dry-run option and log them instead")
+	fs.StringVar(&l.values.LogLevel, FlagLogLevel, "", "debug, info, error or the verbosity of the logs as a number")
+	fs.StringVar(&l.values.LogFormat, FlagLogFormat, "", "json or console")
+	fs.StringToStringVar(&l.values.Labels, FlagLabels, nil, "labels selecting the objects to handle")
+	fs.StringToStringVar(&l.values.Annotations, FlagAnnotations, nil, "annotations selecting the objects to handle")
+}
+
+// Load loads the configuration from its sources. The first call sets up the selector returned by Selector,
+// which is updated by further calls.
+func (l *Loader) Load() (Config, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	path := l.file()
+	var content []byte
+	if path != "" {
+		var err error
+		if content, err = os.ReadFile(path); err != nil {
+			return Config{}, err
+		}
+	}
+	c, err := l.load(content)
+	if err != nil {
+		return Config{}, err
+	}
+	if l.selector == nil {
+		l.selector, err = selector.NewReloadable(c.Labels, c.Annotations)
+	} else {
+		err = l.selector.Set(c.Labels, c.Annotations)
+	}
+	if err != nil {
+		return Config{}, fmt.Errorf("invalid selector: %w", err)
+	}
+	l.content, l.config = content, c
+	return c, nil
+}
+
+// file returns the path of the configuration file, or an empty string without file
+func (l *Loader) file() string {
+	if l.path != "" {
+		return l.path
+	}
+	return os.Getenv(EnvConfig)
+}
+
+// load merges the defaults, the content of the file, the environment variables and the flags
+func (l *Loader) load(content []byte) (Config, error) {
+	c := Defaults()
+	if err := yaml.UnmarshalStrict(content, &c); err != nil {
+		return Config{}, fmt.Errorf("invalid configuration file: %w", err)
+	}
+	if err := fromEnv(&c); err != nil {
+		return Config{}, err
+	}
+	l.fromFlags(&c)
+	if _, err := c.LoggingOptions(); err != nil {
+		return Config{}, err
+	}
+	return c, nil
+}
+
+// fromEnv overrides the settings given with environment variables
+func fromEnv(c *Config) error {
+	var err error
+	if v, ok := os.LookupEnv(EnvMetricsBindAddress); ok {
+		c.MetricsBindAddress = v
+	}
+	if v, ok := os.LookupEnv(EnvHealthProbeBindAddress); ok {
+		c.HealthProbeBindAddress = v
+	}
+	if v, ok := os.LookupEnv(EnvNamespaces); ok {
+		c.Namespaces = splitList(v)
+	}
+	if v, ok := os.LookupEnv(EnvLeaderElection); ok {
+		if c.LeaderElection, err = strconv.ParseBool(v); err != nil {
+			return fmt.Errorf("invalid %s: %w", EnvLeaderElection, err)
+		}
+	}
+	if v, ok := os.LookupEnv(EnvDryRun); ok {
+		if c.DryRun, err = strconv.ParseBool(v); err != nil {
+			return fmt.Errorf("invalid %s: %w", EnvDryRun, err)
+		}
+	}
+	if v, ok := os.LookupEnv(EnvLogLevel); ok {
+		c.LogLevel = v
+	}
+	if v, ok := os.LookupEnv(EnvLogFormat); ok {
+		c.LogFormat = v
+	}
+	if v, ok := os.LookupEnv(EnvLabels); ok {
+		if c.Labels, err = splitMap(v); err != nil {
+			return fmt.Errorf("invalid %s: %w", EnvLabels, err)
+		}
+	}
+	if v, ok := os.LookupEnv(EnvAnnotations); ok {
+		if c.Annotations, err = splitMap(v); err != nil {
+			return fmt.Errorf("invalid %s: %w", EnvAnnotations, err)
+		}
+	}
+	return nil
+}
+
+// fromFlags overrides the settings given with flags
+func (l *Loader) fromFlags(c *Config) {
+	if l.flags == nil {
+		return
+	}
+	set := func(name string) bool {
+		f := l.flags.Lookup(name)
+		return f != nil && f.Changed
+	}
+	if set(FlagMetricsBindAddress) {
+		c.MetricsBindAddress = l.values.MetricsBindAddress
+	}
+	if set(FlagHealthProbeBindAddress) {
+		c.HealthProbeBindAddress = l.values.HealthProbeBindAddress
+	}
+	if set(FlagNamespaces) {
+		c.Namespaces = l.values.Namespaces
+	}
+	if set(FlagLeaderElection) {
+		c.LeaderElection = l.values.LeaderElection
+	}
+	if set(FlagDryRun) {
+		c.DryRun = l.values.DryRun
+	}
+	if set(FlagLogLevel) {
+		c.LogLevel = l.values.LogLevel
+	}
+	if set(FlagLogFormat) {
+		c.LogFormat = l.values.LogFormat
+	}
+	if set(FlagLabels) {
+		c.Labels = l.values.Labels
+	}
+	if set(FlagAnnotations) {
+		c.Annotations = l.values.Annotations
+	}
+}
+
+// splitList splits comma-separated values, ignoring empty ones
+func splitList(v string) []string {
+	var list []string
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			list = append(list, s)
+		}
+	}
+	return list
+}
+
+// splitMap splits comma-separated key=value pairs
+func splitMap(v string) (map[string]string, error) {
+	m := map[string]string{}
+	for _, pair := range splitList(v) {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("%q is not a key=value pair", pair)
+		}
+		m[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	return m, nil
+}
+
+// Selector returns the selector of the labels and annotations loaded last, which is updated when the configuration
+// is loaded again, e.g. by Watch. It is nil until the configuration has been loaded.
+func (l *Loader) Selector() *selector.Reloadable {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.selector
+}
+
+// HandlerOptions returns the options to add handlers with, which select the objects with Selector. The configuration
+// has to be loaded before.
+func (l *Loader) HandlerOptions() []operator.HandlerOption {
+	sel := l.Selector()
+	if sel == nil {
+		// fails the operator with the error of WithSelector
+		return []operator.HandlerOption{operator.WithSelector(nil)}
+	}
+	return []operator.HandlerOption{operator.WithSelector(sel)}
+}
+
+// Watch loads the configuration in the given interval until ctx is done, if the file has changed since it has been
+// loaded last. The labels and annotations of Selector are updated, all other changes are logged and only take effect
+// once the operator is restarted. Invalid configurations are logged and ignored.
+func (l *Loader) Watch(ctx context.Context, interval time.Duration) {
+	log := logf.Log.WithName("Config")
+	for {
+		select {
+		case <-time.After(interval):
+		case <-ctx.Done():
+			return
+		}
+		changed, err := l.changed()
+		if err != nil {
+			log.Error(err, "unable to read the configuration file")
+			continue
+		}
+		if !changed {
+			continue
+		}
+		previous := l.loaded()
+		c, err := l.Load()
+		if err != nil {
+			log.Error(err, "ignoring invalid configuration")
+			continue
+		}
+		log.Info("Reloaded the configuration", "labels", c.Labels, "annotations", c.Annotations)
+		if !sameExceptSelector(previous, c) {
+			log.Info("The configuration has changed in settings which are only applied on restart")
+		}
+	}
+}
+
+// changed returns true if the content of the file differs from the content loaded last
+func (l *Loader) changed() (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	path := l.file()
+	if path == "" {
+		return false, nil
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+	return !bytes.Equal(content, l.content), nil
+}
+
+// loaded returns the configuration loaded last
+func (l *Loader) loaded() Config {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.config
+}
+
+// sameExceptSelector returns true if the configurations only differ in their labels and annotations
+func sameExceptSelector(a, b Config) bool {
+	return a.MetricsBindAddress == b.MetricsBindAddress && a.HealthProbeBindAddress == b.HealthProbeBindAddress &&
+		strings.Join(a.Namespaces, ",") == strings.Join(b.Namespaces, ",") && a.LeaderElection == b.LeaderElection &&
+		a.DryRun == b.DryRun && a.LogLevel == b.LogLevel && a.LogFormat == b.LogFormat
+}
@@ -0,0 +1,115 @@
+// Package logging sets up the logger of an operator, which is used by controller-runtime and LOT and passed to the
+// handlers in their context, see log.FromContext. Handlers get a logger with the kind, namespace and name of the
+// object, the identifier of the reconcile and the name of the handler.
+package logging
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/go-logr/zapr"
+	uberzap "go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+)
+
+type options struct {
+	console    bool
+	level      zapcore.Level
+	first      int
+	thereafter int
+	output     io.Writer
+}
+
+// Option configures a logger created with New or Setup
+type Option func(*options)
+
+// WithJSON writes the logs as JSON objects, which is the default
+func WithJSON() Option {
+	return func(opts *options) {
+		opts.console = false
+	}
+}
+
+// WithConsole writes the logs as human-readable lines, e.g. for local development
+func WithConsole() Option {
+	return func(opts *options) {
+		opts.console = true
+	}
+}
+
+// WithLevel sets the minimum level of the logs, which is zapcore.InfoLevel by default. Logs written with
+// logr.Logger.V(n) have the level -n, so zapcore.DebugLevel enables the logs of V(1).
+func WithLevel(level zapcore.Level) Option {
+	return func(opts *options) {
+		opts.level = level
+	}
+}
+
+// WithSampling limits the logs with the same level and message to the first ones every second, and every
+// thereafter-th one from then on, instead of the first 100 and every 100th one. Logs are never sampled with levels
+// below zapcore.DebugLevel, which the sampler of zap does not support.
+func WithSampling(first, thereafter int) Option {
+	return func(opts *options) {
+		opts.first, opts.thereafter = first, thereafter
+	}
+}
+
+// WithOutput writes the logs to w instead of stderr
+func WithOutput(w io.Writer) Option {
+	return func(opts *options) {
+		opts.output = w
+	}
+}
+
+// New returns a logger configured with the given options
+func New(opts ...Option) logr.Logger {
+	o := options{level: zapcore.InfoLevel, first: 100, thereafter: 100, output: os.Stderr}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	config, newEncoder := uberzap.NewProductionEncoderConfig(), zapcore.NewJSONEncoder
+	if o.console {
+		config, newEncoder = uberzap.NewDevelopmentEncoderConfig(), zapcore.NewConsoleEncoder
+	}
+	config.EncodeTime = zapcore.RFC3339TimeEncoder
+
+	// the core is set up like by zap.New of controller-runtime, but with the sampling given with WithSampling
+	sink := zapcore.AddSync(o.output)
+	var core zapcore.Core = zapcore.NewCore(&zap.KubeAwareEncoder{Encoder: newEncoder(config)}, sink, o.level)
+	if o.first > 0 && o.level >= zapcore.DebugLevel {
+		core = zapcore.NewSamplerWithOptions(core, time.Second, o.first, o.thereafter)
+	}
+	return zapr.NewLogger(uberzap.New(core, uberzap.ErrorOutput(sink), uberzap.AddStacktrace(zapcore.ErrorLevel)))
+}
+
+// Setup sets the logger configured with the given options as the logger of controller-runtime and LOT and returns it.
+// It has to be called before the operator is created.
+func Setup(opts ...Option) logr.Logger {
+	log := New(opts...)
+	logf.SetLogger(log)
+	return log
+}
+
+// ParseLevel parses one of "debug", "info" and "error", or the verbosity of the logs as a number, e.g. "2"
+// for the logs written with logr.Logger.V(2) and below
+func ParseLevel(level string) (zapcore.Level, error) {
+	switch level {
+	case "debug":
+		return zapcore.DebugLevel, nil
+	case "info", "":
+		return zapcore.InfoLevel, nil
+	case "error":
+		return zapcore.ErrorLevel, nil
+	}
+	v, err := strconv.Atoi(level)
+	if err != nil || v < 0 {
+		return 0, fmt.Errorf("invalid log level %q, expected debug, info, error or a verbosity", level)
+	}
+	return zapcore.Level(-v), nil
+}
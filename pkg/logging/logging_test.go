@@ -0,0 +1,76 @@
+package logging_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	"github.com/SchweizerischeBundesbahnen/lot/pkg/logging"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.uber.org/zap/zapcore"
+)
+
+var _ = Describe("logging", func() {
+	var out *bytes.Buffer
+	BeforeEach(func() {
+		out = &bytes.Buffer{}
+	})
+	lines := func() []string {
+		return strings.Split(strings.TrimSpace(out.String()), "\n")
+	}
+
+	It("should write JSON at info level by default", func() {
+		log := logging.New(logging.WithOutput(out))
+		log.Info("hello", "name", "baz")
+		log.V(1).Info("debug")
+		Expect(lines()).To(HaveLen(1))
+		entry := map[string]interface{}{}
+		Expect(json.Unmarshal([]byte(lines()[0]), &entry)).To(Succeed())
+		Expect(entry).To(HaveKeyWithValue("msg", "hello"))
+		Expect(entry).To(HaveKeyWithValue("name", "baz"))
+	})
+
+	It("should write console lines with the given level", func() {
+		log := logging.New(logging.WithOutput(out), logging.WithConsole(), logging.WithLevel(zapcore.DebugLevel))
+		log.V(1).Info("debug")
+		log.V(2).Info("trace")
+		Expect(lines()).To(HaveLen(1))
+		Expect(lines()[0]).To(ContainSubstring("debug"))
+		Expect(lines()[0]).NotTo(HavePrefix("{"))
+	})
+
+	It("should sample repeated logs", func() {
+		log := logging.New(logging.WithOutput(out), logging.WithSampling(2, 100))
+		for i := 0; i < 10; i++ {
+			log.Info("repeated")
+		}
+		Expect(lines()).To(HaveLen(2))
+	})
+
+	It("should only apply the given sampling", func() {
+		log := logging.New(logging.WithOutput(out), logging.WithSampling(200, 100))
+		for i := 0; i < 150; i++ {
+			log.Info("repeated")
+		}
+		Expect(lines()).To(HaveLen(150))
+	})
+
+	It("should not sample levels below debug", func() {
+		log := logging.New(logging.WithOutput(out), logging.WithLevel(zapcore.Level(-3)), logging.WithSampling(2, 100))
+		for i := 0; i < 10; i++ {
+			log.V(3).Info("repeated")
+		}
+		Expect(lines()).To(HaveLen(10))
+	})
+
+	It("should parse levels", func() {
+		Expect(logging.ParseLevel("debug")).To(Equal(zapcore.DebugLevel))
+		Expect(logging.ParseLevel("")).To(Equal(zapcore.InfoLevel))
+		Expect(logging.ParseLevel("3")).To(Equal(zapcore.Level(-3)))
+		_, err := logging.ParseLevel("-1")
+		Expect(err).To(HaveOccurred())
+		_, err = logging.ParseLevel("verbose")
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -2,7 +2,9 @@ package lot_client
 
 import (
 	"context"
+	"time"
 
+	"go.opentelemetry.io/otel/trace"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
 )
@@ -10,16 +12,42 @@ import (
 type Client interface {
 	client.Client
 	Apply(ctx context.Context, obj client.Object, applyPatch interface{}, fieldsOwner string) error
+	// UpdateWithRetry reads the current state of obj from the cluster, applies fn and updates the
+	// object. On conflict the object is re-read and fn is applied again.
+	UpdateWithRetry(ctx context.Context, obj client.Object, fn MutateFn) (OperationResult, error)
+	// CreateOrPatch creates obj with the state set by fn if it does not exist yet, otherwise it
+	// patches the existing object with the changes made by fn. On conflict the object is re-read
+	// and fn is applied again.
+	CreateOrPatch(ctx context.Context, obj client.Object, fn MutateFn) (OperationResult, error)
+	// Ready reads obj from the cluster and returns a *NotReadyError requesting a requeue
+	// after requeueAfter if the object is not ready yet. See IsReady for the evaluated kinds.
+	Ready(ctx context.Context, obj client.Object, requeueAfter time.Duration) error
 }
 
 type lotClient struct {
 	client.Client
+	tracer trace.Tracer
 }
 
-func New(cl client.Client) Client {
-	return lotClient{cl}
+// New returns a Client wrapping cl, which creates a span for every call
+func New(cl client.Client, opts ...Option) Client {
+	o := newOptions(opts)
+	if o.dryRun {
+		cl = dryRunClient{Client: cl}
+	}
+	tracer := o.tracerProvider.Tracer(instrumentationName)
+	return lotClient{Client: tracedClient{Client: cl, tracer: tracer}, tracer: tracer}
+}
+
+// startSpan starts the span of a call which is composed of several calls of the embedded client.Client
+func (c lotClient) startSpan(ctx context.Context, operation string, obj client.Object) (context.Context, trace.Span) {
+	return c.tracer.Start(ctx, operation, trace.WithAttributes(ObjectAttributes(obj, c.Scheme())...))
 }
-func (c lotClient) Apply(ctx context.Context, obj client.Object, applyPatch interface{}, fieldsOwner string) error {
+
+func (c lotClient) Apply(ctx context.Context, obj client.Object, applyPatch interface{}, fieldsOwner string) (err error) {
+	ctx, span := c.startSpan(ctx, "Apply", obj)
+	defer func() { EndSpan(span, err) }()
+
 	gvk, err := apiutil.GVKForObject(obj, c.Scheme())
 	if err != nil {
 		return err
@@ -0,0 +1,220 @@
+package lot_client
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// WithDryRun makes the Client send all writes, including the writes of sub-resources such as the status, with
+// DryRunAll, so that they are validated and admitted by the API server without being persisted. Reads are served
+// as usual. Every successful write is logged as intended change, together with the diff from the live object to the
+// object the write would have resulted in as JSON merge patch. Deletions are logged without a diff. The values of the
+// data and stringData of Secrets are redacted, only the keys which are added, changed or removed are logged.
+func WithDryRun() Option {
+	return func(opts *options) {
+		opts.dryRun = true
+	}
+}
+
+var _ client.Client = dryRunClient{}
+
+// dryRunClient sends the writes of the embedded client.Client with DryRunAll and logs the changes they would make
+type dryRunClient struct {
+	client.Client
+}
+
+func (c dryRunClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) error {
+	if err := c.Client.Create(ctx, obj, append(opts, client.DryRunAll)...); err != nil {
+		return err
+	}
+	c.log(ctx, "Create", obj, nil, obj)
+	return nil
+}
+
+func (c dryRunClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	live := c.live(ctx, obj)
+	if err := c.Client.Update(ctx, obj, append(opts, client.DryRunAll)...); err != nil {
+		return err
+	}
+	c.log(ctx, "Update", obj, live, obj)
+	return nil
+}
+
+func (c dryRunClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	live := c.live(ctx, obj)
+	if err := c.Client.Patch(ctx, obj, patch, append(opts, client.DryRunAll)...); err != nil {
+		return err
+	}
+	c.log(ctx, "Patch", obj, live, obj)
+	return nil
+}
+
+func (c dryRunClient) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) error {
+	if err := c.Client.Delete(ctx, obj, append(opts, client.DryRunAll)...); err != nil {
+		return err
+	}
+	c.log(ctx, "Delete", obj, nil, nil)
+	return nil
+}
+
+func (c dryRunClient) DeleteAllOf(ctx context.Context, obj client.Object, opts ...client.DeleteAllOfOption) error {
+	if err := c.Client.DeleteAllOf(ctx, obj, append(opts, client.DryRunAll)...); err != nil {
+		return err
+	}
+	c.log(ctx, "DeleteAllOf", obj, nil, nil)
+	return nil
+}
+
+func (c dryRunClient) Status() client.SubResourceWriter {
+	return dryRunSubResourceClient{SubResourceWriter: c.Client.Status(), client: c, subResource: "status"}
+}
+
+func (c dryRunClient) SubResource(subResource string) client.SubResourceClient {
+	sc := c.Client.SubResource(subResource)
+	return dryRunSubResourceClient{SubResourceWriter: sc, reader: sc, client: c, subResource: subResource}
+}
+
+// live returns the object as it is stored in the cluster, or nil if it cannot be read
+func (c dryRunClient) live(ctx context.Context, obj client.Object) client.Object {
+	live, ok := obj.DeepCopyObject().(client.Object)
+	if !ok || c.Client.Get(ctx, client.ObjectKeyFromObject(obj), live) != nil {
+		return nil
+	}
+	return live
+}
+
+// log logs a write which has not been persisted, with the diff from the live object to the result of the write
+func (c dryRunClient) log(ctx context.Context, operation string, obj, live, result client.Object) {
+	values := []interface{}{"operation", operation, "resource", client.ObjectKeyFromObject(obj)}
+	if gvk, err := apiutil.GVKForObject(obj, c.Scheme()); err == nil {
+		values = append(values, "kind", gvk.String())
+	}
+	if result != nil {
+		d, err := diff(live, result)
+		if err != nil {
+			values = append(values, "diffError", err.Error())
+		} else {
+			values = append(values, "diff", d)
+		}
+	}
+	logf.FromContext(ctx).WithName("DryRun").Info("intended change not persisted", values...)
+}
+
+// diff returns the JSON merge patch from live to result, or result as JSON if there is no live object. The type,
+// resourceVersion and managed fields are left out, as they do not describe a change made by the caller.
+func diff(live, result client.Object) (string, error) {
+	to, err := diffable(result)
+	if err != nil {
+		return "", err
+	}
+	var from map[string]interface{}
+	if live != nil {
+		if from, err = diffable(live); err != nil {
+			return "", err
+		}
+	}
+	if isSecret(result) {
+		redact(from, to)
+	}
+	toJSON, err := json.Marshal(to)
+	if err != nil || live == nil {
+		return string(toJSON), err
+	}
+	fromJSON, err := json.Marshal(from)
+	if err != nil {
+		return "", err
+	}
+	patch, err := jsonpatch.CreateMergePatch(fromJSON, toJSON)
+	return string(patch), err
+}
+
+// diffable returns a copy of the content of obj without the fields left out of the diff
+func diffable(obj client.Object) (map[string]interface{}, error) {
+	// the content of unstructured objects is not copied by the converter
+	u, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj.DeepCopyObject())
+	if err != nil {
+		return nil, err
+	}
+	delete(u, "apiVersion")
+	delete(u, "kind")
+	if metadata, ok := u["metadata"].(map[string]interface{}); ok {
+		delete(metadata, "resourceVersion")
+		delete(metadata, "managedFields")
+	}
+	return u, nil
+}
+
+// isSecret returns true for typed and unstructured Secrets
+func isSecret(obj client.Object) bool {
+	if _, ok := obj.(*corev1.Secret); ok {
+		return true
+	}
+	gvk := obj.GetObjectKind().GroupVersionKind()
+	return gvk.Group == "" && gvk.Kind == "Secret"
+}
+
+// redact replaces the values of the data and stringData of the Secrets, so that they are not logged. Values of result
+// which differ from live are replaced with another placeholder, so that the diff still shows the changed keys.
+func redact(live, result map[string]interface{}) {
+	for _, field := range []string{"data", "stringData"} {
+		from, _ := live[field].(map[string]interface{})
+		to, _ := result[field].(map[string]interface{})
+		for key, value := range to {
+			if previous, found := from[key]; found && reflect.DeepEqual(previous, value) {
+				to[key] = "REDACTED"
+			} else {
+				to[key] = "REDACTED (changed)"
+			}
+		}
+		for key := range from {
+			from[key] = "REDACTED"
+		}
+	}
+}
+
+// dryRunSubResourceClient sends the writes of a sub-resource with DryRunAll and logs the changes they would make.
+// The reader of the sub-resource is nil for the writer returned by Status().
+type dryRunSubResourceClient struct {
+	client.SubResourceWriter
+	reader      client.SubResourceReader
+	client      dryRunClient
+	subResource string
+}
+
+func (c dryRunSubResourceClient) Get(ctx context.Context, obj client.Object, subResource client.Object, opts ...client.SubResourceGetOption) error {
+	return c.reader.Get(ctx, obj, subResource, opts...)
+}
+
+func (c dryRunSubResourceClient) Create(ctx context.Context, obj client.Object, subResource client.Object, opts ...client.SubResourceCreateOption) error {
+	if err := c.SubResourceWriter.Create(ctx, obj, subResource, append(opts, client.DryRunAll)...); err != nil {
+		return err
+	}
+	c.client.log(ctx, "Create "+c.subResource, obj, nil, subResource)
+	return nil
+}
+
+func (c dryRunSubResourceClient) Update(ctx context.Context, obj client.Object, opts ...client.SubResourceUpdateOption) error {
+	live := c.client.live(ctx, obj)
+	if err := c.SubResourceWriter.Update(ctx, obj, append(opts, client.DryRunAll)...); err != nil {
+		return err
+	}
+	c.client.log(ctx, "Update "+c.subResource, obj, live, obj)
+	return nil
+}
+
+func (c dryRunSubResourceClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.SubResourcePatchOption) error {
+	live := c.client.live(ctx, obj)
+	if err := c.SubResourceWriter.Patch(ctx, obj, patch, append(opts, client.DryRunAll)...); err != nil {
+		return err
+	}
+	c.client.log(ctx, "Patch "+c.subResource, obj, live, obj)
+	return nil
+}
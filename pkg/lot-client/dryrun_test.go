@@ -0,0 +1,122 @@
+package lot_client_test
+
+import (
+	"context"
+	"encoding/base64"
+
+	lotClient "github.com/SchweizerischeBundesbahnen/lot/pkg/lot-client"
+	"github.com/go-logr/logr/funcr"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+var _ = Describe("dry run", func() {
+	var ctx context.Context
+	var cl lotClient.Client
+	var live client.Client
+	var logs []string
+	var key client.ObjectKey
+	BeforeEach(func() {
+		logs = nil
+		log := funcr.New(func(prefix, args string) { logs = append(logs, args) }, funcr.Options{})
+		ctx = logf.IntoContext(context.Background(), log)
+		key = client.ObjectKey{Namespace: "biz", Name: "baz"}
+		live = fake.NewClientBuilder().WithObjects(&v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: key.Namespace, Name: key.Name},
+			Data:       map[string]string{"key": "value"},
+		}).Build()
+		cl = lotClient.New(live, lotClient.WithDryRun())
+	})
+	stored := func() *v1.ConfigMap {
+		cm := &v1.ConfigMap{}
+		Expect(live.Get(ctx, key, cm)).To(Succeed())
+		return cm
+	}
+
+	It("should read live objects", func() {
+		cm := &v1.ConfigMap{}
+		Expect(cl.Get(ctx, key, cm)).To(Succeed())
+		Expect(cm.Data).To(HaveKeyWithValue("key", "value"))
+		Expect(logs).To(BeEmpty())
+	})
+
+	It("should log updates with a diff instead of persisting them", func() {
+		cm := stored()
+		cm.Data["key"] = "changed"
+		Expect(cl.Update(ctx, cm)).To(Succeed())
+		Expect(stored().Data).To(HaveKeyWithValue("key", "value"))
+		Expect(logs).To(HaveLen(1))
+		Expect(logs[0]).To(ContainSubstring(`"operation"="Update"`))
+		Expect(logs[0]).To(ContainSubstring(`"kind"="/v1, Kind=ConfigMap"`))
+		Expect(logs[0]).To(ContainSubstring(`"diff"="{\"data\":{\"key\":\"changed\"}}"`))
+	})
+
+	It("should log patches with a diff instead of persisting them", func() {
+		cm := stored()
+		base := cm.DeepCopy()
+		cm.Labels = map[string]string{"foo": "bar"}
+		Expect(cl.Patch(ctx, cm, client.MergeFrom(base))).To(Succeed())
+		Expect(stored().Labels).To(BeEmpty())
+		Expect(logs).To(HaveLen(1))
+		Expect(logs[0]).To(ContainSubstring(`"diff"="{\"metadata\":{\"labels\":{\"foo\":\"bar\"}}}"`))
+	})
+
+	It("should log creations and deletions instead of persisting them", func() {
+		cm := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: key.Namespace, Name: "new"}}
+		Expect(cl.Create(ctx, cm)).To(Succeed())
+		Expect(live.Get(ctx, client.ObjectKeyFromObject(cm), &v1.ConfigMap{})).NotTo(Succeed())
+
+		Expect(cl.Delete(ctx, stored())).To(Succeed())
+		stored()
+
+		Expect(logs).To(HaveLen(2))
+		Expect(logs[0]).To(ContainSubstring(`"operation"="Create"`))
+		Expect(logs[0]).To(ContainSubstring(`\"name\":\"new\"`))
+		Expect(logs[1]).To(ContainSubstring(`"operation"="Delete"`))
+		Expect(logs[1]).NotTo(ContainSubstring(`"diff"`))
+	})
+
+	It("should redact the data of typed and unstructured Secrets", func() {
+		secret := &v1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: key.Namespace, Name: key.Name},
+			Data:       map[string][]byte{"kept": []byte("kept-secret"), "changed": []byte("old-secret")},
+		}
+		Expect(live.Create(ctx, secret)).To(Succeed())
+		secret.Data["changed"] = []byte("new-secret")
+		secret.StringData = map[string]string{"added": "added-secret"}
+		Expect(cl.Update(ctx, secret)).To(Succeed())
+
+		u := &unstructured.Unstructured{}
+		u.SetGroupVersionKind(v1.SchemeGroupVersion.WithKind("Secret"))
+		Expect(live.Get(ctx, key, u)).To(Succeed())
+		Expect(unstructured.SetNestedStringMap(u.Object, map[string]string{"added": "added-secret"}, "stringData")).To(Succeed())
+		Expect(cl.Update(ctx, u)).To(Succeed())
+		Expect(u.GetAPIVersion()).To(Equal("v1"))
+
+		Expect(logs).To(HaveLen(2))
+		for _, log := range logs {
+			Expect(log).NotTo(ContainSubstring("secret"))
+			for _, value := range []string{"kept-secret", "old-secret", "new-secret"} {
+				Expect(log).NotTo(ContainSubstring(base64.StdEncoding.EncodeToString([]byte(value))))
+			}
+			Expect(log).To(ContainSubstring(`\"added\":\"REDACTED (changed)\"`))
+		}
+		Expect(logs[0]).To(ContainSubstring(`\"changed\":\"REDACTED (changed)\"`))
+		Expect(logs[0]).NotTo(ContainSubstring("kept"))
+	})
+
+	It("should not persist status updates", func() {
+		cm := stored()
+		cm.Data["key"] = "changed"
+		Expect(cl.Status().Update(ctx, cm)).To(Succeed())
+		Expect(stored().Data).To(HaveKeyWithValue("key", "value"))
+		Expect(logs).To(HaveLen(1))
+		Expect(logs[0]).To(ContainSubstring(`"operation"="Update status"`))
+	})
+})
@@ -0,0 +1,261 @@
+package lot_client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// NotReadyError is returned by Client.Ready if an object is not ready yet.
+// Handlers can return it unchanged so that the object is reconciled again
+// after RequeueAfter() without the reconcile being counted as failure.
+type NotReadyError struct {
+	Key    client.ObjectKey
+	Kind   string
+	Reason string
+	After  time.Duration
+}
+
+func (e *NotReadyError) Error() string {
+	return fmt.Sprintf("%s %s is not ready: %s", e.Kind, e.Key, e.Reason)
+}
+
+// RequeueAfter returns the duration after which the readiness should be checked again
+func (e *NotReadyError) RequeueAfter() time.Duration {
+	return e.After
+}
+
+// IsNotReady returns true if the error (or one it wraps) is a NotReadyError
+func IsNotReady(err error) bool {
+	var notReady *NotReadyError
+	return errors.As(err, &notReady)
+}
+
+// Ready reads the current state of obj from the cluster and evaluates its readiness.
+// It returns nil if the object is ready and a *NotReadyError requesting a requeue
+// after requeueAfter if it is not ready yet. Objects which ended in a terminal failure
+// (e.g. a failed Job) result in a regular error.
+func (c lotClient) Ready(ctx context.Context, obj client.Object, requeueAfter time.Duration) (err error) {
+	ctx, span := c.startSpan(ctx, "Ready", obj)
+	defer func() { EndSpan(span, err) }()
+
+	if err := c.Get(ctx, client.ObjectKeyFromObject(obj), obj); err != nil {
+		return err
+	}
+
+	ready, reason, err := IsReady(obj)
+	if err != nil {
+		return err
+	}
+	if ready {
+		return nil
+	}
+
+	kind := obj.GetObjectKind().GroupVersionKind().Kind
+	if gvk, err := apiutil.GVKForObject(obj, c.Scheme()); err == nil {
+		kind = gvk.Kind
+	}
+	return &NotReadyError{Key: client.ObjectKeyFromObject(obj), Kind: kind, Reason: reason, After: requeueAfter}
+}
+
+// IsReady evaluates the readiness of obj as it is, without reading it from the cluster.
+// Deployments, StatefulSets, DaemonSets, Jobs, Pods and PersistentVolumeClaims are evaluated
+// based on their kind specific status, also when passed as unstructured.Unstructured. All other
+// objects are ready when they have a status condition of type "Ready" with status "True".
+// A reason is returned for objects which are not ready and an error for objects which
+// ended in a terminal failure.
+func IsReady(obj client.Object) (bool, string, error) {
+	if u, ok := obj.(*unstructured.Unstructured); ok {
+		typed, err := toTyped(u)
+		if err != nil {
+			return false, "", err
+		}
+		if typed == nil {
+			return conditionReady(u)
+		}
+		obj = typed
+	}
+
+	switch o := obj.(type) {
+	case *appsv1.Deployment:
+		return deploymentReady(o)
+	case *appsv1.StatefulSet:
+		return statefulSetReady(o)
+	case *appsv1.DaemonSet:
+		return daemonSetReady(o)
+	case *batchv1.Job:
+		return jobReady(o)
+	case *corev1.Pod:
+		return podReady(o)
+	case *corev1.PersistentVolumeClaim:
+		return pvcReady(o)
+	default:
+		content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+		if err != nil {
+			return false, "", err
+		}
+		return conditionReady(&unstructured.Unstructured{Object: content})
+	}
+}
+
+// toTyped converts an unstructured object of a kind with a specific readiness evaluation
+// into its typed counterpart. It returns nil for all other kinds.
+func toTyped(u *unstructured.Unstructured) (client.Object, error) {
+	var typed client.Object
+	switch u.GroupVersionKind() {
+	case appsv1.SchemeGroupVersion.WithKind("Deployment"):
+		typed = &appsv1.Deployment{}
+	case appsv1.SchemeGroupVersion.WithKind("StatefulSet"):
+		typed = &appsv1.StatefulSet{}
+	case appsv1.SchemeGroupVersion.WithKind("DaemonSet"):
+		typed = &appsv1.DaemonSet{}
+	case batchv1.SchemeGroupVersion.WithKind("Job"):
+		typed = &batchv1.Job{}
+	case corev1.SchemeGroupVersion.WithKind("Pod"):
+		typed = &corev1.Pod{}
+	case corev1.SchemeGroupVersion.WithKind("PersistentVolumeClaim"):
+		typed = &corev1.PersistentVolumeClaim{}
+	default:
+		return nil, nil
+	}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, typed); err != nil {
+		return nil, err
+	}
+	return typed, nil
+}
+
+func deploymentReady(d *appsv1.Deployment) (bool, string, error) {
+	if d.Generation > d.Status.ObservedGeneration {
+		return false, "waiting for the spec update to be observed", nil
+	}
+	for _, c := range d.Status.Conditions {
+		if c.Type == appsv1.DeploymentProgressing && c.Reason == "ProgressDeadlineExceeded" {
+			return false, "", fmt.Errorf("deployment %s/%s exceeded its progress deadline", d.Namespace, d.Name)
+		}
+	}
+	replicas := int32(1)
+	if d.Spec.Replicas != nil {
+		replicas = *d.Spec.Replicas
+	}
+	if d.Status.UpdatedReplicas < replicas {
+		return false, fmt.Sprintf("%d out of %d replicas have been updated", d.Status.UpdatedReplicas, replicas), nil
+	}
+	if d.Status.Replicas > d.Status.UpdatedReplicas {
+		return false, fmt.Sprintf("%d old replicas are pending termination", d.Status.Replicas-d.Status.UpdatedReplicas), nil
+	}
+	if d.Status.AvailableReplicas < d.Status.UpdatedReplicas {
+		return false, fmt.Sprintf("%d of %d updated replicas are available", d.Status.AvailableReplicas, d.Status.UpdatedReplicas), nil
+	}
+	return true, "", nil
+}
+
+func statefulSetReady(s *appsv1.StatefulSet) (bool, string, error) {
+	if s.Generation > s.Status.ObservedGeneration {
+		return false, "waiting for the spec update to be observed", nil
+	}
+	replicas := int32(1)
+	if s.Spec.Replicas != nil {
+		replicas = *s.Spec.Replicas
+	}
+	if s.Status.ReadyReplicas < replicas {
+		return false, fmt.Sprintf("%d out of %d replicas are ready", s.Status.ReadyReplicas, replicas), nil
+	}
+	// pods are only updated once they are deleted, so the revisions may differ for a long time
+	if s.Spec.UpdateStrategy.Type == appsv1.OnDeleteStatefulSetStrategyType {
+		return true, "", nil
+	}
+	if u := s.Spec.UpdateStrategy.RollingUpdate; u != nil && u.Partition != nil && *u.Partition > 0 {
+		if s.Status.UpdatedReplicas < replicas-*u.Partition {
+			return false, fmt.Sprintf("%d out of %d replicas of the partition have been updated", s.Status.UpdatedReplicas, replicas-*u.Partition), nil
+		}
+		return true, "", nil
+	}
+	if s.Status.UpdateRevision != s.Status.CurrentRevision {
+		return false, fmt.Sprintf("%d out of %d replicas have been updated", s.Status.UpdatedReplicas, replicas), nil
+	}
+	return true, "", nil
+}
+
+func daemonSetReady(d *appsv1.DaemonSet) (bool, string, error) {
+	if d.Generation > d.Status.ObservedGeneration {
+		return false, "waiting for the spec update to be observed", nil
+	}
+	if d.Status.UpdatedNumberScheduled < d.Status.DesiredNumberScheduled {
+		return false, fmt.Sprintf("%d out of %d pods have been updated", d.Status.UpdatedNumberScheduled, d.Status.DesiredNumberScheduled), nil
+	}
+	if d.Status.NumberAvailable < d.Status.DesiredNumberScheduled {
+		return false, fmt.Sprintf("%d of %d updated pods are available", d.Status.NumberAvailable, d.Status.DesiredNumberScheduled), nil
+	}
+	return true, "", nil
+}
+
+func jobReady(j *batchv1.Job) (bool, string, error) {
+	for _, c := range j.Status.Conditions {
+		if c.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch c.Type {
+		case batchv1.JobComplete:
+			return true, "", nil
+		case batchv1.JobFailed:
+			return false, "", fmt.Errorf("job %s/%s failed: %s", j.Namespace, j.Name, c.Message)
+		}
+	}
+	return false, "job has not completed yet", nil
+}
+
+func podReady(p *corev1.Pod) (bool, string, error) {
+	switch p.Status.Phase {
+	case corev1.PodSucceeded:
+		return true, "", nil
+	case corev1.PodFailed:
+		return false, "", fmt.Errorf("pod %s/%s failed: %s", p.Namespace, p.Name, p.Status.Message)
+	}
+	for _, c := range p.Status.Conditions {
+		if c.Type == corev1.PodReady && c.Status == corev1.ConditionTrue {
+			return true, "", nil
+		}
+	}
+	return false, fmt.Sprintf("pod is %s and not ready", p.Status.Phase), nil
+}
+
+func pvcReady(p *corev1.PersistentVolumeClaim) (bool, string, error) {
+	if p.Status.Phase == corev1.ClaimBound {
+		return true, "", nil
+	}
+	if p.Status.Phase == corev1.ClaimLost {
+		return false, "", fmt.Errorf("persistent volume claim %s/%s lost its volume", p.Namespace, p.Name)
+	}
+	return false, fmt.Sprintf("claim is %s", p.Status.Phase), nil
+}
+
+func conditionReady(u *unstructured.Unstructured) (bool, string, error) {
+	conditions, found, err := unstructured.NestedSlice(u.Object, "status", "conditions")
+	if err != nil {
+		return false, "", err
+	}
+	if !found {
+		return false, "object has no status conditions", nil
+	}
+	for _, c := range conditions {
+		condition, ok := c.(map[string]interface{})
+		if !ok || condition["type"] != "Ready" {
+			continue
+		}
+		if condition["status"] == string(corev1.ConditionTrue) {
+			return true, "", nil
+		}
+		reason, _ := condition["reason"].(string)
+		return false, fmt.Sprintf("Ready condition is %v: %s", condition["status"], reason), nil
+	}
+	return false, "object has no Ready condition", nil
+}
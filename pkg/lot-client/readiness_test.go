@@ -0,0 +1,205 @@
+package lot_client_test
+
+import (
+	"context"
+	"time"
+
+	lotClient "github.com/SchweizerischeBundesbahnen/lot/pkg/lot-client"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("readiness", func() {
+	var replicas int32 = 2
+	var meta metav1.ObjectMeta
+	BeforeEach(func() {
+		meta = metav1.ObjectMeta{Namespace: "biz", Name: "baz", Generation: 2}
+	})
+
+	Describe("When evaluating a Deployment", func() {
+		It("should not be ready while the update is not observed", func() {
+			d := &appsv1.Deployment{ObjectMeta: meta, Spec: appsv1.DeploymentSpec{Replicas: &replicas}}
+			d.Status = appsv1.DeploymentStatus{ObservedGeneration: 1, Replicas: 2, UpdatedReplicas: 2, AvailableReplicas: 2}
+			ready, reason, err := lotClient.IsReady(d)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ready).To(BeFalse())
+			Expect(reason).ToNot(BeEmpty())
+		})
+		It("should not be ready while replicas are unavailable", func() {
+			d := &appsv1.Deployment{ObjectMeta: meta, Spec: appsv1.DeploymentSpec{Replicas: &replicas}}
+			d.Status = appsv1.DeploymentStatus{ObservedGeneration: 2, Replicas: 2, UpdatedReplicas: 2, AvailableReplicas: 1}
+			ready, _, err := lotClient.IsReady(d)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ready).To(BeFalse())
+		})
+		It("should be ready when all replicas are updated and available", func() {
+			d := &appsv1.Deployment{ObjectMeta: meta, Spec: appsv1.DeploymentSpec{Replicas: &replicas}}
+			d.Status = appsv1.DeploymentStatus{ObservedGeneration: 2, Replicas: 2, UpdatedReplicas: 2, AvailableReplicas: 2}
+			ready, _, err := lotClient.IsReady(d)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ready).To(BeTrue())
+		})
+		It("should fail when the progress deadline is exceeded", func() {
+			d := &appsv1.Deployment{ObjectMeta: meta, Spec: appsv1.DeploymentSpec{Replicas: &replicas}}
+			d.Status = appsv1.DeploymentStatus{ObservedGeneration: 2, Conditions: []appsv1.DeploymentCondition{
+				{Type: appsv1.DeploymentProgressing, Status: corev1.ConditionFalse, Reason: "ProgressDeadlineExceeded"},
+			}}
+			_, _, err := lotClient.IsReady(d)
+			Expect(err).To(HaveOccurred())
+		})
+		It("should evaluate an unstructured Deployment in the same way", func() {
+			d := &appsv1.Deployment{ObjectMeta: meta, Spec: appsv1.DeploymentSpec{Replicas: &replicas}}
+			d.Status = appsv1.DeploymentStatus{ObservedGeneration: 2, Replicas: 2, UpdatedReplicas: 2, AvailableReplicas: 1}
+			content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(d)
+			Expect(err).ToNot(HaveOccurred())
+			u := &unstructured.Unstructured{Object: content}
+			u.SetGroupVersionKind(appsv1.SchemeGroupVersion.WithKind("Deployment"))
+			ready, _, err := lotClient.IsReady(u)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ready).To(BeFalse())
+		})
+	})
+
+	Describe("When evaluating a StatefulSet", func() {
+		It("should not be ready while the revisions differ", func() {
+			s := &appsv1.StatefulSet{ObjectMeta: meta, Spec: appsv1.StatefulSetSpec{Replicas: &replicas}}
+			s.Status = appsv1.StatefulSetStatus{ObservedGeneration: 2, ReadyReplicas: 2, CurrentRevision: "a", UpdateRevision: "b"}
+			ready, _, err := lotClient.IsReady(s)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ready).To(BeFalse())
+		})
+		It("should not wait for the revisions with the update strategy OnDelete", func() {
+			s := &appsv1.StatefulSet{ObjectMeta: meta, Spec: appsv1.StatefulSetSpec{Replicas: &replicas}}
+			s.Spec.UpdateStrategy.Type = appsv1.OnDeleteStatefulSetStrategyType
+			s.Status = appsv1.StatefulSetStatus{ObservedGeneration: 2, ReadyReplicas: 2, CurrentRevision: "a", UpdateRevision: "b"}
+			ready, _, err := lotClient.IsReady(s)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ready).To(BeTrue())
+		})
+		It("should be ready when all replicas are ready and updated", func() {
+			s := &appsv1.StatefulSet{ObjectMeta: meta, Spec: appsv1.StatefulSetSpec{Replicas: &replicas}}
+			s.Status = appsv1.StatefulSetStatus{ObservedGeneration: 2, ReadyReplicas: 2, CurrentRevision: "b", UpdateRevision: "b"}
+			ready, _, err := lotClient.IsReady(s)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ready).To(BeTrue())
+		})
+	})
+
+	Describe("When evaluating a DaemonSet", func() {
+		It("should only be ready when all pods are updated and available", func() {
+			d := &appsv1.DaemonSet{ObjectMeta: meta}
+			d.Status = appsv1.DaemonSetStatus{ObservedGeneration: 2, DesiredNumberScheduled: 3, UpdatedNumberScheduled: 3, NumberAvailable: 2}
+			ready, _, err := lotClient.IsReady(d)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ready).To(BeFalse())
+
+			d.Status.NumberAvailable = 3
+			ready, _, err = lotClient.IsReady(d)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ready).To(BeTrue())
+		})
+	})
+
+	Describe("When evaluating a Job", func() {
+		It("should be ready when it is complete", func() {
+			j := &batchv1.Job{ObjectMeta: meta}
+			ready, _, err := lotClient.IsReady(j)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ready).To(BeFalse())
+
+			j.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobComplete, Status: corev1.ConditionTrue}}
+			ready, _, err = lotClient.IsReady(j)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ready).To(BeTrue())
+		})
+		It("should fail when it failed", func() {
+			j := &batchv1.Job{ObjectMeta: meta}
+			j.Status.Conditions = []batchv1.JobCondition{{Type: batchv1.JobFailed, Status: corev1.ConditionTrue}}
+			_, _, err := lotClient.IsReady(j)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("When evaluating a Pod", func() {
+		It("should be ready when the Ready condition is true", func() {
+			p := &corev1.Pod{ObjectMeta: meta, Status: corev1.PodStatus{Phase: corev1.PodRunning}}
+			ready, _, err := lotClient.IsReady(p)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ready).To(BeFalse())
+
+			p.Status.Conditions = []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}}
+			ready, _, err = lotClient.IsReady(p)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ready).To(BeTrue())
+		})
+	})
+
+	Describe("When evaluating a PersistentVolumeClaim", func() {
+		It("should be ready when it is bound", func() {
+			p := &corev1.PersistentVolumeClaim{ObjectMeta: meta, Status: corev1.PersistentVolumeClaimStatus{Phase: corev1.ClaimPending}}
+			ready, _, err := lotClient.IsReady(p)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ready).To(BeFalse())
+
+			p.Status.Phase = corev1.ClaimBound
+			ready, _, err = lotClient.IsReady(p)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ready).To(BeTrue())
+		})
+	})
+
+	Describe("When evaluating an unstructured object of another kind", func() {
+		var u *unstructured.Unstructured
+		BeforeEach(func() {
+			u = &unstructured.Unstructured{}
+			u.SetGroupVersionKind(schema.GroupVersionKind{Group: "foo.sbb.ch", Version: "v1", Kind: "Other"})
+		})
+		It("should not be ready without a Ready condition", func() {
+			ready, _, err := lotClient.IsReady(u)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ready).To(BeFalse())
+		})
+		It("should be ready with a true Ready condition", func() {
+			Expect(unstructured.SetNestedSlice(u.Object, []interface{}{
+				map[string]interface{}{"type": "Ready", "status": "True"},
+			}, "status", "conditions")).To(Succeed())
+			ready, _, err := lotClient.IsReady(u)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ready).To(BeTrue())
+		})
+	})
+
+	Describe("When calling Ready", func() {
+		var cl lotClient.Client
+		BeforeEach(func() {
+			p := &corev1.Pod{ObjectMeta: meta, Status: corev1.PodStatus{Phase: corev1.PodPending}}
+			cl = lotClient.New(fake.NewClientBuilder().WithObjects(p).Build())
+		})
+		It("should request a requeue for objects that are not ready", func() {
+			p := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: meta.Namespace, Name: meta.Name}}
+			err := cl.Ready(context.Background(), p, time.Minute)
+			Expect(lotClient.IsNotReady(err)).To(BeTrue())
+
+			var notReady *lotClient.NotReadyError
+			Expect(err).To(BeAssignableToTypeOf(notReady))
+			Expect(err.(*lotClient.NotReadyError).RequeueAfter()).To(Equal(time.Minute))
+			Expect(err.(*lotClient.NotReadyError).Kind).To(Equal("Pod"))
+		})
+		It("should return the read error for missing objects", func() {
+			p := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: meta.Namespace, Name: "missing"}}
+			err := cl.Ready(context.Background(), p, time.Minute)
+			Expect(err).To(HaveOccurred())
+			Expect(lotClient.IsNotReady(err)).To(BeFalse())
+			Expect(client.IgnoreNotFound(err)).ToNot(HaveOccurred())
+		})
+	})
+})
@@ -0,0 +1,146 @@
+package lot_client
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// OperationResult is the action result of an UpdateWithRetry or CreateOrPatch call.
+type OperationResult string
+
+const (
+	// OperationResultNone means that the object was not changed
+	OperationResultNone OperationResult = "unchanged"
+	// OperationResultCreated means that a new object was created
+	OperationResultCreated OperationResult = "created"
+	// OperationResultUpdated means that an existing object was updated or patched
+	OperationResultUpdated OperationResult = "updated"
+)
+
+// MutateFn is a function which mutates the given object into its desired state.
+// It is called again with a freshly read object every time a write is retried.
+type MutateFn func() error
+
+// retryBackoff bounds the number of attempts made on conflicts.
+var retryBackoff = retry.DefaultBackoff
+
+func (c lotClient) UpdateWithRetry(ctx context.Context, obj client.Object, fn MutateFn) (OperationResult, error) {
+	ctx, span := c.startSpan(ctx, "UpdateWithRetry", obj)
+	key := client.ObjectKeyFromObject(obj)
+	result := OperationResultNone
+	attempt := 0
+
+	err := retry.RetryOnConflict(retryBackoff, func() error {
+		if attempt > 0 {
+			reset(obj, key)
+		}
+		attempt++
+		if err := c.Get(ctx, key, obj); err != nil {
+			return err
+		}
+		existing := obj.DeepCopyObject()
+		if err := mutate(fn, key, obj); err != nil {
+			return err
+		}
+		if equality.Semantic.DeepEqual(existing, obj) {
+			result = OperationResultNone
+			return nil
+		}
+		if err := c.Update(ctx, obj); err != nil {
+			return err
+		}
+		result = OperationResultUpdated
+		return nil
+	})
+	EndSpan(span, err)
+	if err != nil {
+		return OperationResultNone, err
+	}
+
+	return result, nil
+}
+
+func (c lotClient) CreateOrPatch(ctx context.Context, obj client.Object, fn MutateFn) (OperationResult, error) {
+	ctx, span := c.startSpan(ctx, "CreateOrPatch", obj)
+	key := client.ObjectKeyFromObject(obj)
+	result := OperationResultNone
+
+	// a concurrent create shows up as AlreadyExists, which is retried in the same way
+	// as a conflict, as the next attempt patches the object created in the meantime
+	retriable := func(err error) bool {
+		return apierrors.IsConflict(err) || apierrors.IsAlreadyExists(err)
+	}
+	attempt := 0
+	err := retry.OnError(retryBackoff, retriable, func() error {
+		if attempt > 0 {
+			reset(obj, key)
+		}
+		attempt++
+		if err := c.Get(ctx, key, obj); err != nil {
+			if !apierrors.IsNotFound(err) {
+				return err
+			}
+			if err := mutate(fn, key, obj); err != nil {
+				return err
+			}
+			if err := c.Create(ctx, obj); err != nil {
+				return err
+			}
+			result = OperationResultCreated
+			return nil
+		}
+
+		existing := obj.DeepCopyObject().(client.Object)
+		if err := mutate(fn, key, obj); err != nil {
+			return err
+		}
+		if equality.Semantic.DeepEqual(existing, obj) {
+			result = OperationResultNone
+			return nil
+		}
+		// the optimistic lock makes the API server reject the patch with a conflict
+		// if the object was changed since it has been read
+		patch := client.MergeFromWithOptions(existing, client.MergeFromWithOptimisticLock{})
+		if err := c.Patch(ctx, obj, patch); err != nil {
+			return err
+		}
+		result = OperationResultUpdated
+		return nil
+	})
+	EndSpan(span, err)
+	if err != nil {
+		return OperationResultNone, err
+	}
+
+	return result, nil
+}
+
+// mutate wraps a MutateFn and makes sure that it does not change the name or namespace of the object
+func mutate(fn MutateFn, key client.ObjectKey, obj client.Object) error {
+	if fn != nil {
+		if err := fn(); err != nil {
+			return err
+		}
+	}
+	if newKey := client.ObjectKeyFromObject(obj); key != newKey {
+		return fmt.Errorf("MutateFn cannot mutate object name and/or object namespace")
+	}
+	return nil
+}
+
+// reset clears all changes a previous attempt made to obj, keeping only the information
+// needed to read the object again
+func reset(obj client.Object, key client.ObjectKey) {
+	gvk := obj.GetObjectKind().GroupVersionKind()
+	v := reflect.ValueOf(obj).Elem()
+	v.Set(reflect.Zero(v.Type()))
+	obj.GetObjectKind().SetGroupVersionKind(gvk)
+	obj.SetName(key.Name)
+	obj.SetNamespace(key.Namespace)
+}
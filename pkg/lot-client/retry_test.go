@@ -0,0 +1,181 @@
+package lot_client_test
+
+import (
+	"context"
+
+	lotClient "github.com/SchweizerischeBundesbahnen/lot/pkg/lot-client"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// racingClient changes the stored object right before the first write, so that
+// the write of the caller fails with a conflict
+type racingClient struct {
+	client.Client
+	races int
+}
+
+func (c *racingClient) race(ctx context.Context, obj client.Object) {
+	if c.races == 0 {
+		return
+	}
+	c.races--
+	cm := &v1.ConfigMap{}
+	Expect(c.Client.Get(ctx, client.ObjectKeyFromObject(obj), cm)).To(Succeed())
+	if cm.Labels == nil {
+		cm.Labels = map[string]string{}
+	}
+	cm.Labels["race"] = "won"
+	Expect(c.Client.Update(ctx, cm)).To(Succeed())
+}
+
+func (c *racingClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) error {
+	c.race(ctx, obj)
+	return c.Client.Update(ctx, obj, opts...)
+}
+
+func (c *racingClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	c.race(ctx, obj)
+	return c.Client.Patch(ctx, obj, patch, opts...)
+}
+
+var _ = Describe("lot-client", func() {
+	var ctx context.Context
+	var cl lotClient.Client
+	var racing *racingClient
+	var key client.ObjectKey
+	BeforeEach(func() {
+		ctx = context.Background()
+		key = client.ObjectKey{Namespace: "biz", Name: "baz"}
+		racing = &racingClient{Client: fake.NewClientBuilder().Build()}
+		cl = lotClient.New(racing)
+	})
+	existing := func() {
+		cm := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: key.Namespace, Name: key.Name}}
+		Expect(cl.Create(ctx, cm)).To(Succeed())
+	}
+
+	Describe("When calling UpdateWithRetry", func() {
+		It("should update an existing object", func() {
+			existing()
+			cm := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: key.Namespace, Name: key.Name}}
+			result, err := cl.UpdateWithRetry(ctx, cm, func() error {
+				cm.Data = map[string]string{"key": "value"}
+				return nil
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result).To(Equal(lotClient.OperationResultUpdated))
+
+			stored := &v1.ConfigMap{}
+			Expect(cl.Get(ctx, key, stored)).To(Succeed())
+			Expect(stored.Data).To(HaveKeyWithValue("key", "value"))
+		})
+		It("should not update an object that is already in the desired state", func() {
+			existing()
+			cm := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: key.Namespace, Name: key.Name}}
+			result, err := cl.UpdateWithRetry(ctx, cm, func() error { return nil })
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result).To(Equal(lotClient.OperationResultNone))
+		})
+		It("should re-apply the mutation on conflict", func() {
+			existing()
+			racing.races = 1
+			calls := 0
+			cm := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: key.Namespace, Name: key.Name}}
+			result, err := cl.UpdateWithRetry(ctx, cm, func() error {
+				calls++
+				cm.Data = map[string]string{"key": "value"}
+				return nil
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result).To(Equal(lotClient.OperationResultUpdated))
+			Expect(calls).To(Equal(2))
+
+			stored := &v1.ConfigMap{}
+			Expect(cl.Get(ctx, key, stored)).To(Succeed())
+			Expect(stored.Labels).To(HaveKeyWithValue("race", "won"))
+			Expect(stored.Data).To(HaveKeyWithValue("key", "value"))
+		})
+		It("should give up after a bounded number of conflicts", func() {
+			existing()
+			racing.races = 100
+			cm := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: key.Namespace, Name: key.Name}}
+			result, err := cl.UpdateWithRetry(ctx, cm, func() error {
+				cm.Data = map[string]string{"key": "value"}
+				return nil
+			})
+			Expect(err).To(HaveOccurred())
+			Expect(result).To(Equal(lotClient.OperationResultNone))
+		})
+		It("should return an error if the object does not exist", func() {
+			cm := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: key.Namespace, Name: key.Name}}
+			_, err := cl.UpdateWithRetry(ctx, cm, func() error { return nil })
+			Expect(err).To(HaveOccurred())
+		})
+		It("should not allow the mutation to change the name", func() {
+			existing()
+			cm := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: key.Namespace, Name: key.Name}}
+			_, err := cl.UpdateWithRetry(ctx, cm, func() error {
+				cm.Name = "other"
+				return nil
+			})
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("When calling CreateOrPatch", func() {
+		It("should create a missing object", func() {
+			cm := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: key.Namespace, Name: key.Name}}
+			result, err := cl.CreateOrPatch(ctx, cm, func() error {
+				cm.Data = map[string]string{"key": "value"}
+				return nil
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result).To(Equal(lotClient.OperationResultCreated))
+
+			stored := &v1.ConfigMap{}
+			Expect(cl.Get(ctx, key, stored)).To(Succeed())
+			Expect(stored.Data).To(HaveKeyWithValue("key", "value"))
+		})
+		It("should patch an existing object", func() {
+			existing()
+			cm := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: key.Namespace, Name: key.Name}}
+			result, err := cl.CreateOrPatch(ctx, cm, func() error {
+				cm.Data = map[string]string{"key": "value"}
+				return nil
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result).To(Equal(lotClient.OperationResultUpdated))
+		})
+		It("should not patch an object that is already in the desired state", func() {
+			existing()
+			cm := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: key.Namespace, Name: key.Name}}
+			result, err := cl.CreateOrPatch(ctx, cm, func() error { return nil })
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result).To(Equal(lotClient.OperationResultNone))
+		})
+		It("should re-apply the mutation on conflict", func() {
+			existing()
+			racing.races = 1
+			calls := 0
+			cm := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: key.Namespace, Name: key.Name}}
+			result, err := cl.CreateOrPatch(ctx, cm, func() error {
+				calls++
+				cm.Data = map[string]string{"key": "value"}
+				return nil
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(result).To(Equal(lotClient.OperationResultUpdated))
+			Expect(calls).To(Equal(2))
+
+			stored := &v1.ConfigMap{}
+			Expect(cl.Get(ctx, key, stored)).To(Succeed())
+			Expect(stored.Labels).To(HaveKeyWithValue("race", "won"))
+			Expect(stored.Data).To(HaveKeyWithValue("key", "value"))
+		})
+	})
+})
@@ -0,0 +1,179 @@
+package lot_client
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// instrumentationName is the name of the tracer creating the spans of the client calls
+const instrumentationName = "github.com/SchweizerischeBundesbahnen/lot/pkg/lot-client"
+
+// Attribute keys set on the spans of client calls and reconciles
+const (
+	GVKKey       = attribute.Key("k8s.object.gvk")
+	NamespaceKey = attribute.Key("k8s.namespace.name")
+	NameKey      = attribute.Key("k8s.object.name")
+)
+
+type options struct {
+	tracerProvider trace.TracerProvider
+	dryRun         bool
+}
+
+// Option configures a Client created with New
+type Option func(*options)
+
+// WithTracerProvider sets the TracerProvider used to create a span for every call of the Client.
+// By default the global TracerProvider of OpenTelemetry is used, which does not record anything
+// unless it has been set with otel.SetTracerProvider.
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(opts *options) {
+		opts.tracerProvider = tp
+	}
+}
+
+// ObjectAttributes returns the span attributes identifying obj
+func ObjectAttributes(obj runtime.Object, scheme *runtime.Scheme) []attribute.KeyValue {
+	var attrs []attribute.KeyValue
+	if gvk, err := apiutil.GVKForObject(obj, scheme); err == nil {
+		attrs = append(attrs, GVKKey.String(gvk.String()))
+	}
+	if o, ok := obj.(client.Object); ok {
+		if o.GetNamespace() != "" {
+			attrs = append(attrs, NamespaceKey.String(o.GetNamespace()))
+		}
+		if o.GetName() != "" {
+			attrs = append(attrs, NameKey.String(o.GetName()))
+		}
+	}
+	return attrs
+}
+
+// EndSpan records err, if any, on span and ends it. NotFound errors are recorded without
+// marking the span as failed, as they are usually expected.
+func EndSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		if !apierrors.IsNotFound(err) {
+			span.SetStatus(codes.Error, err.Error())
+		}
+	}
+	span.End()
+}
+
+func newOptions(opts []Option) options {
+	o := options{tracerProvider: otel.GetTracerProvider()}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+var _ client.Client = tracedClient{}
+
+// tracedClient creates a span for every call of the embedded client.Client
+type tracedClient struct {
+	client.Client
+	tracer trace.Tracer
+}
+
+func (c tracedClient) start(ctx context.Context, operation string, obj runtime.Object, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	attrs = append(ObjectAttributes(obj, c.Scheme()), attrs...)
+	return c.tracer.Start(ctx, operation, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+}
+
+func (c tracedClient) Get(ctx context.Context, key client.ObjectKey, obj client.Object, opts ...client.GetOption) (err error) {
+	ctx, span := c.start(ctx, "Get", obj, NamespaceKey.String(key.Namespace), NameKey.String(key.Name))
+	defer func() { EndSpan(span, err) }()
+	return c.Client.Get(ctx, key, obj, opts...)
+}
+
+func (c tracedClient) List(ctx context.Context, list client.ObjectList, opts ...client.ListOption) (err error) {
+	ctx, span := c.start(ctx, "List", list)
+	defer func() { EndSpan(span, err) }()
+	return c.Client.List(ctx, list, opts...)
+}
+
+func (c tracedClient) Create(ctx context.Context, obj client.Object, opts ...client.CreateOption) (err error) {
+	ctx, span := c.start(ctx, "Create", obj)
+	defer func() { EndSpan(span, err) }()
+	return c.Client.Create(ctx, obj, opts...)
+}
+
+func (c tracedClient) Delete(ctx context.Context, obj client.Object, opts ...client.DeleteOption) (err error) {
+	ctx, span := c.start(ctx, "Delete", obj)
+	defer func() { EndSpan(span, err) }()
+	return c.Client.Delete(ctx, obj, opts...)
+}
+
+func (c tracedClient) Update(ctx context.Context, obj client.Object, opts ...client.UpdateOption) (err error) {
+	ctx, span := c.start(ctx, "Update", obj)
+	defer func() { EndSpan(span, err) }()
+	return c.Client.Update(ctx, obj, opts...)
+}
+
+func (c tracedClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) (err error) {
+	ctx, span := c.start(ctx, "Patch", obj, attribute.String("k8s.patch.type", string(patch.Type())))
+	defer func() { EndSpan(span, err) }()
+	return c.Client.Patch(ctx, obj, patch, opts...)
+}
+
+func (c tracedClient) DeleteAllOf(ctx context.Context, obj client.Object, opts ...client.DeleteAllOfOption) (err error) {
+	ctx, span := c.start(ctx, "DeleteAllOf", obj)
+	defer func() { EndSpan(span, err) }()
+	return c.Client.DeleteAllOf(ctx, obj, opts...)
+}
+
+func (c tracedClient) Status() client.SubResourceWriter {
+	return tracedSubResourceClient{SubResourceWriter: c.Client.Status(), client: c, subResource: "status"}
+}
+
+func (c tracedClient) SubResource(subResource string) client.SubResourceClient {
+	sc := c.Client.SubResource(subResource)
+	return tracedSubResourceClient{SubResourceWriter: sc, reader: sc, client: c, subResource: subResource}
+}
+
+// tracedSubResourceClient creates a span for every call of the embedded client.SubResourceWriter
+// and the reader of the sub-resource, which is nil for the writer returned by Status()
+type tracedSubResourceClient struct {
+	client.SubResourceWriter
+	reader      client.SubResourceReader
+	client      tracedClient
+	subResource string
+}
+
+func (c tracedSubResourceClient) start(ctx context.Context, operation string, obj client.Object) (context.Context, trace.Span) {
+	return c.client.start(ctx, operation+" "+c.subResource, obj, attribute.String("k8s.subresource", c.subResource))
+}
+
+func (c tracedSubResourceClient) Get(ctx context.Context, obj client.Object, subResource client.Object, opts ...client.SubResourceGetOption) (err error) {
+	ctx, span := c.start(ctx, "Get", obj)
+	defer func() { EndSpan(span, err) }()
+	return c.reader.Get(ctx, obj, subResource, opts...)
+}
+
+func (c tracedSubResourceClient) Create(ctx context.Context, obj client.Object, subResource client.Object, opts ...client.SubResourceCreateOption) (err error) {
+	ctx, span := c.start(ctx, "Create", obj)
+	defer func() { EndSpan(span, err) }()
+	return c.SubResourceWriter.Create(ctx, obj, subResource, opts...)
+}
+
+func (c tracedSubResourceClient) Update(ctx context.Context, obj client.Object, opts ...client.SubResourceUpdateOption) (err error) {
+	ctx, span := c.start(ctx, "Update", obj)
+	defer func() { EndSpan(span, err) }()
+	return c.SubResourceWriter.Update(ctx, obj, opts...)
+}
+
+func (c tracedSubResourceClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.SubResourcePatchOption) (err error) {
+	ctx, span := c.start(ctx, "Patch", obj)
+	defer func() { EndSpan(span, err) }()
+	return c.SubResourceWriter.Patch(ctx, obj, patch, opts...)
+}
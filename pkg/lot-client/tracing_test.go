@@ -0,0 +1,79 @@
+package lot_client_test
+
+import (
+	"context"
+
+	lotClient "github.com/SchweizerischeBundesbahnen/lot/pkg/lot-client"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("tracing", func() {
+	var ctx context.Context
+	var cl lotClient.Client
+	var recorder *tracetest.SpanRecorder
+	var cm *v1.ConfigMap
+	BeforeEach(func() {
+		ctx = context.Background()
+		recorder = tracetest.NewSpanRecorder()
+		tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+		cl = lotClient.New(fake.NewClientBuilder().Build(), lotClient.WithTracerProvider(tp))
+		cm = &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "biz", Name: "baz"}}
+	})
+	names := func() []string {
+		var names []string
+		for _, span := range recorder.Ended() {
+			names = append(names, span.Name())
+		}
+		return names
+	}
+
+	It("should create a span for every call", func() {
+		Expect(cl.Create(ctx, cm)).To(Succeed())
+		Expect(cl.Get(ctx, client.ObjectKeyFromObject(cm), cm)).To(Succeed())
+		Expect(cl.List(ctx, &v1.ConfigMapList{})).To(Succeed())
+		Expect(cl.Status().Update(ctx, cm)).To(Succeed())
+		Expect(cl.Delete(ctx, cm)).To(Succeed())
+		Expect(names()).To(Equal([]string{"Create", "Get", "List", "Update status", "Delete"}))
+
+		span := recorder.Ended()[0]
+		Expect(span.Attributes()).To(ContainElements(
+			lotClient.GVKKey.String("/v1, Kind=ConfigMap"),
+			lotClient.NamespaceKey.String("biz"),
+			lotClient.NameKey.String("baz")))
+	})
+
+	It("should nest the calls of composite operations", func() {
+		_, err := cl.CreateOrPatch(ctx, cm, func() error {
+			cm.Data = map[string]string{"key": "value"}
+			return nil
+		})
+		Expect(err).NotTo(HaveOccurred())
+
+		spans := recorder.Ended()
+		Expect(names()).To(Equal([]string{"Get", "Create", "CreateOrPatch"}))
+		parent := spans[2].SpanContext().SpanID()
+		Expect(spans[0].Parent().SpanID()).To(Equal(parent))
+		Expect(spans[1].Parent().SpanID()).To(Equal(parent))
+	})
+
+	It("should record failures", func() {
+		Expect(cl.Create(ctx, cm)).To(Succeed())
+		Expect(cl.Create(ctx, cm)).NotTo(Succeed())
+		Expect(recorder.Ended()[1].Status().Code).To(Equal(codes.Error))
+	})
+
+	It("should not mark objects not found as failure", func() {
+		Expect(cl.Get(ctx, client.ObjectKeyFromObject(cm), cm)).NotTo(Succeed())
+		span := recorder.Ended()[0]
+		Expect(span.Status().Code).To(Equal(codes.Unset))
+		Expect(span.Events()).To(HaveLen(1))
+	})
+})
@@ -0,0 +1,131 @@
+package lottest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/SchweizerischeBundesbahnen/lot/pkg/admission"
+	jsonpatch "github.com/evanphx/json-patch"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	cradmission "sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// AdmissionRequest returns the admission.Request the API server sends to the webhooks of the operator for the given
+// operation on obj. The old object is only used for update requests.
+func (e *Environment) AdmissionRequest(operation admissionv1.Operation, obj, old client.Object) (cradmission.Request, error) {
+	gvk, err := apiutil.GVKForObject(obj, e.manager.scheme)
+	if err != nil {
+		return cradmission.Request{}, err
+	}
+	mapping, err := e.manager.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return cradmission.Request{}, err
+	}
+	req := admissionv1.AdmissionRequest{
+		UID:       uuid.NewUUID(),
+		Kind:      metav1.GroupVersionKind{Group: gvk.Group, Version: gvk.Version, Kind: gvk.Kind},
+		Resource:  metav1.GroupVersionResource{Group: mapping.Resource.Group, Version: mapping.Resource.Version, Resource: mapping.Resource.Resource},
+		Name:      obj.GetName(),
+		Namespace: obj.GetNamespace(),
+		Operation: operation,
+	}
+	switch operation {
+	case admissionv1.Delete:
+		req.OldObject, err = rawExtension(obj, gvk)
+	case admissionv1.Update:
+		if req.Object, err = rawExtension(obj, gvk); err == nil {
+			req.OldObject, err = rawExtension(old, gvk)
+		}
+	default:
+		req.Object, err = rawExtension(obj, gvk)
+	}
+	return cradmission.Request{AdmissionRequest: req}, err
+}
+
+// Validate sends req to the validating webhook of the operator and returns its response
+func (e *Environment) Validate(ctx context.Context, req cradmission.Request) cradmission.Response {
+	return e.admit(ctx, admission.ValidatePath(requestGVK(req)), req)
+}
+
+// Mutate sends req to the mutating webhook of the operator and returns its response, see Mutated
+func (e *Environment) Mutate(ctx context.Context, req cradmission.Request) cradmission.Response {
+	return e.admit(ctx, admission.MutatePath(requestGVK(req)), req)
+}
+
+// Mutated applies the patch of the response of a mutating webhook to the object of req and decodes the result into obj
+func Mutated(req cradmission.Request, resp cradmission.Response, obj client.Object) error {
+	patch := resp.Patch
+	if len(patch) == 0 && len(resp.Patches) > 0 {
+		var err error
+		if patch, err = json.Marshal(resp.Patches); err != nil {
+			return err
+		}
+	}
+	mutated := req.Object.Raw
+	if len(patch) > 0 {
+		p, err := jsonpatch.DecodePatch(patch)
+		if err != nil {
+			return err
+		}
+		if mutated, err = p.Apply(req.Object.Raw); err != nil {
+			return err
+		}
+	}
+	return json.Unmarshal(mutated, obj)
+}
+
+// admit sends req to the webhook registered at path, like the API server does
+func (e *Environment) admit(ctx context.Context, path string, req cradmission.Request) cradmission.Response {
+	mux := e.manager.webhookServer.WebhookMux
+	if mux == nil {
+		return cradmission.Errored(http.StatusNotFound, fmt.Errorf("the operator has no webhooks"))
+	}
+	body, err := json.Marshal(admissionv1.AdmissionReview{
+		TypeMeta: metav1.TypeMeta{APIVersion: admissionv1.SchemeGroupVersion.String(), Kind: "AdmissionReview"},
+		Request:  &req.AdmissionRequest,
+	})
+	if err != nil {
+		return cradmission.Errored(http.StatusBadRequest, err)
+	}
+	r := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(body)).WithContext(ctx)
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		return cradmission.Errored(int32(w.Code), fmt.Errorf("webhook %s: %s", path, w.Body.String()))
+	}
+
+	var review admissionv1.AdmissionReview
+	if err := json.Unmarshal(w.Body.Bytes(), &review); err != nil {
+		return cradmission.Errored(http.StatusInternalServerError, err)
+	}
+	if review.Response == nil {
+		return cradmission.Errored(http.StatusInternalServerError, fmt.Errorf("webhook %s: no response", path))
+	}
+	return cradmission.Response{AdmissionResponse: *review.Response}
+}
+
+func requestGVK(req cradmission.Request) schema.GroupVersionKind {
+	return schema.GroupVersionKind{Group: req.Kind.Group, Version: req.Kind.Version, Kind: req.Kind.Kind}
+}
+
+// rawExtension encodes obj with its apiVersion and kind like the API server
+func rawExtension(obj client.Object, gvk schema.GroupVersionKind) (runtime.RawExtension, error) {
+	if obj == nil {
+		return runtime.RawExtension{}, nil
+	}
+	obj = obj.DeepCopyObject().(client.Object)
+	obj.GetObjectKind().SetGroupVersionKind(gvk)
+	raw, err := json.Marshal(obj)
+	return runtime.RawExtension{Raw: raw}, err
+}
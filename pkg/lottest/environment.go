@@ -0,0 +1,335 @@
+package lottest
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	lot_client "github.com/SchweizerischeBundesbahnen/lot/pkg/lot-client"
+	"github.com/SchweizerischeBundesbahnen/lot/pkg/operator"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta/testrestmapper"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllertest"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// EventType is the type of an event injected into an Environment
+type EventType string
+
+const (
+	Create EventType = "CREATE"
+	Update EventType = "UPDATE"
+	Delete EventType = "DELETE"
+)
+
+// Event is an event injected into an Environment together with the decision of the
+// operator's predicates
+type Event struct {
+	Type      EventType
+	Object    client.Object
+	OldObject client.Object
+	Accepted  bool
+}
+
+// Result is the outcome of a single reconcile run by an Environment
+type Result struct {
+	Request      types.NamespacedName
+	Requeue      bool
+	RequeueAfter time.Duration
+	Err          error
+}
+
+// Environment runs a LOT operator against a fake client. Events are injected by changing
+// objects through the Environment, which evaluates the operator's predicates and runs the
+// reconcile synchronously for accepted events. An Environment is not safe for concurrent use.
+type Environment struct {
+	client   lot_client.Client
+	manager  *fakeManager
+	operator operator.Operator
+	events   []Event
+	results  []Result
+	// clusters are the fake clusters added with WithCluster, keyed by their identifiers
+	clusters map[string]*fakeManager
+}
+
+// New creates an Environment whose fake cluster contains the given objects. If scheme is nil,
+// the client-go scheme containing all Kubernetes built-in types is used.
+func New(sch *runtime.Scheme, objs ...client.Object) *Environment {
+	if sch == nil {
+		sch = scheme.Scheme
+	}
+	cl := fake.NewClientBuilder().WithScheme(sch).WithObjects(objs...).Build()
+	return &Environment{
+		client:   lot_client.New(cl),
+		manager:  newFakeManager(cl, sch, testrestmapper.TestOnlyStaticRESTMapper(sch)),
+		clusters: map[string]*fakeManager{},
+	}
+}
+
+// WithManager returns the constructor option which has to be passed to operator.New
+// in order to create an operator in this Environment
+func (e *Environment) WithManager() operator.ConstructorOption {
+	return operator.WithManager(e.manager)
+}
+
+// WithCluster returns the constructor option which adds a fake cluster containing the given objects to an operator
+// created in this Environment, see operator.WithCluster. The objects of all clusters are reconciled with Reconcile,
+// and events injected by the Environment are reconciled in all clusters, each with the client of its cluster.
+func (e *Environment) WithCluster(id string, objs ...client.Object) operator.ConstructorOption {
+	cl := fake.NewClientBuilder().WithScheme(e.manager.scheme).WithObjects(objs...).Build()
+	e.clusters[id] = newFakeManager(cl, e.manager.scheme, e.manager.mapper)
+	return operator.WithCluster(id, e.clusters[id])
+}
+
+// ClusterClient returns the client of the fake cluster added with WithCluster, or nil if there is no such cluster
+func (e *Environment) ClusterClient(id string) lot_client.Client {
+	c, ok := e.clusters[id]
+	if !ok {
+		return nil
+	}
+	return lot_client.New(c.client)
+}
+
+// Started returns a channel which is closed once the manager of the Environment has been
+// started, e.g. by operator.StartWithContext, which builds the operator before
+func (e *Environment) Started() <-chan struct{} {
+	return e.manager.started
+}
+
+// Build builds the given operator, which has been created with the option returned by
+// WithManager, so that events injected afterwards are processed by it
+func (e *Environment) Build(o operator.Operator) error {
+	if err := o.Build(); err != nil {
+		return err
+	}
+	if len(e.manager.getControllers()) == 0 {
+		return fmt.Errorf("operator was not created with the manager of the environment, use WithManager()")
+	}
+	e.operator = o
+	return nil
+}
+
+// Client returns the client of the fake cluster
+func (e *Environment) Client() lot_client.Client {
+	return e.client
+}
+
+// Recorder returns the recorder which receives the Kubernetes events recorded with recorders
+// from the operator's manager
+func (e *Environment) Recorder() *record.FakeRecorder {
+	return e.manager.recorder
+}
+
+// SetElected controls whether the operator is the leader, which it is by default. In order to test
+// an operator which is not the leader, SetElected(false) has to be called before the operator is created.
+func (e *Environment) SetElected(elected bool) {
+	e.manager.setElected(elected)
+}
+
+// Create creates obj in the fake cluster and injects the resulting create event
+func (e *Environment) Create(ctx context.Context, obj client.Object) error {
+	if err := e.client.Create(ctx, obj); err != nil {
+		return err
+	}
+	return e.inject(ctx, Event{Type: Create, Object: copyOf(obj)})
+}
+
+// Update updates obj in the fake cluster and injects the resulting update event
+func (e *Environment) Update(ctx context.Context, obj client.Object) error {
+	old, err := e.current(ctx, obj)
+	if err != nil {
+		return err
+	}
+	if err := e.client.Update(ctx, obj); err != nil {
+		return err
+	}
+	return e.inject(ctx, Event{Type: Update, Object: copyOf(obj), OldObject: old})
+}
+
+// Delete deletes obj in the fake cluster and injects the resulting event. Like the API server,
+// the fake cluster only marks objects with finalizers as deleted, which results in an update event.
+func (e *Environment) Delete(ctx context.Context, obj client.Object) error {
+	old, err := e.current(ctx, obj)
+	if err != nil {
+		return err
+	}
+	if err := e.client.Delete(ctx, obj); err != nil {
+		return err
+	}
+	remaining, err := e.current(ctx, obj)
+	if err == nil {
+		return e.inject(ctx, Event{Type: Update, Object: remaining, OldObject: old})
+	}
+	if !apierrors.IsNotFound(err) {
+		return err
+	}
+	return e.inject(ctx, Event{Type: Delete, Object: old})
+}
+
+// Trigger sends a trigger with the given payload to the operator and runs the reconcile for the object,
+// as the operator would after the trigger has been enqueued
+func (e *Environment) Trigger(ctx context.Context, key types.NamespacedName, payload interface{}) (Result, error) {
+	if e.operator == nil {
+		return Result{Request: key}, fmt.Errorf("lottest: Build() has to be called before sending triggers")
+	}
+	if err := e.operator.Trigger(ctx, key, payload); err != nil {
+		return Result{Request: key}, err
+	}
+	return e.Reconcile(ctx, key), nil
+}
+
+// Reconcile runs the reconcile for the given object, regardless of any predicates. The objects enqueued by triggers in
+// the meantime, e.g. sent with Operator.Trigger or by the schedules of a started operator, are reconciled afterwards.
+func (e *Environment) Reconcile(ctx context.Context, key types.NamespacedName) Result {
+	enqueued := e.enqueuedTriggers()
+	result := e.reconcile(ctx, key)
+	for _, k := range enqueued {
+		if k != key {
+			e.reconcile(ctx, k)
+		}
+	}
+	return result
+}
+
+// enqueuedTriggers consumes the objects enqueued by triggers and returns their keys once each
+func (e *Environment) enqueuedTriggers() []types.NamespacedName {
+	events := e.manager.getTriggers()
+	var keys []types.NamespacedName
+	seen := map[types.NamespacedName]bool{}
+	for {
+		select {
+		case evt := <-events:
+			key := client.ObjectKeyFromObject(evt.Object)
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		default:
+			return keys
+		}
+	}
+}
+
+// reconcile runs the reconcile of all controllers for the given object
+func (e *Environment) reconcile(ctx context.Context, key types.NamespacedName) Result {
+	request := reconcile.Request{NamespacedName: key}
+	result := Result{Request: key}
+	for _, c := range e.manager.getControllers() {
+		r, err := c.Reconcile(ctx, request)
+		result.Requeue = result.Requeue || r.Requeue
+		if r.RequeueAfter > 0 && (result.RequeueAfter == 0 || r.RequeueAfter < result.RequeueAfter) {
+			result.RequeueAfter = r.RequeueAfter
+		}
+		if err != nil {
+			result.Err = err
+		}
+	}
+	e.results = append(e.results, result)
+	return result
+}
+
+// Events returns all events injected so far
+func (e *Environment) Events() []Event {
+	return e.events
+}
+
+// Results returns the results of all reconciles run so far
+func (e *Environment) Results() []Result {
+	return e.results
+}
+
+// Requeues returns the results of all reconciles which requested a requeue or failed
+func (e *Environment) Requeues() []Result {
+	var requeues []Result
+	for _, r := range e.results {
+		if r.Requeue || r.RequeueAfter > 0 || r.Err != nil {
+			requeues = append(requeues, r)
+		}
+	}
+	return requeues
+}
+
+// Reset forgets all events and results, but keeps the state of the fake cluster
+func (e *Environment) Reset() {
+	e.events = nil
+	e.results = nil
+}
+
+// inject evaluates the predicates of the watches of the operator for the kind of the object, and reconciles the requests
+// the event is mapped to if it is accepted: the object itself for the primary resource, and the owner for owned
+// resources. Events of kinds which are not watched are ignored.
+func (e *Environment) inject(ctx context.Context, evt Event) error {
+	if len(e.manager.getWatches()) == 0 {
+		return fmt.Errorf("lottest: Build() has to be called or the operator has to be started before injecting events")
+	}
+	gvk, _ := apiutil.GVKForObject(evt.Object, e.manager.scheme)
+	q := controllertest.Queue{Interface: workqueue.New()}
+	defer q.ShutDown()
+	for _, w := range e.manager.getWatches() {
+		if watched, err := apiutil.GVKForObject(w.object, e.manager.scheme); err != nil || gvk.Empty() || watched != gvk {
+			continue
+		}
+		if accepted(w.predicates, evt) {
+			evt.Accepted = true
+			switch evt.Type {
+			case Create:
+				w.handler.Create(event.CreateEvent{Object: evt.Object}, q)
+			case Update:
+				w.handler.Update(event.UpdateEvent{ObjectOld: evt.OldObject, ObjectNew: evt.Object}, q)
+			case Delete:
+				w.handler.Delete(event.DeleteEvent{Object: evt.Object}, q)
+			}
+		}
+	}
+	e.events = append(e.events, evt)
+	for q.Len() > 0 {
+		item, _ := q.Get()
+		q.Done(item)
+		if request, ok := item.(reconcile.Request); ok {
+			e.Reconcile(ctx, request.NamespacedName)
+		}
+	}
+	return nil
+}
+
+// accepted returns true if the event passes all predicates, which are evaluated in order like by the controller
+func accepted(prcts []predicate.Predicate, evt Event) bool {
+	for _, p := range prcts {
+		var ok bool
+		switch evt.Type {
+		case Create:
+			ok = p.Create(event.CreateEvent{Object: evt.Object})
+		case Update:
+			ok = p.Update(event.UpdateEvent{ObjectOld: evt.OldObject, ObjectNew: evt.Object})
+		case Delete:
+			ok = p.Delete(event.DeleteEvent{Object: evt.Object})
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// current reads the stored state of obj into a new object
+func (e *Environment) current(ctx context.Context, obj client.Object) (client.Object, error) {
+	current := copyOf(obj)
+	if err := e.client.Get(ctx, client.ObjectKeyFromObject(obj), current); err != nil {
+		return nil, err
+	}
+	return current, nil
+}
+
+func copyOf(obj client.Object) client.Object {
+	return obj.DeepCopyObject().(client.Object)
+}
@@ -0,0 +1,222 @@
+package lottest_test
+
+import (
+	"context"
+	"time"
+
+	lotClient "github.com/SchweizerischeBundesbahnen/lot/pkg/lot-client"
+	"github.com/SchweizerischeBundesbahnen/lot/pkg/lottest"
+	"github.com/SchweizerischeBundesbahnen/lot/pkg/operator"
+	"github.com/SchweizerischeBundesbahnen/lot/pkg/ownership"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+var _ = Describe("Environment", func() {
+	var env *lottest.Environment
+	var o operator.Operator
+	var ctx context.Context
+	var secret *v1.Secret
+	var handled []string
+	var awaitPod bool
+	BeforeEach(func() {
+		ctx = context.Background()
+		handled = nil
+		awaitPod = false
+		secret = &v1.Secret{ObjectMeta: metav1.ObjectMeta{
+			Namespace: "biz",
+			Name:      "baz",
+			Labels:    map[string]string{"foo": "bar"},
+		}}
+
+		env = lottest.New(nil)
+		var err error
+		o, err = operator.New(&v1.Secret{}, env.WithManager())
+		Expect(err).ToNot(HaveOccurred())
+		o.OnCreateOrUpdate(func(ctx context.Context, object client.Object, cl lotClient.Client, scheme *runtime.Scheme) error {
+			handled = append(handled, "createOrUpdate")
+			cm := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: object.GetNamespace(), Name: object.GetName()}}
+			_, err := cl.CreateOrPatch(ctx, cm, func() error {
+				cm.Data = map[string]string{"source": object.GetName()}
+				return nil
+			})
+			if err != nil || !awaitPod {
+				return err
+			}
+			pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: object.GetNamespace(), Name: object.GetName()}}
+			if err := cl.Create(ctx, pod); err != nil {
+				return err
+			}
+			return cl.Ready(ctx, pod, time.Minute)
+		}, operator.WithLabels(map[string]string{"foo": "bar"}))
+	})
+	JustBeforeEach(func() {
+		if o != nil {
+			Expect(env.Build(o)).To(Succeed())
+		}
+	})
+
+	It("should reconcile accepted events", func() {
+		Expect(env.Create(ctx, secret)).To(Succeed())
+		Expect(env.Events()).To(ConsistOf(lottest.BeAccepted()))
+		Expect(handled).To(Equal([]string{"createOrUpdate"}))
+		Expect(env.Results()).To(HaveLen(1))
+		Expect(env.Requeues()).To(BeEmpty())
+
+		cm := &v1.ConfigMap{}
+		Expect(env.Client().Get(ctx, client.ObjectKeyFromObject(secret), cm)).To(Succeed())
+		Expect(cm.Data).To(HaveKeyWithValue("source", "baz"))
+	})
+
+	It("should not reconcile ignored events", func() {
+		secret.Labels = nil
+		Expect(env.Create(ctx, secret)).To(Succeed())
+		Expect(env.Events()).To(ConsistOf(lottest.BeIgnored()))
+		Expect(handled).To(BeEmpty())
+		Expect(env.Results()).To(BeEmpty())
+	})
+
+	It("should ignore events of kinds the operator does not watch", func() {
+		cm := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "biz", Name: "baz", Labels: map[string]string{"foo": "bar"}}}
+		Expect(env.Create(ctx, cm)).To(Succeed())
+		Expect(env.Events()).To(ConsistOf(lottest.BeIgnored()))
+		Expect(handled).To(BeEmpty())
+		Expect(env.Results()).To(BeEmpty())
+	})
+
+	Context("with owned kinds", func() {
+		BeforeEach(func() {
+			var err error
+			o, err = operator.New(&v1.Secret{}, env.WithManager(),
+				operator.WithOwns(&v1.ConfigMap{}, predicate.Funcs{}), operator.WithTrackedOwns(&v1.Pod{}, predicate.Funcs{}))
+			Expect(err).ToNot(HaveOccurred())
+			o.OnCreateOrUpdate(func(ctx context.Context, object client.Object, cl lotClient.Client, scheme *runtime.Scheme) error {
+				handled = append(handled, object.GetName())
+				return nil
+			})
+			secret.UID = "uid-1"
+			Expect(env.Client().Create(ctx, secret)).To(Succeed())
+		})
+
+		It("should reconcile the owner of owned objects", func() {
+			cm := &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "biz", Name: "child"}}
+			Expect(controllerutil.SetControllerReference(secret, cm, scheme.Scheme)).To(Succeed())
+			Expect(env.Create(ctx, cm)).To(Succeed())
+
+			pod := &v1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "other", Name: "child"}}
+			Expect(ownership.SetOwner(secret, pod, scheme.Scheme)).To(Succeed())
+			Expect(env.Create(ctx, pod)).To(Succeed())
+
+			Expect(env.Events()).To(ConsistOf(lottest.BeAccepted(), lottest.BeAccepted()))
+			Expect(env.Results()).To(ConsistOf(
+				HaveField("Request", client.ObjectKeyFromObject(secret)),
+				HaveField("Request", client.ObjectKeyFromObject(secret))))
+			Expect(handled).To(Equal([]string{"baz", "baz"}))
+		})
+
+		It("should ignore objects without an owner", func() {
+			Expect(env.Create(ctx, &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "biz", Name: "orphan"}})).To(Succeed())
+			Expect(env.Results()).To(BeEmpty())
+		})
+	})
+
+	It("should inject update events with the previous state", func() {
+		Expect(env.Create(ctx, secret)).To(Succeed())
+		env.Reset()
+
+		secret.Labels = map[string]string{"foo": "other"}
+		Expect(env.Update(ctx, secret)).To(Succeed())
+		Expect(env.Events()).To(HaveLen(1))
+		evt := env.Events()[0]
+		Expect(evt.Type).To(Equal(lottest.Update))
+		Expect(evt.OldObject.GetLabels()).To(HaveKeyWithValue("foo", "bar"))
+		Expect(evt.Object.GetLabels()).To(HaveKeyWithValue("foo", "other"))
+		Expect(evt).To(lottest.BeAccepted())
+	})
+
+	It("should inject an update event when deleting an object with finalizers", func() {
+		secret.Finalizers = []string{"lot.sbb.ch/test"}
+		Expect(env.Create(ctx, secret)).To(Succeed())
+		env.Reset()
+
+		Expect(env.Delete(ctx, secret)).To(Succeed())
+		Expect(env.Events()).To(HaveLen(1))
+		Expect(env.Events()[0].Type).To(Equal(lottest.Update))
+		Expect(env.Events()[0].Object.GetDeletionTimestamp()).ToNot(BeNil())
+	})
+
+	It("should inject a delete event when deleting an object without finalizers", func() {
+		Expect(env.Create(ctx, secret)).To(Succeed())
+		env.Reset()
+
+		Expect(env.Delete(ctx, secret)).To(Succeed())
+		Expect(env.Events()).To(HaveLen(1))
+		Expect(env.Events()[0].Type).To(Equal(lottest.Delete))
+		Expect(env.Events()[0]).To(lottest.BeIgnored())
+	})
+
+	Context("with a handler requesting a requeue", func() {
+		BeforeEach(func() {
+			awaitPod = true
+		})
+		It("should expose the requeue", func() {
+			Expect(env.Create(ctx, secret)).To(Succeed())
+			Expect(env.Requeues()).To(ConsistOf(lottest.HaveRequeuedAfter(time.Minute)))
+			Expect(env.Requeues()).To(ConsistOf(lottest.HaveRequeued()))
+			Expect(env.Requeues()).ToNot(ContainElement(lottest.HaveFailed()))
+		})
+	})
+
+	Context("with triggers", func() {
+		BeforeEach(func() {
+			var err error
+			o, err = operator.New(&v1.Secret{}, env.WithManager())
+			Expect(err).ToNot(HaveOccurred())
+			o.OnTrigger(func(ctx context.Context, object client.Object, cl lotClient.Client, scheme *runtime.Scheme) error {
+				handled = append(handled, object.GetName())
+				return nil
+			})
+			Expect(env.Client().Create(ctx, secret)).To(Succeed())
+		})
+
+		It("should consume more triggers than the operator buffers", func() {
+			for i := 0; i < 2000; i++ {
+				_, err := env.Trigger(ctx, client.ObjectKeyFromObject(secret), i)
+				Expect(err).NotTo(HaveOccurred())
+			}
+			Expect(handled).To(HaveLen(2000))
+		})
+
+		It("should reconcile the objects of triggers sent to the operator", func() {
+			other := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "biz", Name: "other"}}
+			Expect(env.Client().Create(ctx, other)).To(Succeed())
+			Expect(o.Trigger(ctx, client.ObjectKeyFromObject(other), nil)).To(Succeed())
+			env.Reconcile(ctx, client.ObjectKeyFromObject(secret))
+			Expect(handled).To(Equal([]string{"other"}))
+			Expect(env.Results()).To(HaveLen(2))
+		})
+	})
+
+	Context("without a built operator", func() {
+		BeforeEach(func() {
+			o = nil
+		})
+		It("should fail to inject events and send triggers", func() {
+			Expect(env.Create(ctx, secret)).To(MatchError(ContainSubstring("Build() has to be called")))
+			_, err := env.Trigger(ctx, client.ObjectKeyFromObject(secret), nil)
+			Expect(err).To(MatchError(ContainSubstring("Build() has to be called")))
+		})
+	})
+
+	It("should reject operators created without the environment's manager", func() {
+		other := lottest.New(nil)
+		Expect(other.Build(o)).ToNot(Succeed())
+	})
+})
@@ -0,0 +1,197 @@
+package lottest
+
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/cache/informertest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/config/v1alpha1"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/runtime/inject"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+var _ manager.Manager = &fakeManager{}
+
+// fakeManager is a manager.Manager backed by a fake client. It never starts any controllers,
+// but keeps them so that the Environment can call them directly.
+type fakeManager struct {
+	client        client.Client
+	scheme        *runtime.Scheme
+	mapper        meta.RESTMapper
+	cache         cache.Cache
+	recorder      *record.FakeRecorder
+	webhookServer *webhook.Server
+	elected       chan struct{}
+	// mu guards the controllers, watches and runnables, which may be added while the manager is running
+	mu          sync.Mutex
+	controllers []controller.Controller
+	watches     []watch
+	triggers    <-chan event.GenericEvent
+	runnables   []manager.Runnable
+	started     chan struct{}
+	startOnce   sync.Once
+}
+
+func newFakeManager(cl client.Client, scheme *runtime.Scheme, mapper meta.RESTMapper) *fakeManager {
+	elected := make(chan struct{})
+	close(elected)
+	return &fakeManager{
+		client:        cl,
+		scheme:        scheme,
+		mapper:        mapper,
+		cache:         &informertest.FakeInformers{Scheme: scheme},
+		recorder:      record.NewFakeRecorder(1024),
+		webhookServer: &webhook.Server{},
+		elected:       elected,
+		started:       make(chan struct{}),
+	}
+}
+
+// watch is a kind watched by a controller, whose events are mapped to requests by the handler if they pass the predicates
+type watch struct {
+	object     client.Object
+	handler    handler.EventHandler
+	predicates []predicate.Predicate
+}
+
+// SetFields injects the scheme and the RESTMapper, e.g. into the handlers mapping owned objects to their owners
+func (m *fakeManager) SetFields(i interface{}) error {
+	if _, err := inject.SchemeInto(m.scheme, i); err != nil {
+		return err
+	}
+	_, err := inject.MapperInto(m.mapper, i)
+	return err
+}
+
+// RecordWatch keeps a kind watched by a controller of the operator, so that the Environment can route its events
+func (m *fakeManager) RecordWatch(obj client.Object, hdl handler.EventHandler, prct ...predicate.Predicate) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.watches = append(m.watches, watch{object: obj, handler: hdl, predicates: prct})
+}
+
+// RecordTriggers keeps the channel the operator enqueues the objects of triggers with, so that the Environment can
+// consume them
+func (m *fakeManager) RecordTriggers(events <-chan event.GenericEvent) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.triggers = events
+}
+
+// getTriggers returns the channel recorded with RecordTriggers, or nil
+func (m *fakeManager) getTriggers() <-chan event.GenericEvent {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.triggers
+}
+
+func (m *fakeManager) GetConfig() *rest.Config { return &rest.Config{} }
+
+func (m *fakeManager) GetScheme() *runtime.Scheme { return m.scheme }
+
+func (m *fakeManager) GetClient() client.Client { return m.client }
+
+func (m *fakeManager) GetFieldIndexer() client.FieldIndexer { return m.cache }
+
+func (m *fakeManager) GetCache() cache.Cache { return m.cache }
+
+func (m *fakeManager) GetEventRecorderFor(string) record.EventRecorder { return m.recorder }
+
+func (m *fakeManager) GetRESTMapper() meta.RESTMapper { return m.mapper }
+
+func (m *fakeManager) GetAPIReader() client.Reader { return m.client }
+
+func (m *fakeManager) Add(r manager.Runnable) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if c, ok := r.(controller.Controller); ok {
+		m.controllers = append(m.controllers, c)
+		return nil
+	}
+	m.runnables = append(m.runnables, r)
+	return nil
+}
+
+// getControllers returns the controllers added so far
+func (m *fakeManager) getControllers() []controller.Controller {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]controller.Controller(nil), m.controllers...)
+}
+
+// getWatches returns the watches recorded so far
+func (m *fakeManager) getWatches() []watch {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]watch(nil), m.watches...)
+}
+
+func (m *fakeManager) Elected() <-chan struct{} { return m.elected }
+
+// setElected closes the elected channel or replaces it with a new open one
+func (m *fakeManager) setElected(elected bool) {
+	select {
+	case <-m.elected:
+		if !elected {
+			m.elected = make(chan struct{})
+		}
+	default:
+		if elected {
+			close(m.elected)
+		}
+	}
+}
+
+func (m *fakeManager) AddMetricsExtraHandler(string, http.Handler) error { return nil }
+
+func (m *fakeManager) AddHealthzCheck(string, healthz.Checker) error { return nil }
+
+func (m *fakeManager) AddReadyzCheck(string, healthz.Checker) error { return nil }
+
+// Start starts the runnables which are not controllers, e.g. the schedulers of an operator, and blocks until
+// the context is done or a runnable fails. Events are processed by the Environment instead of the controllers.
+func (m *fakeManager) Start(ctx context.Context) error {
+	m.mu.Lock()
+	runnables := append([]manager.Runnable(nil), m.runnables...)
+	m.mu.Unlock()
+	errs := make(chan error, len(runnables))
+	for _, r := range runnables {
+		go func(r manager.Runnable) {
+			errs <- r.Start(ctx)
+		}(r)
+	}
+	m.startOnce.Do(func() { close(m.started) })
+	for {
+		select {
+		case err := <-errs:
+			if err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+func (m *fakeManager) GetWebhookServer() *webhook.Server { return m.webhookServer }
+
+func (m *fakeManager) GetLogger() logr.Logger { return logf.Log }
+
+func (m *fakeManager) GetControllerOptions() v1alpha1.ControllerConfigurationSpec {
+	return v1alpha1.ControllerConfigurationSpec{}
+}
@@ -0,0 +1,34 @@
+package lottest
+
+import (
+	"github.com/onsi/gomega"
+	"github.com/onsi/gomega/types"
+)
+
+// BeAccepted succeeds if an Event was accepted by the operator's predicates
+func BeAccepted() types.GomegaMatcher {
+	return gomega.HaveField("Accepted", gomega.BeTrue())
+}
+
+// BeIgnored succeeds if an Event was rejected by the operator's predicates
+func BeIgnored() types.GomegaMatcher {
+	return gomega.HaveField("Accepted", gomega.BeFalse())
+}
+
+// HaveRequeued succeeds if a Result requested a requeue, either immediately or after a delay
+func HaveRequeued() types.GomegaMatcher {
+	return gomega.Or(
+		gomega.HaveField("Requeue", gomega.BeTrue()),
+		gomega.HaveField("RequeueAfter", gomega.BeNumerically(">", 0)),
+	)
+}
+
+// HaveRequeuedAfter succeeds if a Result requested a requeue after the given delay
+func HaveRequeuedAfter(after interface{}) types.GomegaMatcher {
+	return gomega.HaveField("RequeueAfter", gomega.BeEquivalentTo(after))
+}
+
+// HaveFailed succeeds if the reconcile of a Result returned an error
+func HaveFailed() types.GomegaMatcher {
+	return gomega.HaveField("Err", gomega.HaveOccurred())
+}
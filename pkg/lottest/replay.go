@@ -0,0 +1,146 @@
+package lottest
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/SchweizerischeBundesbahnen/lot/pkg/predicates"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ReplayFile replays the events recorded with operator.WithEventRecording from the file at path.
+// See Replay for details.
+func (e *Environment) ReplayFile(ctx context.Context, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return e.Replay(ctx, f)
+}
+
+// Replay feeds the events recorded with operator.WithEventRecording back through the operator of the
+// Environment. Before an event is injected, the fake cluster is brought into the recorded state of the
+// object, so that the handlers see the same objects as the recording operator. The predicates are
+// evaluated again, which allows to compare the decisions with the recorded ones.
+func (e *Environment) Replay(ctx context.Context, r io.Reader) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var recorded predicates.RecordedEvent
+		if err := json.Unmarshal(scanner.Bytes(), &recorded); err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+		if err := e.replay(ctx, recorded); err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+	}
+	return scanner.Err()
+}
+
+func (e *Environment) replay(ctx context.Context, recorded predicates.RecordedEvent) error {
+	if recorded.Object == nil {
+		return fmt.Errorf("%s event without object", recorded.Type)
+	}
+	obj, err := e.toObject(recorded.Object)
+	if err != nil {
+		return err
+	}
+
+	switch EventType(recorded.Type) {
+	case Create:
+		if err := e.store(ctx, obj); err != nil {
+			return err
+		}
+		if err := e.inject(ctx, Event{Type: Create, Object: obj}); err != nil {
+			return err
+		}
+	case Update:
+		if recorded.OldObject == nil {
+			return fmt.Errorf("%s event without old object", recorded.Type)
+		}
+		old, err := e.toObject(recorded.OldObject)
+		if err != nil {
+			return err
+		}
+		if err := e.store(ctx, obj); err != nil {
+			return err
+		}
+		if err := e.inject(ctx, Event{Type: Update, Object: obj, OldObject: old}); err != nil {
+			return err
+		}
+	case Delete:
+		if err := e.remove(ctx, obj); err != nil {
+			return err
+		}
+		if err := e.inject(ctx, Event{Type: Delete, Object: obj}); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unsupported event type %q", recorded.Type)
+	}
+	return nil
+}
+
+// toObject converts a recorded object into a typed object if its kind is known to the scheme
+func (e *Environment) toObject(u *unstructured.Unstructured) (client.Object, error) {
+	gvk := u.GroupVersionKind()
+	if !e.manager.scheme.Recognizes(gvk) {
+		return u.DeepCopy(), nil
+	}
+	typed, err := e.manager.scheme.New(gvk)
+	if err != nil {
+		return nil, err
+	}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, typed); err != nil {
+		return nil, err
+	}
+	typed.GetObjectKind().SetGroupVersionKind(gvk)
+	return typed.(client.Object), nil
+}
+
+// store creates or updates obj in the fake cluster with the recorded state. The recorded resource
+// version is replaced, as it is meaningless for the fake cluster.
+func (e *Environment) store(ctx context.Context, obj client.Object) error {
+	stored := copyOf(obj)
+	current, err := e.current(ctx, obj)
+	if apierrors.IsNotFound(err) {
+		stored.SetResourceVersion("")
+		return e.client.Create(ctx, stored)
+	}
+	if err != nil {
+		return err
+	}
+	stored.SetResourceVersion(current.GetResourceVersion())
+	return e.client.Update(ctx, stored)
+}
+
+// remove deletes obj from the fake cluster, regardless of its finalizers
+func (e *Environment) remove(ctx context.Context, obj client.Object) error {
+	current, err := e.current(ctx, obj)
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if len(current.GetFinalizers()) > 0 {
+		current.SetFinalizers(nil)
+		if err := e.client.Update(ctx, current); err != nil {
+			return client.IgnoreNotFound(err)
+		}
+	}
+	return client.IgnoreNotFound(e.client.Delete(ctx, current))
+}
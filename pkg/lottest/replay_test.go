@@ -0,0 +1,88 @@
+package lottest_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	lotClient "github.com/SchweizerischeBundesbahnen/lot/pkg/lot-client"
+	"github.com/SchweizerischeBundesbahnen/lot/pkg/lottest"
+	"github.com/SchweizerischeBundesbahnen/lot/pkg/operator"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("Replay", func() {
+	var ctx context.Context
+	var path string
+	var handled []string
+	newOperator := func(env *lottest.Environment, opts ...operator.ConstructorOption) operator.Operator {
+		o, err := operator.New(&v1.Secret{}, append(opts, env.WithManager())...)
+		Expect(err).ToNot(HaveOccurred())
+		o.OnCreateOrUpdate(func(ctx context.Context, object client.Object, cl lotClient.Client, scheme *runtime.Scheme) error {
+			handled = append(handled, object.GetName()+"="+object.GetLabels()["foo"])
+			return nil
+		}, operator.WithLabels(map[string]string{"foo": "bar"}))
+		return o
+	}
+	BeforeEach(func() {
+		ctx = context.Background()
+		handled = nil
+		path = filepath.Join(GinkgoT().TempDir(), "events.jsonl")
+
+		By("recording events of an operator")
+		env := lottest.New(nil)
+		o := newOperator(env, operator.WithEventRecording(path))
+		// the recording is only written while the operator is running
+		runCtx, cancel := context.WithCancel(ctx)
+		stopped := make(chan error, 1)
+		go func() {
+			stopped <- o.StartWithContext(runCtx)
+		}()
+		Eventually(env.Started()).Should(BeClosed())
+		secret := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "biz", Name: "baz", Labels: map[string]string{"foo": "bar"}}}
+		Expect(env.Create(ctx, secret)).To(Succeed())
+		ignored := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "biz", Name: "other"}}
+		Expect(env.Create(ctx, ignored)).To(Succeed())
+		secret.Labels["foo"] = "baz"
+		Expect(env.Update(ctx, secret)).To(Succeed())
+		Expect(env.Delete(ctx, secret)).To(Succeed())
+		// the update removing the label passes the predicate, but the handler is not run for it
+		Expect(handled).To(Equal([]string{"baz=bar"}))
+		handled = nil
+		cancel()
+		Eventually(stopped).Should(Receive(BeNil()))
+	})
+
+	It("should feed the recorded events back through the operator", func() {
+		env := lottest.New(nil)
+		Expect(env.Build(newOperator(env))).To(Succeed())
+		Expect(env.ReplayFile(ctx, path)).To(Succeed())
+
+		Expect(handled).To(Equal([]string{"baz=bar"}))
+		events := env.Events()
+		Expect(events).To(HaveLen(4))
+		Expect(events[0]).To(lottest.BeAccepted())
+		Expect(events[1]).To(lottest.BeIgnored())
+		Expect(events[2].Type).To(Equal(lottest.Update))
+		Expect(events[2].OldObject.GetLabels()).To(HaveKeyWithValue("foo", "bar"))
+		Expect(events[3].Type).To(Equal(lottest.Delete))
+
+		By("bringing the fake cluster into the recorded state")
+		Expect(env.Client().Get(ctx, client.ObjectKey{Namespace: "biz", Name: "other"}, &v1.Secret{})).To(Succeed())
+		err := env.Client().Get(ctx, client.ObjectKey{Namespace: "biz", Name: "baz"}, &v1.Secret{})
+		Expect(client.IgnoreNotFound(err)).To(Succeed())
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("should fail for malformed recordings", func() {
+		Expect(os.WriteFile(path, []byte("{not json}\n"), 0o600)).To(Succeed())
+		env := lottest.New(nil)
+		Expect(env.Build(newOperator(env))).To(Succeed())
+		Expect(env.ReplayFile(ctx, path)).ToNot(Succeed())
+	})
+})
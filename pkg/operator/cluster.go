@@ -0,0 +1,45 @@
+package operator
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/SchweizerischeBundesbahnen/lot/internal/defaults"
+	lot_client "github.com/SchweizerischeBundesbahnen/lot/pkg/lot-client"
+	"sigs.k8s.io/controller-runtime/pkg/cluster"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+// remoteCluster is a cluster reconciled in addition to the cluster of the manager, with its own client and cache
+type remoteCluster struct {
+	id      string
+	cluster cluster.Cluster
+	client  lot_client.Client
+}
+
+// initClusters creates the clusters given with WithClusters and returns them with the ones given with WithCluster,
+// sorted by their identifiers. The clusters use the scheme of the manager.
+func initClusters(mgr manager.Manager, options constructorOptions, clientOpts []lot_client.Option) ([]remoteCluster, error) {
+	clusters := append([]remoteCluster(nil), options.clusters...)
+	for id, config := range options.clusterSet {
+		// the caches of the clusters are restricted to the same namespaces as the cache of the manager
+		opts := cluster.Options{Scheme: mgr.GetScheme()}
+		if options.mgrOpts != nil {
+			opts.Namespace, opts.NewCache = options.mgrOpts.Namespace, options.mgrOpts.NewCache
+		}
+		c, err := defaults.InitCluster(config, opts)
+		if err != nil {
+			return nil, fmt.Errorf("unable to create cluster %q: %w", id, err)
+		}
+		clusters = append(clusters, remoteCluster{id: id, cluster: c})
+	}
+	sort.Slice(clusters, func(i, j int) bool { return clusters[i].id < clusters[j].id })
+
+	for i := range clusters {
+		if i > 0 && clusters[i].id == clusters[i-1].id {
+			return nil, fmt.Errorf("duplicate cluster %q", clusters[i].id)
+		}
+		clusters[i].client = lot_client.New(clusters[i].cluster.GetClient(), clientOpts...)
+	}
+	return clusters, nil
+}
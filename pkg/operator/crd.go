@@ -0,0 +1,170 @@
+package operator
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+var crdGVK = schema.GroupVersionKind{Group: "apiextensions.k8s.io", Version: "v1", Kind: "CustomResourceDefinition"}
+
+const (
+	// crdEstablishedTimeout is the time installed CRDs are given to be established before the operator fails to start
+	crdEstablishedTimeout = time.Minute
+	crdPollInterval       = 500 * time.Millisecond
+)
+
+// withScheme returns the manager options with a scheme containing the Kubernetes built-in types and the types added
+// with WithScheme, or adds them to the scheme of the manager given with WithManager
+func withScheme(mgrOpts *manager.Options, options constructorOptions) (*manager.Options, error) {
+	if len(options.addToScheme) == 0 {
+		return mgrOpts, nil
+	}
+	if options.mgr != nil {
+		return mgrOpts, addToScheme(options.mgr.GetScheme(), options.addToScheme)
+	}
+
+	opts := manager.Options{}
+	if mgrOpts != nil {
+		opts = *mgrOpts
+	}
+	if opts.Scheme == nil {
+		opts.Scheme = runtime.NewScheme()
+		if err := clientgoscheme.AddToScheme(opts.Scheme); err != nil {
+			return nil, err
+		}
+	}
+	return &opts, addToScheme(opts.Scheme, options.addToScheme)
+}
+
+func addToScheme(scheme *runtime.Scheme, funcs []func(*runtime.Scheme) error) error {
+	for _, fn := range funcs {
+		if err := fn(scheme); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decodeCRDs decodes the CustomResourceDefinitions of YAML manifests, which may contain several documents
+func decodeCRDs(manifests [][]byte) ([]*unstructured.Unstructured, error) {
+	var crds []*unstructured.Unstructured
+	for _, manifest := range manifests {
+		reader := yaml.NewYAMLReader(bufio.NewReader(bytes.NewReader(manifest)))
+		for {
+			doc, err := reader.Read()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			if len(bytes.TrimSpace(doc)) == 0 {
+				continue
+			}
+			crd := &unstructured.Unstructured{}
+			if err := yaml.Unmarshal(doc, &crd.Object); err != nil {
+				return nil, err
+			}
+			if len(crd.Object) == 0 {
+				continue
+			}
+			if crd.GroupVersionKind() != crdGVK {
+				return nil, fmt.Errorf("expected a %s, got %s %q", crdGVK.Kind, crd.GroupVersionKind(), crd.GetName())
+			}
+			crds = append(crds, crd)
+		}
+	}
+	return crds, nil
+}
+
+// installCRDs creates or updates the CRDs given with WithCRDs and waits until they are established, so that the
+// controller can watch the custom resources once it is started. As every replica installs the CRDs at startup, a CRD
+// created or updated by another replica in the meantime is read again and updated. With WithDryRun, the CRDs are only
+// updated with the dry-run option, so the operator fails to start right away if a CRD is not installed yet.
+func (o *operator) installCRDs(ctx context.Context) error {
+	if len(o.crds) == 0 {
+		return nil
+	}
+	reader := o.manager.GetAPIReader()
+	for _, crd := range o.crds {
+		err := retry.OnError(retry.DefaultRetry, func(err error) bool {
+			return apierrors.IsAlreadyExists(err) || apierrors.IsConflict(err)
+		}, func() error {
+			return o.installCRD(ctx, reader, crd)
+		})
+		if err != nil {
+			return fmt.Errorf("unable to install CRD %q: %w", crd.GetName(), err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, crdEstablishedTimeout)
+	defer cancel()
+	for _, crd := range o.crds {
+		err := wait.PollImmediateUntilWithContext(ctx, crdPollInterval, func(ctx context.Context) (bool, error) {
+			current := &unstructured.Unstructured{}
+			current.SetGroupVersionKind(crdGVK)
+			if err := reader.Get(ctx, client.ObjectKeyFromObject(crd), current); err != nil {
+				return false, client.IgnoreNotFound(err)
+			}
+			return established(current), nil
+		})
+		if err != nil {
+			return fmt.Errorf("CRD %q has not been established: %w", crd.GetName(), err)
+		}
+	}
+	return nil
+}
+
+// installCRD creates the CRD, or updates it to the manifest if it exists
+func (o *operator) installCRD(ctx context.Context, reader client.Reader, crd *unstructured.Unstructured) error {
+	log := logf.Log.WithName("CRDs")
+	desired := crd.DeepCopy()
+	current := &unstructured.Unstructured{}
+	current.SetGroupVersionKind(crdGVK)
+	err := reader.Get(ctx, client.ObjectKeyFromObject(desired), current)
+	if apierrors.IsNotFound(err) && o.dryRun {
+		return fmt.Errorf("the CRD is not installed, which is not done with WithDryRun()")
+	}
+	if apierrors.IsNotFound(err) {
+		log.Info("Installing CRD", "name", desired.GetName())
+		return o.client.Create(ctx, desired)
+	}
+	if err != nil {
+		return err
+	}
+	log.Info("Updating CRD", "name", desired.GetName())
+	desired.SetResourceVersion(current.GetResourceVersion())
+	if status, found := current.Object["status"]; found {
+		desired.Object["status"] = status
+	}
+	return o.client.Update(ctx, desired)
+}
+
+// established returns true if the CRD has the condition Established
+func established(crd *unstructured.Unstructured) bool {
+	conditions, _, _ := unstructured.NestedSlice(crd.Object, "status", "conditions")
+	for _, c := range conditions {
+		condition, ok := c.(map[string]interface{})
+		if ok && condition["type"] == "Established" && condition["status"] == "True" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,117 @@
+package operator
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/SchweizerischeBundesbahnen/lot/pkg/reconcile"
+	"k8s.io/apimachinery/pkg/types"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	crreconcile "sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// abandonGracePeriod is the time given to handlers to return after their context has been cancelled
+// at the end of the drain timeout
+const abandonGracePeriod = 5 * time.Second
+
+// drainer keeps track of the reconciles in flight and provides them with a context which is not
+// cancelled on shutdown, but only once the drain timeout has passed. It is shared by the controllers
+// of all clusters and kinds, so the reconciles in flight are tracked per controller.
+type drainer struct {
+	mu       sync.Mutex
+	ctx      context.Context
+	cancel   context.CancelFunc
+	inflight map[inflightKey]time.Time
+}
+
+// inflightKey identifies a reconcile in flight by its controller and object
+type inflightKey struct {
+	controller string
+	object     types.NamespacedName
+}
+
+func newDrainer() *drainer {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &drainer{ctx: ctx, cancel: cancel, inflight: map[inflightKey]time.Time{}}
+}
+
+// wrap returns a Reconciler which runs r of the named controller with a drain context and tracks it while in flight
+func (d *drainer) wrap(controller string, r reconcile.Reconciler) reconcile.Reconciler {
+	return crreconcile.Func(func(ctx context.Context, request crreconcile.Request) (crreconcile.Result, error) {
+		key := inflightKey{controller: controller, object: request.NamespacedName}
+		d.mu.Lock()
+		d.inflight[key] = time.Now()
+		d.mu.Unlock()
+		defer func() {
+			d.mu.Lock()
+			delete(d.inflight, key)
+			d.mu.Unlock()
+		}()
+		return r.Reconcile(drainContext{Context: ctx, done: d.ctx}, request)
+	})
+}
+
+// drain waits up to timeout for the reconciles in flight to finish and cancels the context
+// of all remaining ones afterwards. It logs a summary of the abandoned reconciles.
+func (d *drainer) drain(timeout time.Duration) {
+	log := logf.Log.WithName("Shutdown")
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	tick := time.NewTicker(50 * time.Millisecond)
+	defer tick.Stop()
+
+	if n := d.count(); n > 0 {
+		log.Info("Waiting for in-flight handlers to finish", "inflight", n, "drainTimeout", timeout)
+	}
+	for d.count() > 0 {
+		select {
+		case <-deadline.C:
+			abandoned := d.abandoned()
+			d.cancel()
+			log.Info("Drain timeout reached, cancelled in-flight handlers", "abandoned", len(abandoned), "objects", abandoned)
+			return
+		case <-tick.C:
+		}
+	}
+	d.cancel()
+	log.Info("All in-flight handlers finished")
+}
+
+func (d *drainer) count() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.inflight)
+}
+
+// abandoned returns the objects in flight together with the time they have been running
+func (d *drainer) abandoned() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var abandoned []string
+	for key, started := range d.inflight {
+		abandoned = append(abandoned, key.controller+" "+key.object.String()+" (running for "+time.Since(started).Round(time.Millisecond).String()+")")
+	}
+	sort.Strings(abandoned)
+	return abandoned
+}
+
+// drainContext passes on the values of the reconcile context, but is only done once the drainer
+// cancels its context
+type drainContext struct {
+	context.Context
+	done context.Context
+}
+
+func (c drainContext) Deadline() (time.Time, bool) {
+	return c.done.Deadline()
+}
+
+func (c drainContext) Done() <-chan struct{} {
+	return c.done.Done()
+}
+
+func (c drainContext) Err() error {
+	return c.done.Err()
+}
@@ -0,0 +1,354 @@
+package operator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SchweizerischeBundesbahnen/lot/pkg/reconcile"
+	"github.com/SchweizerischeBundesbahnen/lot/pkg/selector"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+var _ DynamicOperator = &dynamicOperator{}
+
+// defaultDiscoveryInterval is the interval in which the CRDs are listed unless set with WithDiscoveryInterval
+const defaultDiscoveryInterval = 30 * time.Second
+
+// KindSelector selects the kinds handled by a DynamicOperator by the CustomResourceDefinitions defining them. An empty
+// Group matches the kinds of all groups, and Labels are matched against the labels of the CRDs, with the same semantics
+// as WithLabels.
+type KindSelector struct {
+	Group  string
+	Labels map[string]string
+}
+
+// dynamicOperator is a DynamicOperator which runs a controller with its own cache for every selected kind. Its
+// handlers and options are kept by an operator, which is copied for every kind.
+type dynamicOperator struct {
+	base     *operator
+	kinds    KindSelector
+	selector selector.Selector
+	mu       sync.Mutex
+	watched  map[schema.GroupVersionKind]*kindController
+	// discovered counts how often a kind has been discovered, so that a kind whose CRD returns gets a new controller
+	discovered map[schema.GroupVersionKind]int
+}
+
+// NewDynamic creates a DynamicOperator handling the custom resources of all kinds selected by kinds, which are
+// discovered while the operator is running. For every selected kind an informer is started once its CRD has been
+// established, and stopped once its CRD has been deleted. The handlers receive unstructured objects, whose kind is
+// available with object.GetObjectKind().GroupVersionKind(). The storage version of every kind is watched.
+// With WithLeaderElection, the lease is named after the group of kinds, e.g. "lot-dynamic.foo.sbb.ch".
+func NewDynamic(kinds KindSelector, opts ...ConstructorOption) (DynamicOperator, error) {
+	labels := kinds.Labels
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	sel, err := selector.NewSelector(labels, map[string]string{})
+	if err != nil {
+		return nil, fmt.Errorf("invalid KindSelector: %w", err)
+	}
+	// the object only names the operator, e.g. its lease, the objects handled are of the discovered kinds
+	object := &unstructured.Unstructured{}
+	object.SetGroupVersionKind(schema.GroupVersionKind{Group: kinds.Group, Version: "v1", Kind: "Dynamic"})
+	o, err := New(object, opts...)
+	if err != nil {
+		return nil, err
+	}
+	base := o.(*operator)
+	if base.discoveryInterval == 0 {
+		base.discoveryInterval = defaultDiscoveryInterval
+	}
+	return &dynamicOperator{
+		base:       base,
+		kinds:      kinds,
+		selector:   sel,
+		watched:    map[schema.GroupVersionKind]*kindController{},
+		discovered: map[schema.GroupVersionKind]int{},
+	}, nil
+}
+
+// OnCreateOrUpdate adds a handler which is run for created or updated objects of all selected kinds, see Operator
+func (d *dynamicOperator) OnCreateOrUpdate(fn reconcile.Handler, opts ...HandlerOption) {
+	d.base.OnCreateOrUpdate(fn, opts...)
+}
+
+// OnDelete adds a handler which is run for deleted objects of all selected kinds, see Operator
+func (d *dynamicOperator) OnDelete(fn reconcile.Handler, opts ...HandlerOption) {
+	d.base.OnDelete(fn, opts...)
+}
+
+// Kinds returns the kinds which are currently watched, sorted by group and kind
+func (d *dynamicOperator) Kinds() []schema.GroupVersionKind {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	kinds := make([]schema.GroupVersionKind, 0, len(d.watched))
+	for gvk := range d.watched {
+		kinds = append(kinds, gvk)
+	}
+	sort.Slice(kinds, func(i, j int) bool { return kinds[i].String() < kinds[j].String() })
+	return kinds
+}
+
+// RBAC returns the RBAC manifests granting the permissions the operator needs, see Operator. The rules cover all
+// resources of the selected group. If the kinds are only selected by labels, the rules cover the kinds of the selected
+// CRDs which exist in the cluster or are given with WithCRDs, so the manifests have to be computed again once further
+// CRDs are selected.
+func (d *dynamicOperator) RBAC() ([]client.Object, error) {
+	var crds []*unstructured.Unstructured
+	if d.kinds.Group == "" {
+		var err error
+		if crds, err = d.selectedCRDs(context.Background()); err != nil {
+			return nil, err
+		}
+	}
+	return d.base.rbac(func(namespaced, cluster policy) error {
+		verbs := append(readVerbs, "update", "patch")
+		if d.kinds.Group != "" {
+			namespaced.add(schema.GroupResource{Group: d.kinds.Group, Resource: "*"}, verbs...)
+		}
+		for _, crd := range crds {
+			group, _, _ := unstructured.NestedString(crd.Object, "spec", "group")
+			plural, _, _ := unstructured.NestedString(crd.Object, "spec", "names", "plural")
+			scope, _, _ := unstructured.NestedString(crd.Object, "spec", "scope")
+			p := namespaced
+			if scope == "Cluster" {
+				p = cluster
+			}
+			p.add(schema.GroupResource{Group: group, Resource: plural}, verbs...)
+		}
+		cluster.add(schema.GroupResource{Group: crdGVK.Group, Resource: "customresourcedefinitions"}, "list")
+		return nil
+	})
+}
+
+// selectedCRDs returns the selected CRDs given with WithCRDs and, unless the operator was created with
+// WithOfflineManager, the selected CRDs of the cluster
+func (d *dynamicOperator) selectedCRDs(ctx context.Context) ([]*unstructured.Unstructured, error) {
+	crds := d.base.crds
+	if !d.base.offline {
+		list := &unstructured.UnstructuredList{}
+		list.SetGroupVersionKind(crdGVK.GroupVersion().WithKind(crdGVK.Kind + "List"))
+		if err := d.base.manager.GetAPIReader().List(ctx, list); err != nil {
+			return nil, err
+		}
+		for i := range list.Items {
+			crds = append(crds, &list.Items[i])
+		}
+	}
+	var selected []*unstructured.Unstructured
+	for _, crd := range crds {
+		if d.selects(crd) {
+			selected = append(selected, crd)
+		}
+	}
+	return selected, nil
+}
+
+// validate checks the handlers and the owned resources of the operator, as its kinds are only known once discovered
+func (d *dynamicOperator) validate() error {
+	return newValidationError(append(d.base.validateHandlers(), d.base.validateOwns()...))
+}
+
+// Start starts the operator and stops it on SIGTERM or SIGINT
+func (d *dynamicOperator) Start() error {
+	if err := d.validate(); err != nil {
+		return err
+	}
+	return d.StartWithContext(ctrl.SetupSignalHandler())
+}
+
+// StartWithContext starts the operator and stops it once ctx is done. The kinds are discovered by the leader only,
+// and handlers in flight are given the time set with WithDrainTimeout to finish.
+func (d *dynamicOperator) StartWithContext(ctx context.Context) error {
+	if d.base.offline {
+		return errOffline
+	}
+	if err := d.validate(); err != nil {
+		return err
+	}
+	if err := d.base.manager.Add(&discoverer{operator: d}); err != nil {
+		return err
+	}
+	if err := d.base.installCRDs(ctx); err != nil {
+		return err
+	}
+	return d.base.run(ctx)
+}
+
+// discover lists the CRDs and starts watching the selected kinds which are not watched yet, and stops watching
+// the kinds whose CRDs are gone
+func (d *dynamicOperator) discover(ctx context.Context) error {
+	list := &unstructured.UnstructuredList{}
+	list.SetGroupVersionKind(crdGVK.GroupVersion().WithKind(crdGVK.Kind + "List"))
+	if err := d.base.manager.GetAPIReader().List(ctx, list); err != nil {
+		return err
+	}
+	selected := map[schema.GroupVersionKind]bool{}
+	for i := range list.Items {
+		if gvk, ok := d.kindOf(&list.Items[i]); ok {
+			selected[gvk] = true
+		}
+	}
+
+	log := logf.Log.WithName("Discovery")
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var errs error
+	for gvk := range selected {
+		if _, ok := d.watched[gvk]; ok {
+			continue
+		}
+		kc, err := d.watch(gvk)
+		if err != nil {
+			errs = errors.Join(errs, fmt.Errorf("unable to watch %s: %w", gvk, err))
+			continue
+		}
+		log.Info("Watching kind", "kind", gvk.String())
+		d.watched[gvk] = kc
+	}
+	for gvk, kc := range d.watched {
+		if !selected[gvk] {
+			log.Info("Stopped watching kind", "kind", gvk.String())
+			close(kc.stop)
+			delete(d.watched, gvk)
+		}
+	}
+	return errs
+}
+
+// kindOf returns the kind defined by an established CRD if it is selected
+func (d *dynamicOperator) kindOf(crd *unstructured.Unstructured) (schema.GroupVersionKind, bool) {
+	if !established(crd) || !d.selects(crd) {
+		return schema.GroupVersionKind{}, false
+	}
+	group, _, _ := unstructured.NestedString(crd.Object, "spec", "group")
+	kind, _, _ := unstructured.NestedString(crd.Object, "spec", "names", "kind")
+	version := storageVersion(crd)
+	if kind == "" || version == "" {
+		return schema.GroupVersionKind{}, false
+	}
+	return schema.GroupVersionKind{Group: group, Version: version, Kind: kind}, true
+}
+
+// selects returns true if the CRD is selected by the group and labels of the KindSelector
+func (d *dynamicOperator) selects(crd *unstructured.Unstructured) bool {
+	group, _, _ := unstructured.NestedString(crd.Object, "spec", "group")
+	if d.kinds.Group != "" && group != d.kinds.Group {
+		return false
+	}
+	labels := crd.GetLabels()
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	return d.selector.Matches(labels, map[string]string{})
+}
+
+// storageVersion returns the served storage version of a CRD, or its first served version
+func storageVersion(crd *unstructured.Unstructured) string {
+	versions, _, _ := unstructured.NestedSlice(crd.Object, "spec", "versions")
+	var served string
+	for _, v := range versions {
+		version, ok := v.(map[string]interface{})
+		if !ok || version["served"] != true {
+			continue
+		}
+		name, _ := version["name"].(string)
+		if version["storage"] == true {
+			return name
+		}
+		if served == "" {
+			served = name
+		}
+	}
+	return served
+}
+
+// watch adds a controller for the kind to the manager, which is set up like the controller of an Operator
+// for an unstructured object of the kind, but watches the objects with its own cache. A kind discovered again
+// gets a new controller, whose name is suffixed with the number of times the kind has been discovered.
+func (d *dynamicOperator) watch(gvk schema.GroupVersionKind) (*kindController, error) {
+	object := &unstructured.Unstructured{}
+	object.SetGroupVersionKind(gvk)
+	o := *d.base
+	o.object = object
+	o.tombstones = reconcile.NewTombstones()
+	o.name = strings.ToLower(gvk.Kind + "." + gvk.Group)
+	d.discovered[gvk]++
+	if n := d.discovered[gvk]; n > 1 {
+		o.name += "-" + strconv.Itoa(n)
+	}
+
+	c, err := namespacedCache(o.namespaces)(o.manager.GetConfig(), cache.Options{Scheme: o.manager.GetScheme(), Mapper: o.manager.GetRESTMapper()})
+	if err != nil {
+		return nil, err
+	}
+	ctl, err := o.newController(gvk, c)
+	if err != nil {
+		return nil, err
+	}
+	kc := &kindController{Controller: ctl, name: o.name, cache: c, stop: make(chan struct{})}
+	if err := o.manager.Add(kc); err != nil {
+		return nil, err
+	}
+	return kc, nil
+}
+
+// discoverer discovers the kinds of a DynamicOperator in the interval set with WithDiscoveryInterval. It is only
+// started on the leader, as it does not implement manager.LeaderElectionRunnable.
+type discoverer struct {
+	operator *dynamicOperator
+}
+
+func (d *discoverer) Start(ctx context.Context) error {
+	log := logf.Log.WithName("Discovery")
+	for {
+		if err := d.operator.discover(ctx); err != nil && ctx.Err() == nil {
+			log.Error(err, "unable to discover the kinds to watch")
+		}
+		select {
+		case <-time.After(d.operator.base.discoveryInterval):
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+// kindController is the controller of a single kind of a DynamicOperator, which starts its own cache and stops
+// once the kind is not watched anymore
+type kindController struct {
+	controller.Controller
+	name  string
+	cache cache.Cache
+	stop  chan struct{}
+}
+
+func (k *kindController) Start(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-k.stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	go func() {
+		if err := k.cache.Start(ctx); err != nil {
+			logf.Log.WithName("Discovery").Error(err, "unable to start the cache", "controller", k.name)
+		}
+	}()
+	return k.Controller.Start(ctx)
+}
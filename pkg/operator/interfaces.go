@@ -1,7 +1,13 @@
 package operator
 
 import (
+	"context"
+
+	"github.com/SchweizerischeBundesbahnen/lot/pkg/admission"
 	"github.com/SchweizerischeBundesbahnen/lot/pkg/reconcile"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
 )
 
@@ -9,7 +15,24 @@ import (
 type Operator interface {
 	OnCreateOrUpdate(handler reconcile.Handler, opts ...HandlerOption)
 	OnDelete(handler reconcile.Handler, opts ...HandlerOption)
+	OnTrigger(handler reconcile.Handler, opts ...HandlerOption)
+	Trigger(ctx context.Context, key types.NamespacedName, payload interface{}) error
+	OnSchedule(schedule string, handler reconcile.Handler, opts ...HandlerOption)
+	OnValidate(validator admission.Validator, opts ...HandlerOption)
+	OnMutate(mutator admission.Mutator, opts ...HandlerOption)
 	Predicate() predicate.Predicate
 	Build() error
+	RBAC() ([]client.Object, error)
+	Start() error
+	StartWithContext(ctx context.Context) error
+}
+
+// DynamicOperator knows how to call event handling operations for the custom resources of all kinds selected at runtime
+type DynamicOperator interface {
+	OnCreateOrUpdate(handler reconcile.Handler, opts ...HandlerOption)
+	OnDelete(handler reconcile.Handler, opts ...HandlerOption)
+	Kinds() []schema.GroupVersionKind
+	RBAC() ([]client.Object, error)
 	Start() error
+	StartWithContext(ctx context.Context) error
 }
@@ -0,0 +1,190 @@
+package operator
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/SchweizerischeBundesbahnen/lot/internal/defaults"
+	"github.com/SchweizerischeBundesbahnen/lot/internal/kinds"
+	lot_client "github.com/SchweizerischeBundesbahnen/lot/pkg/lot-client"
+	"github.com/SchweizerischeBundesbahnen/lot/pkg/reconcile"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	isLeader = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "lot_leader_election_is_leader",
+		Help: "Whether this replica currently holds the leader election lease (1) or not (0)",
+	}, []string{"lease"})
+	leaderTransitions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "lot_leader_election_transitions_total",
+		Help: "Number of times this replica acquired or released the leader election lease",
+	}, []string{"lease", "transition"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(isLeader, leaderTransitions)
+}
+
+// managerOptions returns the manager.Options to create the manager with, including the leader
+// election configuration requested with WithLeaderElection
+func managerOptions(object client.Object, options constructorOptions) (*manager.Options, error) {
+	if !options.leaderElection {
+		return options.mgrOpts, nil
+	}
+	if options.mgr != nil {
+		return nil, fmt.Errorf("WithLeaderElection() cannot be combined with WithManager(...)")
+	}
+
+	mgrOpts := manager.Options{}
+	if options.mgrOpts != nil {
+		mgrOpts = *options.mgrOpts
+	}
+	mgrOpts.LeaderElection = true
+
+	if mgrOpts.LeaderElectionID == "" {
+		scheme := mgrOpts.Scheme
+		if scheme == nil {
+			scheme = clientgoscheme.Scheme
+		}
+		gvk, err := apiutil.GVKForObject(object, scheme)
+		if err != nil {
+			return nil, err
+		}
+		// results in e.g. "lot-secret" or "lot-deployment.apps"
+		mgrOpts.LeaderElectionID = strings.ToLower(strings.TrimSuffix("lot-"+gvk.Kind+"."+gvk.Group, "."))
+	}
+	if options.dryRun {
+		mgrOpts.LeaderElectionID += "-dry-run"
+	}
+
+	if mgrOpts.LeaderElectionNamespace == "" {
+		ns, err := defaults.Namespace()
+		if err != nil {
+			return nil, fmt.Errorf("unable to determine the leader election namespace, set it with WithManagerOptions(...): %w", err)
+		}
+		mgrOpts.LeaderElectionNamespace = ns
+	}
+	return &mgrOpts, nil
+}
+
+// leaseName returns the name of the leader election lease, or an empty string without leader election
+func leaseName(mgrOpts *manager.Options) string {
+	if mgrOpts == nil || !mgrOpts.LeaderElection {
+		return ""
+	}
+	return mgrOpts.LeaderElectionID
+}
+
+// leaseNamespace returns the namespace of the leader election lease, or an empty string without leader election
+func leaseNamespace(mgrOpts *manager.Options) string {
+	if mgrOpts == nil || !mgrOpts.LeaderElection {
+		return ""
+	}
+	return mgrOpts.LeaderElectionNamespace
+}
+
+// leaderOnly wraps a handler so that it is skipped on replicas which are not the leader, which then do not write the
+// status of the object either. Without leader election every replica is considered to be the leader.
+func leaderOnly(fn reconcile.Handler, elected <-chan struct{}) reconcile.Handler {
+	if fn == nil {
+		return nil
+	}
+	return func(ctx context.Context, obj client.Object, cl lot_client.Client, scheme *runtime.Scheme) error {
+		select {
+		case <-elected:
+			return fn(ctx, obj, cl, scheme)
+		default:
+			logf.FromContext(ctx).V(1).Info("skipping leader-only handler, this replica is not the leader")
+			reconcile.SkipStatus(ctx)
+			return nil
+		}
+	}
+}
+
+var _ manager.LeaderElectionRunnable = allReplicasController{}
+
+// allReplicasController runs a controller regardless of whether this replica is the leader
+type allReplicasController struct {
+	controller.Controller
+}
+
+func (allReplicasController) NeedLeaderElection() bool {
+	return false
+}
+
+// resyncOnElection enqueues all primary resources once this replica becomes the leader. It is
+// only started on the leader, as it does not implement manager.LeaderElectionRunnable.
+type resyncOnElection struct {
+	client lot_client.Client
+	object client.Object
+	events chan<- event.GenericEvent
+}
+
+func (r *resyncOnElection) Start(ctx context.Context) error {
+	return enqueueAll(ctx, r.client, r.object, r.events)
+}
+
+// enqueueAll sends a generic event for every object of the kind of object
+func enqueueAll(ctx context.Context, cl lot_client.Client, object client.Object, events chan<- event.GenericEvent) error {
+	list, err := kinds.NewListFor(object, cl.Scheme())
+	if err != nil {
+		return err
+	}
+	if err := cl.List(ctx, list); err != nil {
+		return err
+	}
+	return meta.EachListItem(list, func(o runtime.Object) error {
+		select {
+		case events <- event.GenericEvent{Object: o.(client.Object)}:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+}
+
+var _ manager.LeaderElectionRunnable = &leaderReporter{}
+
+// leaderReporter logs and exposes metrics about the leadership of this replica
+type leaderReporter struct {
+	lease   string
+	elected <-chan struct{}
+}
+
+func (r *leaderReporter) NeedLeaderElection() bool {
+	return false
+}
+
+func (r *leaderReporter) Start(ctx context.Context) error {
+	log := logf.Log.WithName("LeaderElection").WithValues("lease", r.lease)
+	isLeader.WithLabelValues(r.lease).Set(0)
+	log.Info("Waiting to become the leader")
+
+	select {
+	case <-r.elected:
+	case <-ctx.Done():
+		return nil
+	}
+	log.Info("Became the leader")
+	isLeader.WithLabelValues(r.lease).Set(1)
+	leaderTransitions.WithLabelValues(r.lease, "acquired").Inc()
+
+	// the manager stops once the leadership is lost, so the leadership ends with the context
+	<-ctx.Done()
+	log.Info("Stopped leading")
+	isLeader.WithLabelValues(r.lease).Set(0)
+	leaderTransitions.WithLabelValues(r.lease, "released").Inc()
+	return nil
+}
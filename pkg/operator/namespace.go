@@ -0,0 +1,134 @@
+package operator
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/SchweizerischeBundesbahnen/lot/internal/defaults"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+// withNamespaces returns the manager options with a cache restricted to the namespaces given with WithNamespaces or
+// WithOwnNamespace, together with these namespaces
+func withNamespaces(mgrOpts *manager.Options, options constructorOptions) (*manager.Options, []string, error) {
+	namespaces := options.namespaces
+	if options.ownNamespace {
+		ns, err := defaults.Namespace()
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to determine the namespace of the operator: %w", err)
+		}
+		namespaces = append(namespaces, ns)
+	}
+	if len(namespaces) == 0 {
+		return mgrOpts, nil, nil
+	}
+	if options.mgr != nil {
+		return nil, nil, fmt.Errorf("WithNamespaces(...) and WithOwnNamespace() cannot be combined with WithManager(...)")
+	}
+
+	opts := manager.Options{}
+	if mgrOpts != nil {
+		opts = *mgrOpts
+	}
+	if opts.Namespace != "" || opts.NewCache != nil {
+		return nil, nil, fmt.Errorf("WithNamespaces(...) and WithOwnNamespace() cannot be combined with a namespace or cache set with WithManagerOptions(...)")
+	}
+	namespaces = unique(namespaces)
+	opts.NewCache = namespacedCache(namespaces)
+	return &opts, namespaces, nil
+}
+
+// namespacedCache returns a function creating caches which only list and watch the namespaced objects of the given
+// namespaces, or of all namespaces if there are none
+func namespacedCache(namespaces []string) cache.NewCacheFunc {
+	if len(namespaces) > 1 {
+		return cache.MultiNamespacedCacheBuilder(namespaces)
+	}
+	return func(config *rest.Config, opts cache.Options) (cache.Cache, error) {
+		if len(namespaces) == 1 {
+			opts.Namespace = namespaces[0]
+		}
+		return cache.New(config, opts)
+	}
+}
+
+func unique(values []string) []string {
+	var result []string
+	seen := map[string]bool{}
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+// validateScopes checks that the kinds given with WithOwns can be owned by the primary resource, as namespaced objects
+// can only be owned by objects of their own namespace or cluster-scoped objects, and cluster-scoped objects only by
+// cluster-scoped ones. Operators restricted to namespaces must not watch cluster-scoped kinds at all, neither owned
+// nor tracked with WithTrackedOwns. Kinds which are
+// not known yet, e.g. custom resources whose CRDs are installed with WithCRDs, are not checked.
+func (o *operator) validateScopes(gvk schema.GroupVersionKind) error {
+	scope, err := o.scopeOf(gvk)
+	if err != nil {
+		return err
+	}
+	var errs error
+	if scope == meta.RESTScopeNameRoot && len(o.namespaces) > 0 {
+		errs = errors.Join(errs, fmt.Errorf("%s is cluster-scoped, but the operator is restricted to the namespaces %v", gvk.Kind, o.namespaces))
+	}
+	for _, input := range o.ownsInput {
+		ownedGVK, err := apiutil.GVKForObject(input.object, o.manager.GetScheme())
+		if err != nil {
+			return err
+		}
+		ownedScope, err := o.scopeOf(ownedGVK)
+		if err != nil {
+			return err
+		}
+		if ownedScope != meta.RESTScopeNameRoot {
+			continue
+		}
+		switch {
+		case scope == meta.RESTScopeNameNamespace:
+			errs = errors.Join(errs, fmt.Errorf("WithOwns(...) cannot watch the cluster-scoped %s, as it cannot be owned by the namespaced %s, use WithTrackedOwns(...)", ownedGVK.Kind, gvk.Kind))
+		case len(o.namespaces) > 0:
+			errs = errors.Join(errs, fmt.Errorf("WithOwns(...) cannot watch the cluster-scoped %s, as the operator is restricted to the namespaces %v", ownedGVK.Kind, o.namespaces))
+		}
+	}
+	if len(o.namespaces) == 0 {
+		return errs
+	}
+	for _, input := range o.trackedOwnsInput {
+		trackedGVK, err := apiutil.GVKForObject(input.object, o.manager.GetScheme())
+		if err != nil {
+			return err
+		}
+		trackedScope, err := o.scopeOf(trackedGVK)
+		if err != nil {
+			return err
+		}
+		if trackedScope == meta.RESTScopeNameRoot {
+			errs = errors.Join(errs, fmt.Errorf("WithTrackedOwns(...) cannot watch the cluster-scoped %s, as the operator is restricted to the namespaces %v", trackedGVK.Kind, o.namespaces))
+		}
+	}
+	return errs
+}
+
+// scopeOf returns the scope of the kind, or an empty scope if the kind is not known
+func (o *operator) scopeOf(gvk schema.GroupVersionKind) (meta.RESTScopeName, error) {
+	mapping, err := o.manager.GetRESTMapper().RESTMapping(gvk.GroupKind(), gvk.Version)
+	if meta.IsNoMatchError(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return mapping.Scope.Name(), nil
+}
@@ -1,25 +1,43 @@
 package operator
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"github.com/SchweizerischeBundesbahnen/lot/internal/defaults"
+	"github.com/SchweizerischeBundesbahnen/lot/pkg/admission"
 	lot_client "github.com/SchweizerischeBundesbahnen/lot/pkg/lot-client"
+	"github.com/SchweizerischeBundesbahnen/lot/pkg/ownership"
 	"github.com/SchweizerischeBundesbahnen/lot/pkg/predicates"
 	"github.com/SchweizerischeBundesbahnen/lot/pkg/reconcile"
+	"github.com/SchweizerischeBundesbahnen/lot/pkg/selector"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/time/rate"
+	rbacv1 "k8s.io/api/rbac/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
-	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	crreconcile "sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+	"strings"
+	"time"
 )
 
 var _ Operator = &operator{}
 
+// triggerBufferSize is the number of triggers which can be sent before the controller has been started
+const triggerBufferSize = 1024
+
 // operator is an operator.Operator that holds all the moving parts needed in order to build an controller-runtime controller
 type operator struct {
 	client     lot_client.Client
@@ -30,8 +48,53 @@ type operator struct {
 	predicates        []predicate.Predicate
 	customPredicates  []predicate.Predicate
 	ownsInput         []OwnsInput
+	trackedOwnsInput  []OwnsInput
+	recorder          *recording
 	reconcileHandlers *reconcile.HandlerFuncs
-	errs              error
+	tombstones        *reconcile.Tombstones
+	triggers          *reconcile.Triggers
+	// triggerEvents enqueues the objects of triggers sent with Trigger
+	triggerEvents chan event.GenericEvent
+	// schedulers enqueue the objects of the handlers added with OnSchedule
+	schedulers []*scheduler
+	validators []admission.NamedValidator
+	mutators   []admission.NamedMutator
+	webhook    webhookOptions
+	// crds are installed when the operator is started
+	crds          []*unstructured.Unstructured
+	statusUpdates bool
+	// dryRun is true if the writes of the operator are sent with the dry-run option
+	dryRun bool
+	// discoveryInterval is the interval in which a DynamicOperator discovers the kinds to watch
+	discoveryInterval time.Duration
+	// clusters are reconciled in addition to the cluster of the manager
+	clusters []remoteCluster
+	// cluster is the identifier of the cluster reconciled by a copy of the operator for one of its clusters
+	cluster string
+	// namespaces the operator is restricted to, empty for all namespaces
+	namespaces []string
+	// allReplicas is true if any handler has to run on all replicas of the operator
+	allReplicas bool
+	// reloadables are the selectors given with WithSelector which change at runtime
+	reloadables []changeNotifier
+	// leaseName is the name of the leader election lease, empty without leader election
+	leaseName      string
+	leaseNamespace string
+	// rbacRules are added to the RBAC manifests of the operator
+	rbacRules []rbacv1.PolicyRule
+	// offline is set for operators created with WithOfflineManager, which cannot be started
+	offline bool
+	// drain tracks the handlers in flight so they can finish during shutdown
+	drain           *drainer
+	drainTimeout    time.Duration
+	controllerOpts  controller.Options
+	defaultTimeout  time.Duration
+	middlewares     []reconcile.Middleware
+	tracerProvider  trace.TracerProvider
+	shutdownTracing func(context.Context) error
+	// name is the name of the controller, set by Build
+	name string
+	errs error
 }
 
 // New is a constructor function that creates a new instance of Operator.
@@ -51,7 +114,22 @@ func New(object client.Object, opts ...ConstructorOption) (Operator, error) {
 		_ownsInput = append(_ownsInput, options.ownsInput...)
 	}
 
-	mgr, err := defaults.InitManager(options.mgrOpts)
+	var err error
+	options.mgrOpts, err = withScheme(options.mgrOpts, options)
+	if err != nil {
+		return nil, err
+	}
+	mgrOpts, err := managerOptions(object, options)
+	if err != nil {
+		return nil, err
+	}
+	mgrOpts, namespaces, err := withNamespaces(mgrOpts, options)
+	if err != nil {
+		return nil, err
+	}
+	options.mgrOpts = withGracefulShutdownTimeout(mgrOpts, options)
+
+	mgr, err := initManager(options)
 	if err != nil {
 		return nil, err
 	}
@@ -61,9 +139,26 @@ func New(object client.Object, opts ...ConstructorOption) (Operator, error) {
 		customPredicates = options.predicates
 	}
 
-	handlerFuncs := reconcile.HandlerFuncs{}
+	var recorder *recording
+	if options.recordPath != "" {
+		recorder = &recording{path: options.recordPath}
+	}
 
-	cl := lot_client.New(mgr.GetClient())
+	handlerFuncs := reconcile.HandlerFuncs{Parallel: options.parallelHandlers}
+
+	var clientOpts []lot_client.Option
+	if options.tracerProvider != nil {
+		clientOpts = append(clientOpts, lot_client.WithTracerProvider(options.tracerProvider))
+	}
+	if options.dryRun {
+		clientOpts = append(clientOpts, lot_client.WithDryRun())
+	}
+	cl := lot_client.New(mgr.GetClient(), clientOpts...)
+
+	clusters, err := initClusters(mgr, options, clientOpts)
+	if err != nil {
+		return nil, err
+	}
 
 	return &operator{
 			client:            cl,
@@ -71,7 +166,30 @@ func New(object client.Object, opts ...ConstructorOption) (Operator, error) {
 			manager:           mgr,
 			customPredicates:  customPredicates,
 			ownsInput:         _ownsInput,
-			reconcileHandlers: &handlerFuncs},
+			trackedOwnsInput:  options.trackedOwnsInput,
+			recorder:          recorder,
+			reconcileHandlers: &handlerFuncs,
+			tombstones:        reconcile.NewTombstones(),
+			triggers:          reconcile.NewTriggers(),
+			triggerEvents:     make(chan event.GenericEvent, triggerBufferSize),
+			webhook:           options.webhook,
+			crds:              options.crds,
+			statusUpdates:     options.statusUpdates,
+			dryRun:            options.dryRun,
+			discoveryInterval: options.discoveryInterval,
+			clusters:          clusters,
+			namespaces:        namespaces,
+			leaseName:         leaseName(mgrOpts),
+			leaseNamespace:    leaseNamespace(mgrOpts),
+			rbacRules:         options.rbacRules,
+			offline:           options.offline,
+			drain:             newDrainer(),
+			drainTimeout:      options.drainTimeout,
+			controllerOpts:    options.controllerOpts,
+			defaultTimeout:    options.defaultTimeout,
+			middlewares:       options.middlewares,
+			tracerProvider:    options.tracerProvider,
+			shutdownTracing:   options.shutdownTracing},
 		nil
 }
 
@@ -80,33 +198,6 @@ func New(object client.Object, opts ...ConstructorOption) (Operator, error) {
 // and a variadic list of constructorOptions.
 // It returns an Operator and an error, if any.
 func NewUntyped(group, version, kind string, opts ...ConstructorOption) (Operator, error) {
-	var options constructorOptions
-	for _, opt := range opts {
-		err := opt(&options)
-		if err != nil {
-			return nil, err
-		}
-	}
-
-	var _ownsInput []OwnsInput
-	if len(options.ownsInput) > 0 {
-		_ownsInput = append(_ownsInput, options.ownsInput...)
-	}
-
-	mgr, err := defaults.InitManager(options.mgrOpts)
-	if err != nil {
-		return nil, err
-	}
-
-	var customPredicates []predicate.Predicate
-	if options.predicates != nil {
-		customPredicates = options.predicates
-	}
-
-	handlerFuncs := reconcile.HandlerFuncs{}
-
-	cl := lot_client.New(mgr.GetClient())
-
 	var object client.Object
 	object = &unstructured.Unstructured{}
 	gvk := schema.GroupVersionKind{
@@ -116,30 +207,49 @@ func NewUntyped(group, version, kind string, opts ...ConstructorOption) (Operato
 	}
 	object.GetObjectKind().SetGroupVersionKind(gvk)
 
-	return &operator{
-			client:            cl,
-			object:            object,
-			manager:           mgr,
-			customPredicates:  customPredicates,
-			ownsInput:         _ownsInput,
-			reconcileHandlers: &handlerFuncs},
-		nil
+	return New(object, opts...)
 }
 
-// OnCreateOrUpdate is a function that configures the predicate.CreateFunc and the reconcile.CreateOrUpdateHandler which are
-// used to build the Operator's embedded controller.Controller. In this way the controller.Controller's Reconciler is able to
-// handle create and update events accordingly
-func (o *operator) OnCreateOrUpdate(fn reconcile.Handler, opts ...HandlerOption) {
-	options := handlerOptions{
-		labels:      map[string]string{},
-		annotations: map[string]string{},
-	}
-	for _, opt := range opts {
-		err := opt(&options)
-		if err != nil {
-			o.errs = errors.Join(o.errs, err)
+// initManager returns the manager.Manager given with WithManager or creates a new one
+// with the options given with WithManagerOptions
+func initManager(options constructorOptions) (manager.Manager, error) {
+	if options.mgr == nil {
+		if options.offline {
+			return defaults.InitOfflineManager(options.mgrOpts)
 		}
+		return defaults.InitManager(options.mgrOpts)
+	}
+	if options.offline {
+		return nil, fmt.Errorf("WithManager(...) and WithOfflineManager() cannot be combined")
+	}
+	if options.mgrOpts != nil {
+		return nil, fmt.Errorf("WithManager(...) and WithManagerOptions(...) cannot be combined")
+	}
+	return options.mgr, nil
+}
+
+// withGracefulShutdownTimeout raises the graceful shutdown timeout of the manager so that it does
+// not give up on the controllers before the handlers in flight have been drained
+func withGracefulShutdownTimeout(mgrOpts *manager.Options, options constructorOptions) *manager.Options {
+	if options.drainTimeout == 0 || options.mgr != nil {
+		return mgrOpts
+	}
+	if mgrOpts == nil {
+		mgrOpts = &manager.Options{}
 	}
+	if mgrOpts.GracefulShutdownTimeout == nil {
+		timeout := options.drainTimeout + abandonGracePeriod
+		mgrOpts.GracefulShutdownTimeout = &timeout
+	}
+	return mgrOpts
+}
+
+// OnCreateOrUpdate is a function that configures the predicate.CreateFunc and adds a handler to the reconcile.HandlerFuncs
+// which are used to build the Operator's embedded controller.Controller. In this way the controller.Controller's Reconciler is able to
+// handle create and update events accordingly. Calling it multiple times adds further handlers, which are run in the order they have
+// been added, but only for objects matching their labels and annotations.
+func (o *operator) OnCreateOrUpdate(fn reconcile.Handler, opts ...HandlerOption) {
+	options, sel := o.handlerOptions(opts)
 	// Filter out all non-delete events when using a delete handler.
 	// See Predicate() for further details.
 	defaultPredicate := predicate.Funcs{
@@ -148,20 +258,120 @@ func (o *operator) OnCreateOrUpdate(fn reconcile.Handler, opts ...HandlerOption)
 		DeleteFunc:  func(event.DeleteEvent) bool { return false },
 		GenericFunc: func(event.GenericEvent) bool { return false },
 	}
-	metadataPredicate, err := predicates.CreateOrUpdateByMetadata(options.labels, options.annotations)
-	if err != nil {
-		o.errs = errors.Join(o.errs, err)
-	}
-	handlerPredicate := predicate.And(defaultPredicate, metadataPredicate)
+	handlerPredicate := predicate.And(defaultPredicate, predicates.CreateOrUpdateBySelector(sel))
 	o.predicates = append(o.predicates, handlerPredicate)
 
-	o.reconcileHandlers.CreateOrUpdateHandler = fn
+	o.reconcileHandlers.CreateOrUpdateHandlers = append(o.reconcileHandlers.CreateOrUpdateHandlers,
+		o.namedHandler(fn, "create_or_update", len(o.reconcileHandlers.CreateOrUpdateHandlers), options, sel))
 }
 
-// OnDelete is a function that configures the predicate.DeleteFunc and the reconcileHandler.DeleteHandler which are
+// OnDelete is a function that configures the predicate.DeleteFunc and adds a handler to the reconcile.HandlerFuncs which are
 // used to build the Operator's embedded controller.Controller. In this way the controller.Controller's Reconciler is able to
-// handle update events accordingly
+// handle update events accordingly. Calling it multiple times adds further handlers, which are run in the order they have
+// been added, but only for objects matching their labels and annotations.
 func (o *operator) OnDelete(fn reconcile.Handler, opts ...HandlerOption) {
+	options, sel := o.handlerOptions(opts)
+	// Filter out all non-delete events when using a delete handler, except for updates of objects
+	// which are terminating, as they are waiting for the delete handler to remove their finalizers.
+	// See Predicate() for further details.
+	defaultPredicate := predicate.Funcs{
+		CreateFunc:  func(event.CreateEvent) bool { return false },
+		UpdateFunc:  func(event.UpdateEvent) bool { return false },
+		DeleteFunc:  func(event.DeleteEvent) bool { return true },
+		GenericFunc: func(event.GenericEvent) bool { return false },
+	}
+	handlerPredicate := predicate.Or(predicate.And(defaultPredicate, predicates.DeleteBySelector(sel)), predicates.TerminatingBySelector(sel))
+	o.predicates = append(o.predicates, handlerPredicate)
+
+	o.reconcileHandlers.DeleteHandlers = append(o.reconcileHandlers.DeleteHandlers,
+		o.namedHandler(fn, "delete", len(o.reconcileHandlers.DeleteHandlers), options, sel))
+}
+
+// OnTrigger adds a handler to the reconcile.HandlerFuncs which is run once for every trigger sent with Trigger for an object
+// matching its labels and annotations. The payload of the trigger is passed to the handler in its context, see
+// reconcile.TriggerPayload. Calling it multiple times adds further handlers, which are run in the order they have been added.
+func (o *operator) OnTrigger(fn reconcile.Handler, opts ...HandlerOption) {
+	options, sel := o.handlerOptions(opts)
+	// Trigger handlers are not run for events of the primary resource, which are filtered out as a whole
+	// if there are no other handlers. Triggers are enqueued regardless of any predicates.
+	o.predicates = append(o.predicates, rejectAll)
+
+	o.reconcileHandlers.TriggerHandlers = append(o.reconcileHandlers.TriggerHandlers,
+		o.namedHandler(fn, "trigger", len(o.reconcileHandlers.TriggerHandlers), options, sel))
+}
+
+// Trigger requests a reconcile of the object with the given key, in which the trigger handlers are run with the payload
+// before the create or update handlers. Triggers can be sent before the operator has been started, and only block if
+// too many of them are waiting to be enqueued. If ctx is done before the reconcile has been requested, the trigger is
+// still passed to the trigger handlers the next time the object is reconciled.
+func (o *operator) Trigger(ctx context.Context, key types.NamespacedName, payload interface{}) error {
+	return o.sendTrigger(ctx, reconcile.Trigger{Key: key, Payload: payload})
+}
+
+// OnSchedule adds a handler to the reconcile.HandlerFuncs which is run for every object matching its labels and annotations
+// whenever the schedule is due. The schedule is either an interval like "30m" or a cron expression like "0 * * * *" or
+// "@hourly". The objects are read from the cache and spread over the jitter given with WithJitter, which defaults to a tenth
+// of the schedule's period. The handler receives the time the object was due as the payload of its trigger, see
+// reconcile.TriggerPayload, and is run before the create or update handlers, so that a nil handler simply resyncs the objects.
+// The objects are only enqueued by the leader, the time they waited for the handler is observed in the metric
+// lot_schedule_lag_seconds.
+func (o *operator) OnSchedule(schedule string, fn reconcile.Handler, opts ...HandlerOption) {
+	options, sel := o.handlerOptions(opts)
+	sched, err := parseSchedule(schedule)
+	if err != nil {
+		o.errs = errors.Join(o.errs, fmt.Errorf("invalid schedule %q: %w", schedule, err))
+		return
+	}
+	// Scheduled handlers are not run for events of the primary resource, see OnTrigger.
+	o.predicates = append(o.predicates, rejectAll)
+
+	h := o.namedHandler(fn, "schedule", len(o.reconcileHandlers.ScheduledHandlers), options, sel)
+	h.Handler = o.withScheduleLag(h.Handler, h.Name)
+	o.reconcileHandlers.ScheduledHandlers = append(o.reconcileHandlers.ScheduledHandlers, h)
+	o.schedulers = append(o.schedulers, &scheduler{
+		handler:  h,
+		schedule: sched,
+		jitter:   jitterFor(sched, options),
+		client:   o.client,
+		object:   o.object,
+		send:     o.sendTrigger,
+	})
+}
+
+// rejectAll filters out all events of the primary resource for handlers which are only run for triggers
+var rejectAll = predicate.Funcs{
+	CreateFunc:  func(event.CreateEvent) bool { return false },
+	UpdateFunc:  func(event.UpdateEvent) bool { return false },
+	DeleteFunc:  func(event.DeleteEvent) bool { return false },
+	GenericFunc: func(event.GenericEvent) bool { return false },
+}
+
+// ownerGone accepts the events of terminating and deleted objects, so that the children tracked with
+// WithTrackedOwns are cleaned up even without a delete handler
+var ownerGone = predicate.Funcs{
+	CreateFunc:  func(event.CreateEvent) bool { return false },
+	UpdateFunc:  func(e event.UpdateEvent) bool { return e.ObjectNew.GetDeletionTimestamp() != nil },
+	DeleteFunc:  func(event.DeleteEvent) bool { return true },
+	GenericFunc: func(event.GenericEvent) bool { return false },
+}
+
+// sendTrigger keeps the trigger for the reconcile of its object, which is enqueued through the trigger channel
+func (o *operator) sendTrigger(ctx context.Context, trigger reconcile.Trigger) error {
+	o.triggers.Add(trigger)
+
+	obj := o.object.DeepCopyObject().(client.Object)
+	obj.SetNamespace(trigger.Key.Namespace)
+	obj.SetName(trigger.Key.Name)
+	select {
+	case o.triggerEvents <- event.GenericEvent{Object: obj}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// handlerOptions applies the options of a handler and returns its selector, or nil if the options are invalid
+func (o *operator) handlerOptions(opts []HandlerOption) (handlerOptions, selector.Selector) {
 	options := handlerOptions{
 		labels:      map[string]string{},
 		annotations: map[string]string{},
@@ -172,39 +382,151 @@ func (o *operator) OnDelete(fn reconcile.Handler, opts ...HandlerOption) {
 			o.errs = errors.Join(o.errs, err)
 		}
 	}
-	// Filter out all non-delete events when using a delete handler.
-	// See Predicate() for further details.
-	defaultPredicate := predicate.Funcs{
-		CreateFunc:  func(event.CreateEvent) bool { return false },
-		UpdateFunc:  func(event.UpdateEvent) bool { return false },
-		DeleteFunc:  func(event.DeleteEvent) bool { return true },
-		GenericFunc: func(event.GenericEvent) bool { return false },
-	}
-	metadataPredicate, err := predicates.DeleteByMetadata(options.labels, options.annotations)
+	sel, err := options.newSelector()
 	if err != nil {
 		o.errs = errors.Join(o.errs, err)
+		return options, nil
 	}
-	handlerPredicate := predicate.And(defaultPredicate, metadataPredicate)
-	o.predicates = append(o.predicates, handlerPredicate)
+	return options, sel
+}
+
+// namedHandler returns the handler registered under its name, see handlerName
+func (o *operator) namedHandler(fn reconcile.Handler, eventType string, registered int, options handlerOptions, sel selector.Selector) reconcile.NamedHandler {
+	name := o.handlerName(eventType, registered, options)
+	if n, ok := sel.(changeNotifier); ok && !o.reloads(n) {
+		o.reloadables = append(o.reloadables, n)
+	}
+	return reconcile.NamedHandler{Name: name, Handler: o.wrap(fn, name, options), Selector: sel}
+}
+
+// reloads returns true if the selector is already resynced on changes
+func (o *operator) reloads(n changeNotifier) bool {
+	for _, r := range o.reloadables {
+		if r == n {
+			return true
+		}
+	}
+	return false
+}
 
-	o.reconcileHandlers.DeleteHandler = fn
+// handlerName returns the name given with WithName. Without a name, handlers are named after their
+// event type, e.g. "delete", "delete-2" and so on. Names have to be unique among all handlers.
+func (o *operator) handlerName(eventType string, registered int, options handlerOptions) string {
+	name := options.name
+	if name == "" {
+		name = eventType
+		if registered > 0 {
+			name = fmt.Sprintf("%s-%d", eventType, registered+1)
+		}
+	}
+	var names []string
+	for _, handlers := range [][]reconcile.NamedHandler{
+		o.reconcileHandlers.CreateOrUpdateHandlers,
+		o.reconcileHandlers.DeleteHandlers,
+		o.reconcileHandlers.TriggerHandlers,
+		o.reconcileHandlers.ScheduledHandlers,
+	} {
+		for _, h := range handlers {
+			names = append(names, h.Name)
+		}
+	}
+	for _, v := range o.validators {
+		names = append(names, v.Name)
+	}
+	for _, m := range o.mutators {
+		names = append(names, m.Name)
+	}
+	for _, n := range names {
+		if n == name {
+			o.errs = errors.Join(o.errs, fmt.Errorf("a handler named %q is already registered", name))
+		}
+	}
+	return name
+}
+
+// wrap applies the handler options which change the way the handler is run
+func (o *operator) wrap(fn reconcile.Handler, handler string, options handlerOptions) reconcile.Handler {
+	fn = o.withTimeout(fn, handler, options)
+	var middlewares []reconcile.Middleware
+	middlewares = append(middlewares, o.middlewares...)
+	fn = reconcile.Chain(fn, append(middlewares, options.middlewares...)...)
+	fn = limited(fn, options)
+	if options.allReplicas {
+		o.allReplicas = true
+		return fn
+	}
+	return leaderOnly(fn, o.manager.Elected())
+}
+
+// limited wraps the handler with a reconcile.Limiter if WithMaxConcurrency or WithRateLimit is given
+func limited(fn reconcile.Handler, options handlerOptions) reconcile.Handler {
+	if options.concurrency == 0 && options.rateLimit == 0 {
+		return fn
+	}
+	limit := rate.Inf
+	if options.rateLimit > 0 {
+		limit = options.rateLimit
+	}
+	return reconcile.NewLimiter(options.concurrency, limit, options.burst).Wrap(fn)
 }
 
 // Start is a function that starts the embedded manager.Manager part of the Operator
+// and stops it on SIGTERM or SIGINT
 func (o *operator) Start() error {
-	if o.errs != nil {
-		return o.errs
+	// the signal handler can only be set up once, so it is not set up if the operator cannot start anyway
+	if err := o.validate(); err != nil {
+		return err
 	}
+	return o.StartWithContext(ctrl.SetupSignalHandler())
+}
 
+// StartWithContext starts the embedded manager.Manager part of the Operator and stops it
+// once ctx is done. Handlers in flight are given the time set with WithDrainTimeout to finish.
+func (o *operator) StartWithContext(ctx context.Context) error {
+	if o.offline {
+		return errOffline
+	}
 	if err := o.Build(); err != nil {
 		return err
 	}
 
-	if err := o.manager.Start(ctrl.SetupSignalHandler()); err != nil {
+	if err := o.installCRDs(ctx); err != nil {
 		return err
 	}
+	return o.run(ctx)
+}
+
+// run starts the manager once everything has been added to it, and drains the handlers in flight once ctx is done
+func (o *operator) run(ctx context.Context) (err error) {
+	if o.recorder != nil {
+		if err := o.recorder.open(); err != nil {
+			return err
+		}
+		defer o.recorder.Close()
+	}
 
-	return nil
+	// flush the spans of a TracerProvider created with WithTraceExporter
+	if o.shutdownTracing != nil {
+		defer func() {
+			err = errors.Join(err, o.shutdownTracing(context.Background()))
+		}()
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- o.manager.Start(ctx)
+	}()
+
+	select {
+	case err := <-errCh:
+		o.drain.cancel()
+		return err
+	case <-ctx.Done():
+	}
+	// the controller stops dequeuing events as soon as ctx is done
+	o.drain.drain(o.drainTimeout)
+
+	return <-errCh
 }
 
 // Predicate constructs the predicate used to filter events for the primary resources
@@ -221,7 +543,11 @@ func (o *operator) Predicate() predicate.Predicate {
 		// for the specific handler and blocks all other events. By combining these predicates with a logical
 		// or, we ensure that all events handled by any handler are processed.
 		// Read this as: "The event either is intended for a handler, or it gets rejected"
-		handlerPredicate := predicate.Or(o.predicates...)
+		handlerPredicates := o.predicates
+		if len(o.trackedOwnsInput) > 0 {
+			handlerPredicates = append(append([]predicate.Predicate(nil), o.predicates...), ownerGone)
+		}
+		handlerPredicate := predicate.Or(handlerPredicates...)
 		prcts = append(prcts, handlerPredicate)
 	}
 
@@ -230,37 +556,230 @@ func (o *operator) Predicate() predicate.Predicate {
 	// Read this as: "The event must be intended for a handler and it must fulfill all custom predicates".
 	operatorPredicate := predicate.And(prcts...)
 
+	// keep the objects of accepted delete events for the delete handlers
+	operatorPredicate = o.tombstones.Track(operatorPredicate)
+
+	// record the events and the decision of the operator predicate if requested
+	if o.recorder != nil {
+		operatorPredicate = predicates.Record(o.recorder, o.manager.GetScheme(), operatorPredicate)
+	}
+
 	// wrap the operator predicate in a special logging predicate so we can enable event logging
 	// by increasing the log level
 	return predicates.Log(logf.Log, false, operatorPredicate)
 }
 
 // Build is a function that builds the embedded controller.Controller part of the Operator
-// and registers the primary resource and its owned resources
-// TODO: Add "Ows" and "Owns.Predicates" if possible
+// and registers the primary resource and its owned resources. The definition of the Operator is
+// validated before, and all problems found are returned at once as a *ValidationError.
 func (o *operator) Build() error {
-	bldr := builder.
-		ControllerManagedBy(o.manager).
-		For(o.object, builder.WithPredicates(o.Predicate()))
-
-	// TODO: catch nil predicate slice
-	if len(o.ownsInput) > 0 {
-		for _, input := range o.ownsInput {
-			bldr.Owns(input.object, builder.WithPredicates(input.predicate))
-		}
+	if err := o.validate(); err != nil {
+		return err
 	}
+	gvk, err := apiutil.GVKForObject(o.object, o.manager.GetScheme())
+	if err != nil {
+		return err
+	}
+
+	// the controller is set up like a controller built with builder.ControllerManagedBy(), but it is not
+	// added to the manager right away, as it has to run on all replicas if any handler requires it
 
-	c, err := bldr.Build(o.reconcileFuncWithClient())
+	o.name = strings.ToLower(gvk.Kind)
+	c, err := o.newController(gvk, nil)
 	if err != nil {
 		return err
 	}
 
+	// triggers are enqueued regardless of the predicates of the primary resource
+	if err := c.Watch(&source.Channel{Source: o.triggerEvents}, &handler.EnqueueRequestForObject{}); err != nil {
+		return err
+	}
+	if r, ok := o.manager.(watchRecorder); ok {
+		r.RecordTriggers(o.triggerEvents)
+	}
+
+	var runnable manager.Runnable = c
+	if o.allReplicas {
+		// replicas which are not the leader skip the leader-only handlers, so all objects
+		// are reconciled again once a replica becomes the leader
+		resync := make(chan event.GenericEvent)
+		if err := c.Watch(&source.Channel{Source: resync}, &handler.EnqueueRequestForObject{}); err != nil {
+			return err
+		}
+		if err := o.manager.Add(&resyncOnElection{client: o.client, object: o.object, events: resync}); err != nil {
+			return err
+		}
+		runnable = allReplicasController{c}
+	}
+	if err := o.manager.Add(runnable); err != nil {
+		return err
+	}
+
+	if len(o.reloadables) > 0 {
+		resync := make(chan event.GenericEvent)
+		if err := c.Watch(&source.Channel{Source: resync}, &handler.EnqueueRequestForObject{}); err != nil {
+			return err
+		}
+		if err := o.manager.Add(&resyncOnChange{client: o.client, object: o.object, selectors: o.reloadables, events: resync, allReplicas: o.allReplicas}); err != nil {
+			return err
+		}
+	}
+
+	if err := o.buildClusters(gvk); err != nil {
+		return err
+	}
+
+	for _, s := range o.schedulers {
+		if err := o.manager.Add(s); err != nil {
+			return err
+		}
+	}
+
+	if err := o.registerWebhooks(gvk); err != nil {
+		return err
+	}
+
+	if o.leaseName != "" {
+		if err := o.manager.Add(&leaderReporter{lease: o.leaseName, elected: o.manager.Elected()}); err != nil {
+			return err
+		}
+	}
+
 	o.controller = c
 	return nil
 }
 
+// newController creates a controller watching the primary resource and its owned resources, which reads them from
+// the cache of the manager if c is nil
+func (o *operator) newController(gvk schema.GroupVersionKind, c cache.Cache) (controller.Controller, error) {
+	opts := o.controllerOpts
+	opts.Reconciler = o.reconcileFuncWithClient()
+	ctl, err := controller.NewUnmanaged(o.name, o.manager, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := o.watch(ctl, o.object, c, &handler.EnqueueRequestForObject{}, o.Predicate()); err != nil {
+		return nil, err
+	}
+
+	for _, input := range o.ownsInput {
+		hdl := &handler.EnqueueRequestForOwner{OwnerType: o.object, IsController: true}
+		if err := o.watch(ctl, input.object, c, hdl, input.predicate); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, input := range o.trackedOwnsInput {
+		if err := o.watch(ctl, input.object, c, ownership.EnqueueOwner(gvk), input.predicate); err != nil {
+			return nil, err
+		}
+	}
+	return ctl, nil
+}
+
+// watchRecorder is implemented by managers which route events to the controllers themselves, i.e. the fake
+// manager of lottest, which needs to know the kinds watched by the controllers and how they are mapped to requests,
+// and consumes the objects enqueued by triggers
+type watchRecorder interface {
+	RecordWatch(obj client.Object, hdl handler.EventHandler, prct ...predicate.Predicate)
+	RecordTriggers(events <-chan event.GenericEvent)
+}
+
+// watch watches the objects of the type of obj with the controller, see kindSource, and records the watch if the
+// manager is a watchRecorder
+func (o *operator) watch(ctl controller.Controller, obj client.Object, c cache.Cache, hdl handler.EventHandler, prct ...predicate.Predicate) error {
+	if r, ok := o.manager.(watchRecorder); ok && c == nil {
+		r.RecordWatch(obj, hdl, prct...)
+	}
+	return ctl.Watch(kindSource(obj, c), hdl, prct...)
+}
+
+// kindSource returns a source of the objects of the type of obj, which are read from the cache of the manager if c is nil
+func kindSource(obj client.Object, c cache.Cache) source.Source {
+	if c == nil {
+		return &source.Kind{Type: obj}
+	}
+	return source.NewKindWithCache(obj, c)
+}
+
+// buildClusters adds the clusters given with WithClusters or WithCluster to the manager, together with a controller for
+// every cluster which is set up like the controller of the operator, but uses the client and cache of its cluster
+func (o *operator) buildClusters(gvk schema.GroupVersionKind) error {
+	for _, rc := range o.clusters {
+		if err := o.manager.Add(rc.cluster); err != nil {
+			return err
+		}
+		co := *o
+		co.client = rc.client
+		co.tombstones = reconcile.NewTombstones()
+		co.cluster = rc.id
+		// triggers and schedules only apply to the cluster of the manager
+		co.triggers = nil
+		co.name = o.name + "-" + strings.ToLower(rc.id)
+		c, err := co.newController(gvk, rc.cluster.GetCache())
+		if err != nil {
+			return err
+		}
+		if err := o.manager.Add(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (o *operator) reconcileFuncWithClient() reconcile.Reconciler {
 	cl := o.client
 	obj := o.object
-	return reconcile.WithClient(cl, obj, o.manager.GetScheme(), o.reconcileHandlers)
+	opts := []reconcile.Option{reconcile.WithTombstones(o.tombstones), reconcile.WithTriggers(o.triggers)}
+	if o.tracerProvider != nil {
+		opts = append(opts, reconcile.WithTracerProvider(o.tracerProvider))
+	}
+	if o.statusUpdates {
+		opts = append(opts, reconcile.WithStatusUpdates())
+	}
+	if o.cluster != "" {
+		opts = append(opts, reconcile.WithCluster(o.cluster))
+	}
+	r := reconcile.WithClient(cl, obj, o.manager.GetScheme(), o.reconcileHandlers, opts...)
+	if len(o.trackedOwnsInput) > 0 {
+		r = o.withTrackedOwnsCleanup(r)
+	}
+	return o.drain.wrap(o.name, r)
+}
+
+// withTrackedOwnsCleanup wraps the Reconciler so that children registered with WithTrackedOwns are
+// deleted once their owner is terminating or gone
+func (o *operator) withTrackedOwnsCleanup(r reconcile.Reconciler) reconcile.Reconciler {
+	var kinds []client.Object
+	for _, input := range o.trackedOwnsInput {
+		kinds = append(kinds, input.object)
+	}
+	return crreconcile.Func(func(ctx context.Context, request crreconcile.Request) (crreconcile.Result, error) {
+		owner := o.object.DeepCopyObject().(client.Object)
+		err := o.client.Get(ctx, request.NamespacedName, owner)
+		if client.IgnoreNotFound(err) != nil {
+			return crreconcile.Result{}, err
+		}
+		gone := err != nil
+		if gone {
+			// the children of a deleted owner are identified by the UID of its last known state, which is
+			// forgotten by the reconcile, and by its kind, namespace and name without it
+			owner = o.object.DeepCopyObject().(client.Object)
+			if tombstone := o.tombstones.Get(request.NamespacedName); tombstone != nil {
+				owner = tombstone
+			}
+		}
+
+		result, err := r.Reconcile(ctx, request)
+		if err != nil || (!gone && owner.GetDeletionTimestamp() == nil) {
+			return result, err
+		}
+		ref, err := ownership.ReferenceTo(owner, o.manager.GetScheme())
+		if err != nil {
+			return result, err
+		}
+		ref.Namespace, ref.Name = request.Namespace, request.Name
+		return result, ownership.Cleanup(ctx, o.client, ref, kinds...)
+	})
 }
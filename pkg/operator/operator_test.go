@@ -1,27 +1,62 @@
 package operator_test
 
 import (
+	"bytes"
 	"context"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/SchweizerischeBundesbahnen/lot/internal/defaults"
+	"github.com/SchweizerischeBundesbahnen/lot/pkg/clusters"
 	lotClient "github.com/SchweizerischeBundesbahnen/lot/pkg/lot-client"
+	"github.com/SchweizerischeBundesbahnen/lot/pkg/lottest"
 	"github.com/SchweizerischeBundesbahnen/lot/pkg/operator"
+	"github.com/SchweizerischeBundesbahnen/lot/pkg/ownership"
+	"github.com/SchweizerischeBundesbahnen/lot/pkg/reconcile"
+	"github.com/SchweizerischeBundesbahnen/lot/pkg/selector"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	admissionv1 "k8s.io/api/admission/v1"
+	appsv1 "k8s.io/api/apps/v1"
 	v1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/event"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/yaml"
 )
 
 // when spawning multiple operator instances, prevent
 // them from creating listeners that could block each other
 var disableHealthAndMetricEndpoint operator.ConstructorOption = operator.WithManagerOptions(&manager.Options{MetricsBindAddress: "0", HealthProbeBindAddress: "0"})
 
+// keepingExporter keeps the exported spans on shutdown, so that they can be checked afterwards
+type keepingExporter struct {
+	*tracetest.InMemoryExporter
+}
+
+func (keepingExporter) Shutdown(context.Context) error {
+	return nil
+}
+
 var _ = Describe("operator", func() {
 	Describe("When creating a new operator", func() {
 
@@ -50,6 +85,13 @@ var _ = Describe("operator", func() {
 				Expect(o).To(BeNil())
 			}
 		})
+		It("should return error if combining WithManager and WithManagerOptions", func() {
+			env := lottest.New(nil)
+			opts := manager.Options{MetricsBindAddress: ":9090"}
+			o, err := operator.New(&v1.Secret{}, env.WithManager(), operator.WithManagerOptions(&opts))
+			Expect(err).To(HaveOccurred())
+			Expect(o).To(BeNil())
+		})
 		It("should permit giving certain opts functions multiple times", func() {
 			var o operator.Operator
 			var err error
@@ -64,9 +106,1291 @@ var _ = Describe("operator", func() {
 				Expect(o).ToNot(BeNil())
 			}
 		})
+		It("should build with children tracked across namespaces", func() {
+			p := predicate.NewPredicateFuncs(func(object client.Object) bool {
+				return true
+			})
+			o, err := operator.New(&v1.Secret{}, disableHealthAndMetricEndpoint,
+				operator.WithTrackedOwns(&rbacv1.ClusterRoleBinding{}, p), operator.WithTrackedOwns(&v1.ConfigMap{}, p))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(o).ToNot(BeNil())
+			Expect(o.Build()).To(Succeed())
+		})
+		It("should delete the tracked children once their owner is deleted", func() {
+			owner := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "biz", Name: "baz", UID: "uid-1"}}
+			child := &rbacv1.ClusterRoleBinding{ObjectMeta: metav1.ObjectMeta{Name: "biz-baz"}}
+			Expect(ownership.SetOwner(owner, child, clientgoscheme.Scheme)).To(Succeed())
+			env := lottest.New(nil, owner, child)
+			o, err := operator.New(&v1.Secret{}, env.WithManager(), operator.WithTrackedOwns(&rbacv1.ClusterRoleBinding{}, predicate.Funcs{}))
+			Expect(err).ToNot(HaveOccurred())
+			o.OnCreateOrUpdate(func(ctx context.Context, object client.Object, cl lotClient.Client, scheme *runtime.Scheme) error {
+				return nil
+			})
+			Expect(env.Build(o)).To(Succeed())
+
+			Expect(env.Reconcile(context.Background(), client.ObjectKeyFromObject(owner)).Err).NotTo(HaveOccurred())
+			Expect(env.Client().Get(context.Background(), client.ObjectKeyFromObject(child), child)).To(Succeed())
+
+			Expect(env.Delete(context.Background(), owner)).To(Succeed())
+			Expect(env.Events()[0].Accepted).To(BeTrue())
+			err = env.Client().Get(context.Background(), client.ObjectKeyFromObject(child), child)
+			Expect(apierrors.IsNotFound(err)).To(BeTrue())
+		})
+		Describe("with namespaces", func() {
+			p := predicate.NewPredicateFuncs(func(object client.Object) bool {
+				return true
+			})
+			AfterEach(func() {
+				Expect(os.Unsetenv("POD_NAMESPACE")).To(Succeed())
+			})
+			It("should build with namespaced kinds in several namespaces", func() {
+				o, err := operator.New(&v1.Secret{}, disableHealthAndMetricEndpoint, operator.WithNamespaces("biz", "baz"),
+					operator.WithOwns(&v1.ConfigMap{}, p))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(o.Build()).To(Succeed())
+			})
+			It("should build in its own namespace", func() {
+				Expect(os.Setenv("POD_NAMESPACE", "biz")).To(Succeed())
+				o, err := operator.New(&v1.Secret{}, disableHealthAndMetricEndpoint, operator.WithOwnNamespace())
+				Expect(err).NotTo(HaveOccurred())
+				Expect(o.Build()).To(Succeed())
+			})
+			It("should not watch cluster-scoped kinds", func() {
+				o, err := operator.New(&rbacv1.ClusterRoleBinding{}, disableHealthAndMetricEndpoint, operator.WithNamespaces("biz"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(o.Build()).To(MatchError(ContainSubstring("ClusterRoleBinding is cluster-scoped")))
+			})
+			It("should reject cluster-scoped kinds owned by namespaced ones", func() {
+				env := lottest.New(nil)
+				o, err := operator.New(&v1.Secret{}, env.WithManager(), operator.WithOwns(&rbacv1.ClusterRole{}, p))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(env.Build(o)).To(MatchError(ContainSubstring("WithTrackedOwns")))
+
+				o, err = operator.New(&rbacv1.ClusterRole{}, lottest.New(nil).WithManager(), operator.WithOwns(&rbacv1.ClusterRoleBinding{}, p))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(o.Build()).To(Succeed())
+			})
+			It("should reject cluster-scoped tracked kinds", func() {
+				o, err := operator.New(&v1.Secret{}, disableHealthAndMetricEndpoint, operator.WithNamespaces("biz"),
+					operator.WithTrackedOwns(&rbacv1.ClusterRoleBinding{}, p))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(o.Build()).To(MatchError(ContainSubstring("WithTrackedOwns(...) cannot watch the cluster-scoped ClusterRoleBinding")))
+
+				o, err = operator.New(&v1.Secret{}, disableHealthAndMetricEndpoint, operator.WithNamespaces("biz"),
+					operator.WithTrackedOwns(&v1.ConfigMap{}, p))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(o.Build()).To(Succeed())
+			})
+			It("should reject invalid namespaces", func() {
+				_, err := operator.New(&v1.Secret{}, disableHealthAndMetricEndpoint, operator.WithNamespaces())
+				Expect(err).To(HaveOccurred())
+				_, err = operator.New(&v1.Secret{}, disableHealthAndMetricEndpoint, operator.WithNamespaces("Not_Valid"))
+				Expect(err).To(HaveOccurred())
+				_, err = operator.New(&v1.Secret{}, lottest.New(nil).WithManager(), operator.WithNamespaces("biz"))
+				Expect(err).To(HaveOccurred())
+				_, err = operator.New(&v1.Secret{}, operator.WithManagerOptions(&manager.Options{Namespace: "biz"}), operator.WithNamespaces("baz"))
+				Expect(err).To(HaveOccurred())
+			})
+			It("should return error if its own namespace cannot be detected", func() {
+				detect := defaults.Namespace
+				defer func() { defaults.Namespace = detect }()
+				defaults.Namespace = func() (string, error) { return "", errors.New("not running in-cluster") }
+				_, err := operator.New(&v1.Secret{}, disableHealthAndMetricEndpoint, operator.WithOwnNamespace())
+				Expect(err).To(MatchError(ContainSubstring("not running in-cluster")))
+			})
+		})
+		Describe("with leader election", func() {
+			AfterEach(func() {
+				Expect(os.Unsetenv("POD_NAMESPACE")).To(Succeed())
+			})
+			It("should return success if the namespace can be detected", func() {
+				Expect(os.Setenv("POD_NAMESPACE", "biz")).To(Succeed())
+				o, err := operator.New(&v1.Secret{}, disableHealthAndMetricEndpoint, operator.WithLeaderElection())
+				Expect(err).ToNot(HaveOccurred())
+				Expect(o).ToNot(BeNil())
+				Expect(o.Build()).To(Succeed())
+			})
+			It("should return error if the namespace cannot be detected", func() {
+				detect := defaults.Namespace
+				defer func() { defaults.Namespace = detect }()
+				defaults.Namespace = func() (string, error) { return "", errors.New("not running in-cluster") }
+				o, err := operator.New(&v1.Secret{}, disableHealthAndMetricEndpoint, operator.WithLeaderElection())
+				Expect(err).To(HaveOccurred())
+				Expect(o).To(BeNil())
+			})
+			It("should return success if the namespace is given in the manager options", func() {
+				opts := manager.Options{MetricsBindAddress: "0", HealthProbeBindAddress: "0", LeaderElectionNamespace: "biz"}
+				o, err := operator.New(&v1.Secret{}, operator.WithManagerOptions(&opts), operator.WithLeaderElection())
+				Expect(err).ToNot(HaveOccurred())
+				Expect(o).ToNot(BeNil())
+			})
+			It("should return error if combined with WithManager", func() {
+				Expect(os.Setenv("POD_NAMESPACE", "biz")).To(Succeed())
+				env := lottest.New(nil)
+				o, err := operator.New(&v1.Secret{}, env.WithManager(), operator.WithLeaderElection())
+				Expect(err).To(HaveOccurred())
+				Expect(o).To(BeNil())
+			})
+			Describe("when running handlers", func() {
+				var env *lottest.Environment
+				var o operator.Operator
+				var handled []string
+				var secret *v1.Secret
+				BeforeEach(func() {
+					handled = nil
+					secret = &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "biz", Name: "baz"}}
+					env = lottest.New(nil)
+				})
+				JustBeforeEach(func() {
+					var err error
+					o, err = operator.New(&v1.Secret{}, env.WithManager())
+					Expect(err).NotTo(HaveOccurred())
+					o.OnCreateOrUpdate(func(ctx context.Context, object client.Object, cl lotClient.Client, scheme *runtime.Scheme) error {
+						handled = append(handled, "leader")
+						return nil
+					})
+					o.OnCreateOrUpdate(func(ctx context.Context, object client.Object, cl lotClient.Client, scheme *runtime.Scheme) error {
+						handled = append(handled, "all")
+						return nil
+					}, operator.WithAllReplicas())
+					Expect(env.Build(o)).To(Succeed())
+				})
+				Context("on the leader", func() {
+					It("should run all handlers", func() {
+						Expect(env.Create(context.Background(), secret)).To(Succeed())
+						Expect(handled).To(ConsistOf("leader", "all"))
+					})
+				})
+				Context("on other replicas", func() {
+					BeforeEach(func() {
+						env.SetElected(false)
+					})
+					It("should only run the handlers marked for all replicas", func() {
+						Expect(env.Create(context.Background(), secret)).To(Succeed())
+						Expect(handled).To(ConsistOf("all"))
+
+						By("becoming the leader")
+						handled = nil
+						env.SetElected(true)
+						env.Reconcile(context.Background(), client.ObjectKeyFromObject(secret))
+						Expect(handled).To(ConsistOf("leader", "all"))
+					})
+				})
+			})
+		})
+		Describe("when shutting down", func() {
+			var env *lottest.Environment
+			var o operator.Operator
+			var secret *v1.Secret
+			var running, release chan struct{}
+			var handlerCtx context.Context
+			var drainTimeout time.Duration
+			BeforeEach(func() {
+				running, release = make(chan struct{}), make(chan struct{})
+				secret = &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "biz", Name: "baz"}}
+				env = lottest.New(nil, secret)
+			})
+			JustBeforeEach(func() {
+				var err error
+				o, err = operator.New(&v1.Secret{}, env.WithManager(), operator.WithDrainTimeout(drainTimeout))
+				Expect(err).NotTo(HaveOccurred())
+				o.OnCreateOrUpdate(func(ctx context.Context, object client.Object, cl lotClient.Client, scheme *runtime.Scheme) error {
+					handlerCtx = ctx
+					close(running)
+					select {
+					case <-release:
+						return cl.Update(ctx, object)
+					case <-ctx.Done():
+						return ctx.Err()
+					}
+				})
+			})
+			// start runs the operator and a reconcile of the secret, and stops the operator
+			// while the handler is in flight
+			start := func() (chan error, chan lottest.Result) {
+				ctx, cancel := context.WithCancel(context.Background())
+				stopped, results := make(chan error, 1), make(chan lottest.Result, 1)
+				go func() {
+					stopped <- o.StartWithContext(ctx)
+				}()
+				Eventually(env.Started()).Should(BeClosed())
+				go func() {
+					results <- env.Reconcile(context.Background(), client.ObjectKeyFromObject(secret))
+				}()
+				Eventually(running).Should(BeClosed())
+				cancel()
+				return stopped, results
+			}
+			Context("with a drain timeout", func() {
+				BeforeEach(func() {
+					drainTimeout = time.Minute
+				})
+				It("should let handlers in flight finish", func() {
+					stopped, results := start()
+					Consistently(handlerCtx.Done(), "200ms").ShouldNot(BeClosed())
+					Consistently(stopped, "200ms").ShouldNot(Receive())
+
+					close(release)
+					Eventually(results).Should(Receive(HaveField("Err", BeNil())))
+					Eventually(stopped).Should(Receive(BeNil()))
+				})
+			})
+			Context("when the drain timeout is exceeded", func() {
+				BeforeEach(func() {
+					drainTimeout = 100 * time.Millisecond
+				})
+				It("should cancel the handlers in flight", func() {
+					stopped, results := start()
+					Eventually(results).Should(Receive(HaveField("Err", MatchError(context.Canceled))))
+					Eventually(stopped).Should(Receive(BeNil()))
+				})
+			})
+			It("should reject a negative drain timeout", func() {
+				o, err := operator.New(&v1.Secret{}, env.WithManager(), operator.WithDrainTimeout(-time.Second))
+				Expect(err).To(HaveOccurred())
+				Expect(o).To(BeNil())
+			})
+		})
+		Describe("with limits", func() {
+			It("should build with workers and rate limiters", func() {
+				env := lottest.New(nil)
+				o, err := operator.New(&v1.Secret{}, env.WithManager(), operator.WithMaxConcurrentReconciles(4),
+					operator.WithRateLimiter(operator.ExponentialRateLimiter(time.Millisecond, time.Minute), operator.TokenBucketRateLimiter(10, 100)))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(env.Build(o)).To(Succeed())
+			})
+			It("should reject invalid limits", func() {
+				env := lottest.New(nil)
+				_, err := operator.New(&v1.Secret{}, env.WithManager(), operator.WithMaxConcurrentReconciles(0))
+				Expect(err).To(HaveOccurred())
+				_, err = operator.New(&v1.Secret{}, env.WithManager(), operator.WithRateLimiter())
+				Expect(err).To(HaveOccurred())
+
+				o, err := operator.New(&v1.Secret{}, env.WithManager())
+				Expect(err).NotTo(HaveOccurred())
+				o.OnDelete(nil, operator.WithRateLimit(0, 1))
+				Expect(o.Start()).To(HaveOccurred())
+			})
+			It("should run the other handlers while one handler is throttled", func() {
+				secret := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "biz", Name: "baz"}}
+				env := lottest.New(nil)
+				o, err := operator.New(&v1.Secret{}, env.WithManager())
+				Expect(err).NotTo(HaveOccurred())
+				var handled []string
+				o.OnCreateOrUpdate(func(ctx context.Context, object client.Object, cl lotClient.Client, scheme *runtime.Scheme) error {
+					handled = append(handled, "limited")
+					return nil
+				}, operator.WithRateLimit(0.001, 1))
+				o.OnCreateOrUpdate(func(ctx context.Context, object client.Object, cl lotClient.Client, scheme *runtime.Scheme) error {
+					handled = append(handled, "unlimited")
+					return nil
+				})
+				Expect(env.Build(o)).To(Succeed())
+
+				Expect(env.Create(context.Background(), secret)).To(Succeed())
+				Expect(handled).To(Equal([]string{"limited", "unlimited"}))
+
+				handled = nil
+				result := env.Reconcile(context.Background(), client.ObjectKeyFromObject(secret))
+				Expect(handled).To(Equal([]string{"unlimited"}))
+				Expect(result.Err).NotTo(HaveOccurred())
+				Expect(result.RequeueAfter).To(BeNumerically(">", time.Minute))
+			})
+		})
+		Describe("with timeouts", func() {
+			var env *lottest.Environment
+			var secret *v1.Secret
+			var deadlines []bool
+			handler := func(ctx context.Context, object client.Object, cl lotClient.Client, scheme *runtime.Scheme) error {
+				_, ok := ctx.Deadline()
+				deadlines = append(deadlines, ok)
+				if !ok {
+					return nil
+				}
+				<-ctx.Done()
+				return ctx.Err()
+			}
+			BeforeEach(func() {
+				deadlines = nil
+				secret = &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "biz", Name: "baz"}}
+				env = lottest.New(nil)
+			})
+			It("should fail handlers exceeding their timeout", func() {
+				o, err := operator.New(&v1.Secret{}, env.WithManager())
+				Expect(err).NotTo(HaveOccurred())
+				o.OnCreateOrUpdate(handler, operator.WithTimeout(10*time.Millisecond))
+				Expect(env.Build(o)).To(Succeed())
+
+				Expect(env.Create(context.Background(), secret)).To(Succeed())
+				Expect(env.Results()).To(ConsistOf(HaveField("Err", MatchError(context.DeadlineExceeded))))
+				Expect(reconcile.IsTimeout(env.Results()[0].Err)).To(BeTrue())
+
+				families, err := metrics.Registry.Gather()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(families).To(ContainElement(HaveField("Name", HaveValue(Equal("lot_handler_timeouts_total")))))
+			})
+			It("should apply the default timeout unless the handler sets its own", func() {
+				o, err := operator.New(&v1.Secret{}, env.WithManager(), operator.WithDefaultTimeout(10*time.Millisecond))
+				Expect(err).NotTo(HaveOccurred())
+				o.OnCreateOrUpdate(handler, operator.WithTimeout(0))
+				o.OnCreateOrUpdate(handler)
+				Expect(env.Build(o)).To(Succeed())
+
+				Expect(env.Create(context.Background(), secret)).To(Succeed())
+				Expect(deadlines).To(Equal([]bool{false, true}))
+				Expect(reconcile.IsTimeout(env.Results()[0].Err)).To(BeTrue())
+			})
+			It("should reject invalid timeouts", func() {
+				_, err := operator.New(&v1.Secret{}, env.WithManager(), operator.WithDefaultTimeout(0))
+				Expect(err).To(HaveOccurred())
+
+				o, err := operator.New(&v1.Secret{}, env.WithManager())
+				Expect(err).NotTo(HaveOccurred())
+				o.OnCreateOrUpdate(handler, operator.WithTimeout(-time.Second))
+				Expect(o.Start()).To(HaveOccurred())
+			})
+		})
+		Describe("with middlewares", func() {
+			It("should wrap the handlers with the global and their own middlewares", func() {
+				var calls []string
+				trace := func(name string) reconcile.Middleware {
+					return func(fn reconcile.Handler) reconcile.Handler {
+						return func(ctx context.Context, object client.Object, cl lotClient.Client, scheme *runtime.Scheme) error {
+							calls = append(calls, name+" "+reconcile.HandlerNameFromContext(ctx))
+							return fn(ctx, object, cl, scheme)
+						}
+					}
+				}
+				env := lottest.New(nil)
+				o, err := operator.New(&v1.Secret{}, env.WithManager(), operator.WithMiddleware(trace("global"), reconcile.Recover()))
+				Expect(err).NotTo(HaveOccurred())
+				o.OnCreateOrUpdate(func(ctx context.Context, object client.Object, cl lotClient.Client, scheme *runtime.Scheme) error {
+					return nil
+				})
+				o.OnCreateOrUpdate(func(ctx context.Context, object client.Object, cl lotClient.Client, scheme *runtime.Scheme) error {
+					panic("boom")
+				}, operator.WithHandlerMiddleware(trace("own")))
+				Expect(env.Build(o)).To(Succeed())
+
+				secret := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "biz", Name: "baz"}}
+				Expect(env.Create(context.Background(), secret)).To(Succeed())
+				Expect(calls).To(Equal([]string{"global create_or_update", "global create_or_update-2", "own create_or_update-2"}))
+				Expect(env.Results()).To(ConsistOf(lottest.HaveFailed()))
+			})
+		})
+		Describe("with tracing", func() {
+			It("should trace reconciles, handlers and client calls", func() {
+				recorder := tracetest.NewSpanRecorder()
+				tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+				env := lottest.New(nil)
+				o, err := operator.New(&v1.Secret{}, env.WithManager(), operator.WithTracerProvider(tp))
+				Expect(err).NotTo(HaveOccurred())
+				o.OnCreateOrUpdate(func(ctx context.Context, object client.Object, cl lotClient.Client, scheme *runtime.Scheme) error {
+					return cl.Update(ctx, object)
+				})
+				Expect(env.Build(o)).To(Succeed())
+
+				secret := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "biz", Name: "baz"}}
+				Expect(env.Create(context.Background(), secret)).To(Succeed())
+
+				spans := map[string]sdktrace.ReadOnlySpan{}
+				for _, span := range recorder.Ended() {
+					spans[span.Name()] = span
+				}
+				Expect(spans).To(HaveKey("Reconcile"))
+				Expect(spans["Reconcile"].Parent().IsValid()).To(BeFalse())
+				Expect(spans["Reconcile"].Attributes()).To(ContainElements(
+					lotClient.GVKKey.String("/v1, Kind=Secret"), lotClient.NamespaceKey.String("biz"), lotClient.NameKey.String("baz")))
+				Expect(spans["Get"].Parent().SpanID()).To(Equal(spans["Reconcile"].SpanContext().SpanID()))
+				Expect(spans["Handler create_or_update"].Parent().SpanID()).To(Equal(spans["Reconcile"].SpanContext().SpanID()))
+				Expect(spans["Update"].Parent().SpanID()).To(Equal(spans["Handler create_or_update"].SpanContext().SpanID()))
+			})
+			It("should flush the spans of the trace exporter on shutdown", func() {
+				exporter := keepingExporter{tracetest.NewInMemoryExporter()}
+				env := lottest.New(nil)
+				o, err := operator.New(&v1.Secret{}, env.WithManager(), operator.WithTraceExporter(exporter))
+				Expect(err).NotTo(HaveOccurred())
+				o.OnCreateOrUpdate(func(ctx context.Context, object client.Object, cl lotClient.Client, scheme *runtime.Scheme) error {
+					return nil
+				})
+
+				ctx, cancel := context.WithCancel(context.Background())
+				stopped := make(chan error, 1)
+				go func() {
+					stopped <- o.StartWithContext(ctx)
+				}()
+				Eventually(env.Started()).Should(BeClosed())
+				secret := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "biz", Name: "baz"}}
+				Expect(env.Client().Create(context.Background(), secret)).To(Succeed())
+				env.Reconcile(context.Background(), client.ObjectKeyFromObject(secret))
+				cancel()
+
+				Eventually(stopped).Should(Receive(BeNil()))
+				Expect(exporter.GetSpans()).To(ContainElement(HaveField("Name", "Reconcile")))
+			})
+			It("should reject several tracer providers", func() {
+				_, err := operator.New(&v1.Secret{}, lottest.New(nil).WithManager(),
+					operator.WithTracerProvider(sdktrace.NewTracerProvider()), operator.WithTraceExporter(tracetest.NewInMemoryExporter()))
+				Expect(err).To(HaveOccurred())
+			})
+		})
+		Describe("with several handlers per event type", func() {
+			var env *lottest.Environment
+			var handled []string
+			var mu sync.Mutex
+			record := func(name string) reconcile.Handler {
+				return func(ctx context.Context, object client.Object, cl lotClient.Client, scheme *runtime.Scheme) error {
+					mu.Lock()
+					defer mu.Unlock()
+					handled = append(handled, name+"/"+reconcile.HandlerNameFromContext(ctx))
+					return nil
+				}
+			}
+			BeforeEach(func() {
+				handled = nil
+				env = lottest.New(nil)
+			})
+			It("should run the matching handlers in the order they have been registered", func() {
+				o, err := operator.New(&v1.Secret{}, env.WithManager())
+				Expect(err).NotTo(HaveOccurred())
+				o.OnCreateOrUpdate(record("first"))
+				o.OnCreateOrUpdate(record("foo"), operator.WithLabels(map[string]string{"foo": "bar"}), operator.WithName("foo"))
+				o.OnCreateOrUpdate(record("other"), operator.WithLabels(map[string]string{"other": selector.KeyPresent()}))
+				o.OnCreateOrUpdate(record("last"))
+				Expect(env.Build(o)).To(Succeed())
+
+				secret := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "biz", Name: "baz", Labels: map[string]string{"foo": "bar"}}}
+				Expect(env.Create(context.Background(), secret)).To(Succeed())
+				Expect(handled).To(Equal([]string{"first/create_or_update", "foo/foo", "last/create_or_update-4"}))
+			})
+			It("should run the matching handlers in parallel", func() {
+				o, err := operator.New(&v1.Secret{}, env.WithManager(), operator.WithParallelHandlers())
+				Expect(err).NotTo(HaveOccurred())
+				o.OnCreateOrUpdate(record("first"))
+				o.OnCreateOrUpdate(record("second"))
+				o.OnCreateOrUpdate(record("ignored"), operator.WithAnnotations(map[string]string{"foo": "bar"}))
+				Expect(env.Build(o)).To(Succeed())
+
+				secret := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "biz", Name: "baz"}}
+				Expect(env.Create(context.Background(), secret)).To(Succeed())
+				Expect(handled).To(ConsistOf("first/create_or_update", "second/create_or_update-2"))
+			})
+			It("should stop at the first failing handler", func() {
+				o, err := operator.New(&v1.Secret{}, env.WithManager())
+				Expect(err).NotTo(HaveOccurred())
+				o.OnCreateOrUpdate(func(ctx context.Context, object client.Object, cl lotClient.Client, scheme *runtime.Scheme) error {
+					return errors.New("failure")
+				})
+				o.OnCreateOrUpdate(record("second"))
+				Expect(env.Build(o)).To(Succeed())
+
+				secret := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "biz", Name: "baz"}}
+				Expect(env.Create(context.Background(), secret)).To(Succeed())
+				Expect(handled).To(BeEmpty())
+				Expect(env.Results()).To(ConsistOf(lottest.HaveFailed()))
+			})
+			It("should reject duplicate names", func() {
+				o, err := operator.New(&v1.Secret{}, env.WithManager())
+				Expect(err).NotTo(HaveOccurred())
+				o.OnCreateOrUpdate(record("first"), operator.WithName("same"))
+				o.OnDelete(record("second"), operator.WithName("same"))
+				Expect(o.Start()).To(MatchError(ContainSubstring(`"same"`)))
+			})
+		})
+		Describe("with triggers", func() {
+			var env *lottest.Environment
+			var handled []string
+			var secret *v1.Secret
+			key := types.NamespacedName{Namespace: "biz", Name: "baz"}
+			record := func(name string) reconcile.Handler {
+				return func(ctx context.Context, object client.Object, cl lotClient.Client, scheme *runtime.Scheme) error {
+					payload, _ := reconcile.TriggerPayload[string](ctx)
+					handled = append(handled, name+"/"+payload)
+					return nil
+				}
+			}
+			BeforeEach(func() {
+				handled = nil
+				secret = &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "biz", Name: "baz"}}
+				env = lottest.New(nil, secret)
+			})
+			It("should pass the payload to the trigger handlers before running the create or update handlers", func() {
+				o, err := operator.New(&v1.Secret{}, env.WithManager(), operator.WithCustomPredicate(predicate.NewPredicateFuncs(func(client.Object) bool {
+					return false
+				})))
+				Expect(err).NotTo(HaveOccurred())
+				o.OnTrigger(record("trigger"))
+				o.OnTrigger(record("other"), operator.WithLabels(map[string]string{"foo": "bar"}))
+				o.OnCreateOrUpdate(record("createOrUpdate"))
+				Expect(env.Build(o)).To(Succeed())
+
+				result, err := env.Trigger(context.Background(), key, "foo")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result).NotTo(lottest.HaveFailed())
+				Expect(handled).To(Equal([]string{"trigger/foo", "createOrUpdate/"}))
+			})
+			It("should pass every trigger sent before the reconcile in order", func() {
+				o, err := operator.New(&v1.Secret{}, env.WithManager())
+				Expect(err).NotTo(HaveOccurred())
+				o.OnTrigger(record("trigger"))
+				Expect(env.Build(o)).To(Succeed())
+
+				Expect(o.Trigger(context.Background(), key, "foo")).To(Succeed())
+				Expect(o.Trigger(context.Background(), key, "bar")).To(Succeed())
+				env.Reconcile(context.Background(), key)
+				Expect(handled).To(Equal([]string{"trigger/foo", "trigger/bar"}))
+
+				handled = nil
+				env.Reconcile(context.Background(), key)
+				Expect(handled).To(BeEmpty())
+			})
+			It("should keep triggers until the trigger handlers succeed", func() {
+				o, err := operator.New(&v1.Secret{}, env.WithManager())
+				Expect(err).NotTo(HaveOccurred())
+				failing := true
+				o.OnTrigger(func(ctx context.Context, object client.Object, cl lotClient.Client, scheme *runtime.Scheme) error {
+					payload, _ := reconcile.TriggerPayload[string](ctx)
+					handled = append(handled, payload)
+					if failing {
+						return errors.New("failure")
+					}
+					return nil
+				})
+				Expect(env.Build(o)).To(Succeed())
+
+				result, err := env.Trigger(context.Background(), key, "foo")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result).To(lottest.HaveFailed())
+
+				failing = false
+				_, err = env.Trigger(context.Background(), key, "bar")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(handled).To(Equal([]string{"foo", "foo", "bar"}))
+			})
+			It("should discard the triggers of objects which are gone", func() {
+				o, err := operator.New(&v1.Secret{}, env.WithManager())
+				Expect(err).NotTo(HaveOccurred())
+				o.OnTrigger(record("trigger"))
+				Expect(env.Build(o)).To(Succeed())
+
+				_, err = env.Trigger(context.Background(), types.NamespacedName{Namespace: "biz", Name: "missing"}, "foo")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(handled).To(BeEmpty())
+			})
+			It("should not accept events of the primary resource with trigger handlers only", func() {
+				o, err := operator.New(&v1.Secret{}, env.WithManager())
+				Expect(err).NotTo(HaveOccurred())
+				o.OnTrigger(record("trigger"))
+				Expect(env.Build(o)).To(Succeed())
+
+				other := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "biz", Name: "other"}}
+				Expect(env.Create(context.Background(), other)).To(Succeed())
+				Expect(env.Events()).To(ConsistOf(HaveField("Accepted", BeFalse())))
+			})
+		})
+		Describe("with schedules", func() {
+			var env *lottest.Environment
+			var mu sync.Mutex
+			var handled []string
+			var matching, other *v1.Secret
+			BeforeEach(func() {
+				handled = nil
+				matching = &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "biz", Name: "matching", Labels: map[string]string{"foo": "bar"}}}
+				other = &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "biz", Name: "other"}}
+				env = lottest.New(nil, matching, other)
+			})
+			It("should run the scheduled handler for the matching objects whenever the schedule is due", func() {
+				o, err := operator.New(&v1.Secret{}, env.WithManager())
+				Expect(err).NotTo(HaveOccurred())
+				o.OnSchedule("50ms", func(ctx context.Context, object client.Object, cl lotClient.Client, scheme *runtime.Scheme) error {
+					due, ok := reconcile.TriggerPayload[time.Time](ctx)
+					Expect(ok).To(BeTrue())
+					Expect(due).To(BeTemporally("<=", time.Now()))
+					mu.Lock()
+					defer mu.Unlock()
+					handled = append(handled, object.GetName())
+					return nil
+				}, operator.WithLabels(map[string]string{"foo": "bar"}), operator.WithJitter(0))
+
+				ctx, cancel := context.WithCancel(context.Background())
+				defer cancel()
+				go func() {
+					defer GinkgoRecover()
+					Expect(o.StartWithContext(ctx)).To(Succeed())
+				}()
+				Eventually(env.Started()).Should(BeClosed())
+
+				reconciled := func() []string {
+					env.Reconcile(context.Background(), client.ObjectKeyFromObject(matching))
+					env.Reconcile(context.Background(), client.ObjectKeyFromObject(other))
+					mu.Lock()
+					defer mu.Unlock()
+					return handled
+				}
+				Eventually(reconciled).Should(ContainElement("matching"))
+				Expect(reconciled()).NotTo(ContainElement("other"))
+
+				families, err := metrics.Registry.Gather()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(families).To(ContainElement(HaveField("Name", HaveValue(Equal("lot_schedule_lag_seconds")))))
+			})
+			It("should not accept events of the primary resource with scheduled handlers only", func() {
+				o, err := operator.New(&v1.Secret{}, env.WithManager())
+				Expect(err).NotTo(HaveOccurred())
+				o.OnSchedule("@hourly", nil)
+				Expect(env.Build(o)).To(Succeed())
+
+				Expect(env.Update(context.Background(), matching)).To(Succeed())
+				Expect(env.Events()).To(ConsistOf(HaveField("Accepted", BeFalse())))
+			})
+			It("should reject invalid schedules", func() {
+				o, err := operator.New(&v1.Secret{}, env.WithManager())
+				Expect(err).NotTo(HaveOccurred())
+				o.OnSchedule("every now and then", nil)
+				Expect(o.Start()).To(MatchError(ContainSubstring("every now and then")))
+			})
+		})
+		Describe("with admission webhooks", func() {
+			var env *lottest.Environment
+			var secret *v1.Secret
+			BeforeEach(func() {
+				env = lottest.New(nil)
+				secret = &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "biz", Name: "baz", Labels: map[string]string{"foo": "bar"}}}
+			})
+			It("should validate and mutate the matching objects", func() {
+				o, err := operator.New(&v1.Secret{}, env.WithManager())
+				Expect(err).NotTo(HaveOccurred())
+				o.OnValidate(func(ctx context.Context, object, old client.Object, cl lotClient.Client) error {
+					if _, ok := object.GetAnnotations()["required"]; !ok {
+						return errors.New(`the annotation "required" is missing`)
+					}
+					return nil
+				}, operator.WithLabels(map[string]string{"foo": "bar"}))
+				o.OnMutate(func(ctx context.Context, object client.Object, cl lotClient.Client) error {
+					object.SetAnnotations(map[string]string{"required": "defaulted"})
+					return nil
+				}, operator.WithAnnotations(map[string]string{"default": selector.KeyPresent()}))
+				Expect(env.Build(o)).To(Succeed())
+
+				req, err := env.AdmissionRequest(admissionv1.Create, secret, nil)
+				Expect(err).NotTo(HaveOccurred())
+				resp := env.Validate(context.Background(), req)
+				Expect(resp.Allowed).To(BeFalse())
+				Expect(resp.Result.Message).To(ContainSubstring(`"required" is missing`))
+
+				// the mutator only matches secrets with the annotation "default"
+				Expect(env.Mutate(context.Background(), req).Patch).To(BeEmpty())
+				secret.Annotations = map[string]string{"default": ""}
+				req, err = env.AdmissionRequest(admissionv1.Create, secret, nil)
+				Expect(err).NotTo(HaveOccurred())
+				resp = env.Mutate(context.Background(), req)
+				Expect(resp.Allowed).To(BeTrue())
+
+				mutated := &v1.Secret{}
+				Expect(lottest.Mutated(req, resp, mutated)).To(Succeed())
+				Expect(mutated.Annotations).To(HaveKeyWithValue("required", "defaulted"))
+				req, err = env.AdmissionRequest(admissionv1.Create, mutated, nil)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(env.Validate(context.Background(), req).Allowed).To(BeTrue())
+			})
+			It("should not serve webhooks without validators or mutators", func() {
+				o, err := operator.New(&v1.Secret{}, env.WithManager())
+				Expect(err).NotTo(HaveOccurred())
+				o.OnCreateOrUpdate(func(ctx context.Context, object client.Object, cl lotClient.Client, scheme *runtime.Scheme) error {
+					return nil
+				})
+				Expect(env.Build(o)).To(Succeed())
+
+				req, err := env.AdmissionRequest(admissionv1.Create, secret, nil)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(env.Validate(context.Background(), req).Result.Code).To(BeEquivalentTo(http.StatusNotFound))
+			})
+			It("should reject duplicate names", func() {
+				o, err := operator.New(&v1.Secret{}, env.WithManager())
+				Expect(err).NotTo(HaveOccurred())
+				o.OnCreateOrUpdate(nil, operator.WithName("same"))
+				o.OnValidate(nil, operator.WithName("same"))
+				Expect(o.Start()).To(MatchError(ContainSubstring(`"same"`)))
+			})
+			It("should reject invalid webhook options", func() {
+				_, err := operator.New(&v1.Secret{}, env.WithManager(), operator.WithWebhookPort(0))
+				Expect(err).To(HaveOccurred())
+				_, err = operator.New(&v1.Secret{}, env.WithManager(), operator.WithWebhookCertificates("", "tls.crt", "tls.key"))
+				Expect(err).To(HaveOccurred())
+			})
+		})
+		Describe("with an invalid definition", func() {
+			noop := func(ctx context.Context, object client.Object, cl lotClient.Client, scheme *runtime.Scheme) error {
+				return nil
+			}
+			It("should report all problems when built", func() {
+				sch := runtime.NewScheme()
+				Expect(v1.AddToScheme(sch)).To(Succeed())
+				env := lottest.New(sch)
+				book := &unstructured.Unstructured{}
+				book.SetGroupVersionKind(schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Book"})
+				o, err := operator.New(&v1.Secret{}, env.WithManager(),
+					operator.WithOwns(&v1.ConfigMap{}, nil),
+					operator.WithOwns(&v1.ConfigMap{}, predicate.Funcs{}),
+					operator.WithOwns(&appsv1.Deployment{}, predicate.Funcs{}),
+					operator.WithTrackedOwns(book, predicate.Funcs{}))
+				Expect(err).NotTo(HaveOccurred())
+				o.OnCreateOrUpdate(nil, operator.WithName("same"))
+				o.OnDelete(noop, operator.WithName("same"))
+				o.OnValidate(nil)
+
+				err = o.Build()
+				var invalid *operator.ValidationError
+				Expect(errors.As(err, &invalid)).To(BeTrue())
+				Expect(invalid.Problems).To(HaveLen(7))
+				Expect(err).To(SatisfyAll(
+					MatchError(ContainSubstring(`a handler named "same" is already registered`)),
+					MatchError(ContainSubstring("handlers[same]: Required value: OnCreateOrUpdate(...) requires a handler function")),
+					MatchError(ContainSubstring("handlers[validate]: Required value: OnValidate(...) requires a validator")),
+					MatchError(ContainSubstring("owns[0].predicate: Required value")),
+					MatchError(ContainSubstring(`owns[1].object: Duplicate value: "/v1, Kind=ConfigMap"`)),
+					MatchError(ContainSubstring("owns[2].object: Invalid value: \"*v1.Deployment\": type is not registered in the scheme")),
+					MatchError(ContainSubstring(`trackedOwns[0].object: Not found: "example.com/v1, Kind=Book"`))))
+				Expect(o.Start()).To(MatchError(invalid.Error()))
+			})
+			It("should reject status updates of parallel handlers", func() {
+				o, err := operator.New(&v1.Secret{}, lottest.New(nil).WithManager(), operator.WithStatusUpdates(), operator.WithParallelHandlers())
+				Expect(err).NotTo(HaveOccurred())
+				o.OnCreateOrUpdate(noop)
+				Expect(o.Build()).To(MatchError(ContainSubstring("statusUpdates: Forbidden: WithStatusUpdates() cannot be combined with WithParallelHandlers()")))
+			})
+			It("should report the problems of the primary resource", func() {
+				o, err := operator.NewUntyped("example.com", "v1", "Book", lottest.New(nil).WithManager())
+				Expect(err).NotTo(HaveOccurred())
+				o.OnCreateOrUpdate(noop)
+				Expect(o.Build()).To(MatchError(ContainSubstring(`object: Not found: "example.com/v1, Kind=Book"`)))
+
+				o, err = operator.New(&appsv1.Deployment{}, lottest.New(runtime.NewScheme()).WithManager())
+				Expect(err).NotTo(HaveOccurred())
+				o.OnCreateOrUpdate(noop)
+				Expect(o.Build()).To(MatchError(ContainSubstring("object: Invalid value")))
+			})
+			It("should accept nil scheduled handlers", func() {
+				o, err := operator.New(&v1.Secret{}, lottest.New(nil).WithManager())
+				Expect(err).NotTo(HaveOccurred())
+				o.OnSchedule("@hourly", nil)
+				Expect(o.Build()).To(Succeed())
+			})
+		})
+		Describe("with custom resources", func() {
+			crd := func(served bool) *unstructured.Unstructured {
+				obj := &unstructured.Unstructured{Object: map[string]interface{}{
+					"apiVersion": "apiextensions.k8s.io/v1",
+					"kind":       "CustomResourceDefinition",
+					"metadata":   map[string]interface{}{"name": "books.example.com"},
+					"spec": map[string]interface{}{
+						"group": "example.com",
+						"names": map[string]interface{}{"kind": "Book", "plural": "books"},
+						"scope": "Namespaced",
+						"versions": []interface{}{
+							map[string]interface{}{"name": "v1", "served": served, "storage": true},
+						},
+					},
+				}}
+				return obj
+			}
+			It("should add the types to the scheme of the manager", func() {
+				sch := runtime.NewScheme()
+				Expect(v1.AddToScheme(sch)).To(Succeed())
+				env := lottest.New(sch)
+				_, err := operator.New(&v1.Secret{}, env.WithManager(), operator.WithScheme(rbacv1.AddToScheme))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(sch.Recognizes(rbacv1.SchemeGroupVersion.WithKind("Role"))).To(BeTrue())
+
+				_, err = operator.New(&v1.Secret{}, env.WithManager(), operator.WithScheme(func(*runtime.Scheme) error {
+					return errors.New("failure")
+				}))
+				Expect(err).To(MatchError("failure"))
+			})
+			It("should update existing CRDs when started", func() {
+				existing := crd(false)
+				Expect(unstructured.SetNestedSlice(existing.Object, []interface{}{
+					map[string]interface{}{"type": "Established", "status": "True"},
+				}, "status", "conditions")).To(Succeed())
+				env := lottest.New(nil, existing)
+
+				manifest, err := yaml.Marshal(crd(true).Object)
+				Expect(err).NotTo(HaveOccurred())
+				o, err := operator.New(&v1.Secret{}, env.WithManager(), operator.WithCRDs([]byte("---\n"), manifest))
+				Expect(err).NotTo(HaveOccurred())
+				o.OnCreateOrUpdate(func(ctx context.Context, object client.Object, cl lotClient.Client, scheme *runtime.Scheme) error {
+					return nil
+				})
+
+				ctx, cancel := context.WithCancel(context.Background())
+				defer cancel()
+				go func() {
+					defer GinkgoRecover()
+					Expect(o.StartWithContext(ctx)).To(Succeed())
+				}()
+				Eventually(env.Started()).Should(BeClosed())
+
+				installed := &unstructured.Unstructured{}
+				installed.SetGroupVersionKind(existing.GroupVersionKind())
+				Expect(env.Client().Get(context.Background(), client.ObjectKeyFromObject(existing), installed)).To(Succeed())
+				versions, _, _ := unstructured.NestedSlice(installed.Object, "spec", "versions")
+				Expect(versions).To(ConsistOf(HaveKeyWithValue("served", true)))
+				conditions, _, _ := unstructured.NestedSlice(installed.Object, "status", "conditions")
+				Expect(conditions).To(HaveLen(1))
+			})
+			It("should not wait for CRDs which are not installed with dry run", func() {
+				manifest, err := yaml.Marshal(crd(true).Object)
+				Expect(err).NotTo(HaveOccurred())
+				env := lottest.New(nil)
+				o, err := operator.New(&v1.Secret{}, env.WithManager(), operator.WithCRDs(manifest), operator.WithDryRun())
+				Expect(err).NotTo(HaveOccurred())
+				o.OnCreateOrUpdate(func(ctx context.Context, object client.Object, cl lotClient.Client, scheme *runtime.Scheme) error {
+					return nil
+				})
+
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				defer cancel()
+				Expect(o.StartWithContext(ctx)).To(MatchError(ContainSubstring("not installed, which is not done with WithDryRun()")))
+				Expect(ctx.Err()).NotTo(HaveOccurred())
+				err = env.Client().Get(context.Background(), client.ObjectKeyFromObject(crd(true)), crd(true))
+				Expect(apierrors.IsNotFound(err)).To(BeTrue())
+			})
+			It("should start with CRDs installed before with dry run", func() {
+				existing := crd(false)
+				Expect(unstructured.SetNestedSlice(existing.Object, []interface{}{
+					map[string]interface{}{"type": "Established", "status": "True"},
+				}, "status", "conditions")).To(Succeed())
+				env := lottest.New(nil, existing)
+				manifest, err := yaml.Marshal(crd(true).Object)
+				Expect(err).NotTo(HaveOccurred())
+				o, err := operator.New(&v1.Secret{}, env.WithManager(), operator.WithCRDs(manifest), operator.WithDryRun())
+				Expect(err).NotTo(HaveOccurred())
+				o.OnCreateOrUpdate(func(ctx context.Context, object client.Object, cl lotClient.Client, scheme *runtime.Scheme) error {
+					return nil
+				})
+
+				ctx, cancel := context.WithCancel(context.Background())
+				defer cancel()
+				go func() {
+					defer GinkgoRecover()
+					Expect(o.StartWithContext(ctx)).To(Succeed())
+				}()
+				Eventually(env.Started()).Should(BeClosed())
+
+				installed := &unstructured.Unstructured{}
+				installed.SetGroupVersionKind(existing.GroupVersionKind())
+				Expect(env.Client().Get(context.Background(), client.ObjectKeyFromObject(existing), installed)).To(Succeed())
+				versions, _, _ := unstructured.NestedSlice(installed.Object, "spec", "versions")
+				Expect(versions).To(ConsistOf(HaveKeyWithValue("served", false)))
+			})
+			It("should accept untyped kinds defined by the CRDs", func() {
+				manifest, err := yaml.Marshal(crd(true).Object)
+				Expect(err).NotTo(HaveOccurred())
+				o, err := operator.NewUntyped("example.com", "v1", "Book", lottest.New(nil).WithManager(), operator.WithCRDs(manifest))
+				Expect(err).NotTo(HaveOccurred())
+				o.OnCreateOrUpdate(func(ctx context.Context, object client.Object, cl lotClient.Client, scheme *runtime.Scheme) error {
+					return nil
+				})
+				Expect(o.Build()).To(Succeed())
+			})
+			It("should reject manifests which are no CRDs", func() {
+				_, err := operator.New(&v1.Secret{}, lottest.New(nil).WithManager(), operator.WithCRDs([]byte("apiVersion: v1\nkind: Secret\n")))
+				Expect(err).To(MatchError(ContainSubstring("CustomResourceDefinition")))
+				_, err = operator.New(&v1.Secret{}, lottest.New(nil).WithManager(), operator.WithCRDs([]byte("{")))
+				Expect(err).To(HaveOccurred())
+			})
+			It("should write the status changed by the handlers", func() {
+				deployment := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Namespace: "biz", Name: "baz", Generation: 2}}
+				env := lottest.New(nil, deployment)
+				o, err := operator.New(&appsv1.Deployment{}, env.WithManager(), operator.WithStatusUpdates())
+				Expect(err).NotTo(HaveOccurred())
+				o.OnCreateOrUpdate(func(ctx context.Context, object client.Object, cl lotClient.Client, scheme *runtime.Scheme) error {
+					object.(*appsv1.Deployment).Status.Replicas = 1
+					return nil
+				})
+				Expect(env.Build(o)).To(Succeed())
+
+				env.Reconcile(context.Background(), client.ObjectKeyFromObject(deployment))
+				Expect(env.Client().Get(context.Background(), client.ObjectKeyFromObject(deployment), deployment)).To(Succeed())
+				Expect(deployment.Status.Replicas).To(BeEquivalentTo(1))
+				Expect(deployment.Status.ObservedGeneration).To(BeEquivalentTo(2))
+			})
+		})
+		Describe("with dynamic kinds", func() {
+			var env *lottest.Environment
+			var bookCRD *unstructured.Unstructured
+			var book *unstructured.Unstructured
+			bookGVK := schema.GroupVersionKind{Group: "foo.sbb.ch", Version: "v1", Kind: "Book"}
+			crd := func(group, kind string, labels map[string]string) *unstructured.Unstructured {
+				obj := &unstructured.Unstructured{Object: map[string]interface{}{
+					"apiVersion": "apiextensions.k8s.io/v1",
+					"kind":       "CustomResourceDefinition",
+					"metadata":   map[string]interface{}{"name": strings.ToLower(kind) + "s." + group},
+					"spec": map[string]interface{}{
+						"group": group,
+						"names": map[string]interface{}{"kind": kind, "plural": strings.ToLower(kind) + "s"},
+						"scope": "Namespaced",
+						"versions": []interface{}{
+							map[string]interface{}{"name": "v1alpha1", "served": true, "storage": false},
+							map[string]interface{}{"name": "v1", "served": true, "storage": true},
+						},
+					},
+					"status": map[string]interface{}{
+						"conditions": []interface{}{map[string]interface{}{"type": "Established", "status": "True"}},
+					},
+				}}
+				obj.SetLabels(labels)
+				return obj
+			}
+			BeforeEach(func() {
+				bookCRD = crd("foo.sbb.ch", "Book", map[string]string{"lot": "true"})
+				book = &unstructured.Unstructured{}
+				book.SetGroupVersionKind(bookGVK)
+				book.SetNamespace("biz")
+				book.SetName("baz")
+				env = lottest.New(nil, bookCRD, crd("foo.sbb.ch", "Magazine", nil), crd("bar.sbb.ch", "Book", map[string]string{"lot": "true"}), book)
+			})
+			It("should watch the selected kinds while their CRDs exist", func() {
+				o, err := operator.NewDynamic(operator.KindSelector{Group: "foo.sbb.ch", Labels: map[string]string{"lot": selector.KeyPresent()}},
+					env.WithManager(), operator.WithDiscoveryInterval(10*time.Millisecond))
+				Expect(err).NotTo(HaveOccurred())
+				var mu sync.Mutex
+				var handled []schema.GroupVersionKind
+				o.OnCreateOrUpdate(func(ctx context.Context, object client.Object, cl lotClient.Client, scheme *runtime.Scheme) error {
+					mu.Lock()
+					defer mu.Unlock()
+					handled = append(handled, object.GetObjectKind().GroupVersionKind())
+					return nil
+				})
+
+				ctx, cancel := context.WithCancel(context.Background())
+				defer cancel()
+				go func() {
+					defer GinkgoRecover()
+					Expect(o.StartWithContext(ctx)).To(Succeed())
+				}()
+				Eventually(o.Kinds).Should(Equal([]schema.GroupVersionKind{bookGVK}))
+
+				Expect(env.Reconcile(context.Background(), client.ObjectKeyFromObject(book)).Err).NotTo(HaveOccurred())
+				mu.Lock()
+				Expect(handled).To(Equal([]schema.GroupVersionKind{bookGVK}))
+				mu.Unlock()
+
+				Expect(env.Client().Delete(context.Background(), bookCRD)).To(Succeed())
+				Eventually(o.Kinds).Should(BeEmpty())
+
+				bookCRD.SetResourceVersion("")
+				Expect(env.Client().Create(context.Background(), bookCRD)).To(Succeed())
+				Eventually(o.Kinds).Should(Equal([]schema.GroupVersionKind{bookGVK}))
+			})
+			It("should validate the owned resources before starting", func() {
+				o, err := operator.NewDynamic(operator.KindSelector{Group: "foo.sbb.ch"}, env.WithManager(), operator.WithOwns(&v1.ConfigMap{}, nil))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(o.StartWithContext(context.Background())).To(MatchError(ContainSubstring("owns[0].predicate")))
+			})
+			It("should only grant access to the kinds selected by labels", func() {
+				o, err := operator.NewDynamic(operator.KindSelector{Labels: map[string]string{"lot": "true"}}, env.WithManager())
+				Expect(err).NotTo(HaveOccurred())
+				objs, err := o.RBAC()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(objs[0].(*rbacv1.ClusterRole).Rules).To(Equal([]rbacv1.PolicyRule{
+					{APIGroups: []string{""}, Resources: []string{"events"}, Verbs: []string{"create", "patch"}},
+					{APIGroups: []string{"bar.sbb.ch"}, Resources: []string{"books"}, Verbs: []string{"get", "list", "patch", "update", "watch"}},
+					{APIGroups: []string{"foo.sbb.ch"}, Resources: []string{"books"}, Verbs: []string{"get", "list", "patch", "update", "watch"}},
+					{APIGroups: []string{"apiextensions.k8s.io"}, Resources: []string{"customresourcedefinitions"}, Verbs: []string{"list"}},
+				}))
+			})
+			It("should reject invalid kind selectors and discovery intervals", func() {
+				_, err := operator.NewDynamic(operator.KindSelector{Labels: map[string]string{"in valid": "x"}}, env.WithManager())
+				Expect(err).To(HaveOccurred())
+				_, err = operator.NewDynamic(operator.KindSelector{}, env.WithManager(), operator.WithDiscoveryInterval(0))
+				Expect(err).To(HaveOccurred())
+			})
+		})
+		Describe("with several clusters", func() {
+			var env *lottest.Environment
+			var local, remote *v1.Secret
+			BeforeEach(func() {
+				local = &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "biz", Name: "baz", Labels: map[string]string{"cluster": "local"}}}
+				remote = &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "biz", Name: "baz", Labels: map[string]string{"cluster": "prod"}}}
+				env = lottest.New(nil, local)
+			})
+			It("should reconcile the objects of every cluster with the client of their cluster", func() {
+				o, err := operator.New(&v1.Secret{}, env.WithManager(), env.WithCluster("prod", remote))
+				Expect(err).NotTo(HaveOccurred())
+				var handled []string
+				o.OnCreateOrUpdate(func(ctx context.Context, object client.Object, cl lotClient.Client, scheme *runtime.Scheme) error {
+					handled = append(handled, reconcile.ClusterFromContext(ctx)+"/"+object.GetLabels()["cluster"])
+					object.SetAnnotations(map[string]string{"reconciled": "true"})
+					return cl.Update(ctx, object)
+				})
+				Expect(env.Build(o)).To(Succeed())
+
+				Expect(env.Reconcile(context.Background(), client.ObjectKeyFromObject(local)).Err).NotTo(HaveOccurred())
+				Expect(handled).To(ConsistOf("/local", "prod/prod"))
+
+				reconciled := &v1.Secret{}
+				Expect(env.ClusterClient("prod").Get(context.Background(), client.ObjectKeyFromObject(remote), reconciled)).To(Succeed())
+				Expect(reconciled.Labels).To(HaveKeyWithValue("cluster", "prod"))
+				Expect(reconciled.Annotations).To(HaveKeyWithValue("reconciled", "true"))
+			})
+			It("should only pass triggers to the handlers in the cluster of the manager", func() {
+				o, err := operator.New(&v1.Secret{}, env.WithManager(), env.WithCluster("prod", remote))
+				Expect(err).NotTo(HaveOccurred())
+				var triggered []string
+				o.OnTrigger(func(ctx context.Context, object client.Object, cl lotClient.Client, scheme *runtime.Scheme) error {
+					triggered = append(triggered, reconcile.ClusterFromContext(ctx)+"/"+object.GetLabels()["cluster"])
+					if len(triggered) == 1 {
+						return errors.New("failed")
+					}
+					return nil
+				})
+				Expect(env.Build(o)).To(Succeed())
+
+				result, err := env.Trigger(context.Background(), client.ObjectKeyFromObject(local), "payload")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result.Err).To(HaveOccurred())
+				Expect(env.Reconcile(context.Background(), client.ObjectKeyFromObject(local)).Err).NotTo(HaveOccurred())
+				Expect(triggered).To(Equal([]string{"/local", "/local"}))
+			})
+			It("should create the clusters of a cluster set", func() {
+				o, err := operator.New(&v1.Secret{}, env.WithManager(), operator.WithClusters(clusters.Set{"prod": &rest.Config{Host: "https://127.0.0.1:1"}}))
+				Expect(err).NotTo(HaveOccurred())
+				o.OnCreateOrUpdate(func(ctx context.Context, object client.Object, cl lotClient.Client, scheme *runtime.Scheme) error {
+					return nil
+				})
+				Expect(env.Build(o)).To(Succeed())
+			})
+			It("should reject invalid and duplicate clusters", func() {
+				_, err := operator.New(&v1.Secret{}, env.WithManager(), env.WithCluster("prod"), env.WithCluster("prod"))
+				Expect(err).To(MatchError(ContainSubstring(`duplicate cluster "prod"`)))
+				_, err = operator.New(&v1.Secret{}, env.WithManager(), operator.WithCluster("", nil))
+				Expect(err).To(HaveOccurred())
+				_, err = operator.New(&v1.Secret{}, env.WithManager(), operator.WithClusters(clusters.Set{"prod": nil}))
+				Expect(err).To(HaveOccurred())
+			})
+		})
+		Describe("with dry run", func() {
+			It("should run the handlers without persisting their writes", func() {
+				secret := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "biz", Name: "baz"}}
+				env := lottest.New(nil, secret)
+				o, err := operator.New(&v1.Secret{}, env.WithManager(), operator.WithDryRun())
+				Expect(err).NotTo(HaveOccurred())
+				o.OnCreateOrUpdate(func(ctx context.Context, object client.Object, cl lotClient.Client, scheme *runtime.Scheme) error {
+					object.SetAnnotations(map[string]string{"reconciled": "true"})
+					if err := cl.Update(ctx, object); err != nil {
+						return err
+					}
+					return cl.Create(ctx, &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "biz", Name: "baz"}})
+				})
+				Expect(env.Build(o)).To(Succeed())
+				Expect(env.Reconcile(context.Background(), client.ObjectKeyFromObject(secret)).Err).NotTo(HaveOccurred())
+
+				stored := &v1.Secret{}
+				Expect(env.Client().Get(context.Background(), client.ObjectKeyFromObject(secret), stored)).To(Succeed())
+				Expect(stored.Annotations).NotTo(HaveKey("reconciled"))
+				err = env.Client().Get(context.Background(), client.ObjectKeyFromObject(secret), &v1.ConfigMap{})
+				Expect(apierrors.IsNotFound(err)).To(BeTrue())
+			})
+			It("should not compete for the lease of the operator", func() {
+				opts := manager.Options{MetricsBindAddress: "0", HealthProbeBindAddress: "0", LeaderElectionNamespace: "biz"}
+				o, err := operator.New(&v1.Secret{}, operator.WithManagerOptions(&opts), operator.WithLeaderElection(), operator.WithDryRun())
+				Expect(err).NotTo(HaveOccurred())
+				objs, err := o.RBAC()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(objs[len(objs)-1].GetName()).To(Equal("lot-secret-leader-election"))
+			})
+		})
+		Describe("with RBAC manifests", func() {
+			p := predicate.NewPredicateFuncs(func(object client.Object) bool {
+				return true
+			})
+			AfterEach(func() {
+				Expect(os.Unsetenv("POD_NAMESPACE")).To(Succeed())
+			})
+			It("should compute a ClusterRole from the operator definition", func() {
+				manifest, err := yaml.Marshal(map[string]interface{}{
+					"apiVersion": "apiextensions.k8s.io/v1", "kind": "CustomResourceDefinition", "metadata": map[string]interface{}{"name": "books.foo.sbb.ch"},
+				})
+				Expect(err).NotTo(HaveOccurred())
+				o, err := operator.New(&v1.Secret{}, lottest.New(nil).WithManager(),
+					operator.WithOwns(&v1.ConfigMap{}, p), operator.WithTrackedOwns(&rbacv1.ClusterRoleBinding{}, p),
+					operator.WithStatusUpdates(), operator.WithCRDs(manifest),
+					operator.WithRBACRules(rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"get"}}))
+				Expect(err).NotTo(HaveOccurred())
+
+				objs, err := o.RBAC()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(objs).To(HaveLen(1))
+				role, ok := objs[0].(*rbacv1.ClusterRole)
+				Expect(ok).To(BeTrue())
+				Expect(role.Name).To(Equal("lot-secret"))
+				Expect(role.Rules).To(Equal([]rbacv1.PolicyRule{
+					{APIGroups: []string{""}, Resources: []string{"configmaps"}, Verbs: []string{"create", "delete", "get", "list", "patch", "update", "watch"}},
+					{APIGroups: []string{""}, Resources: []string{"events"}, Verbs: []string{"create", "patch"}},
+					{APIGroups: []string{""}, Resources: []string{"secrets"}, Verbs: []string{"get", "list", "patch", "update", "watch"}},
+					{APIGroups: []string{""}, Resources: []string{"secrets/finalizers"}, Verbs: []string{"update"}},
+					{APIGroups: []string{""}, Resources: []string{"secrets/status"}, Verbs: []string{"patch", "update"}},
+					{APIGroups: []string{"apiextensions.k8s.io"}, Resources: []string{"customresourcedefinitions"}, Verbs: []string{"create", "get", "update"}},
+					{APIGroups: []string{"rbac.authorization.k8s.io"}, Resources: []string{"clusterrolebindings"}, Verbs: []string{"create", "delete", "get", "list", "patch", "update", "watch"}},
+					{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: []string{"get"}},
+				}))
+			})
+			It("should write Roles for every namespace and the lease without a cluster", func() {
+				Expect(os.Setenv("POD_NAMESPACE", "operators")).To(Succeed())
+				o, err := operator.New(&v1.Secret{}, operator.WithOfflineManager(), operator.WithNamespaces("biz", "baz"), operator.WithLeaderElection(),
+					operator.WithRBACRules(rbacv1.PolicyRule{NonResourceURLs: []string{"/metrics"}, Verbs: []string{"get"}}))
+				Expect(err).NotTo(HaveOccurred())
+				o.OnCreateOrUpdate(func(ctx context.Context, object client.Object, cl lotClient.Client, scheme *runtime.Scheme) error {
+					return nil
+				})
+				Expect(o.StartWithContext(context.Background())).To(MatchError(ContainSubstring("WithOfflineManager()")))
+
+				objs, err := o.RBAC()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(objs[0].(*rbacv1.ClusterRole).Rules).To(Equal([]rbacv1.PolicyRule{{NonResourceURLs: []string{"/metrics"}, Verbs: []string{"get"}}}))
+				var data bytes.Buffer
+				Expect(operator.WriteRBAC(&data, objs)).To(Succeed())
+				var kinds []string
+				for _, doc := range strings.Split(data.String(), "---\n")[1:] {
+					role := &unstructured.Unstructured{}
+					Expect(yaml.Unmarshal([]byte(doc), &role.Object)).To(Succeed())
+					kinds = append(kinds, role.GetKind()+" "+role.GetNamespace()+"/"+role.GetName())
+				}
+				Expect(kinds).To(Equal([]string{"ClusterRole /lot-secret", "Role biz/lot-secret", "Role baz/lot-secret", "Role operators/lot-secret-leader-election"}))
+			})
+			var book *unstructured.Unstructured
+			BeforeEach(func() {
+				book = &unstructured.Unstructured{}
+				book.SetGroupVersionKind(schema.GroupVersionKind{Group: "foo.sbb.ch", Version: "v1", Kind: "Book"})
+			})
+			It("should read the scope of custom kinds from their CRDs", func() {
+				manifest, err := yaml.Marshal(map[string]interface{}{
+					"apiVersion": "apiextensions.k8s.io/v1", "kind": "CustomResourceDefinition", "metadata": map[string]interface{}{"name": "books.foo.sbb.ch"},
+					"spec": map[string]interface{}{"group": "foo.sbb.ch", "scope": "Cluster", "names": map[string]interface{}{"kind": "Book", "plural": "books"}},
+				})
+				Expect(err).NotTo(HaveOccurred())
+				o, err := operator.New(&v1.Secret{}, operator.WithOfflineManager(), operator.WithNamespaces("biz"),
+					operator.WithTrackedOwns(book, p), operator.WithCRDs(manifest))
+				Expect(err).NotTo(HaveOccurred())
+				objs, err := o.RBAC()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(objs[0].(*rbacv1.ClusterRole).Rules).To(ContainElement(
+					rbacv1.PolicyRule{APIGroups: []string{"foo.sbb.ch"}, Resources: []string{"books"}, Verbs: []string{"create", "delete", "get", "list", "patch", "update", "watch"}}))
+			})
+			It("should not guess the scope of unknown kinds", func() {
+				o, err := operator.New(&v1.Secret{}, operator.WithOfflineManager(), operator.WithOwns(book, p))
+				Expect(err).NotTo(HaveOccurred())
+				_, err = o.RBAC()
+				Expect(err).To(MatchError(ContainSubstring("the scope of Book.foo.sbb.ch is unknown")))
+			})
+			It("should compute the rules of dynamic operators", func() {
+				o, err := operator.NewDynamic(operator.KindSelector{Group: "foo.sbb.ch"}, lottest.New(nil).WithManager())
+				Expect(err).NotTo(HaveOccurred())
+				objs, err := o.RBAC()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(objs).To(HaveLen(1))
+				Expect(objs[0].GetName()).To(Equal("lot-dynamic.foo.sbb.ch"))
+				Expect(objs[0].(*rbacv1.ClusterRole).Rules).To(ContainElement(
+					rbacv1.PolicyRule{APIGroups: []string{"foo.sbb.ch"}, Resources: []string{"*"}, Verbs: []string{"get", "list", "patch", "update", "watch"}}))
+			})
+		})
+		Describe("when objects are deleted", func() {
+			var env *lottest.Environment
+			var handled []string
+			record := func(name string) reconcile.Handler {
+				return func(ctx context.Context, object client.Object, cl lotClient.Client, scheme *runtime.Scheme) error {
+					handled = append(handled, name+"/"+object.GetLabels()["state"])
+					return nil
+				}
+			}
+			BeforeEach(func() {
+				handled = nil
+				env = lottest.New(nil)
+			})
+			It("should pass the last known state of deleted objects to the delete handlers only", func() {
+				o, err := operator.New(&v1.Secret{}, env.WithManager())
+				Expect(err).NotTo(HaveOccurred())
+				o.OnCreateOrUpdate(record("createOrUpdate"))
+				o.OnDelete(record("delete"))
+				Expect(env.Build(o)).To(Succeed())
+
+				secret := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "biz", Name: "baz", Labels: map[string]string{"state": "created"}}}
+				Expect(env.Create(context.Background(), secret)).To(Succeed())
+				handled = nil
+				Expect(env.Delete(context.Background(), secret)).To(Succeed())
+				Expect(handled).To(Equal([]string{"delete/created"}))
+
+				handled = nil
+				env.Reconcile(context.Background(), client.ObjectKeyFromObject(secret))
+				Expect(handled).To(BeEmpty())
+			})
+			It("should pass terminating objects to the delete handlers only", func() {
+				o, err := operator.New(&v1.Secret{}, env.WithManager())
+				Expect(err).NotTo(HaveOccurred())
+				o.OnCreateOrUpdate(record("createOrUpdate"))
+				o.OnDelete(record("delete"))
+				Expect(env.Build(o)).To(Succeed())
+
+				secret := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "biz", Name: "baz", Labels: map[string]string{"state": "created"}, Finalizers: []string{"foo"}}}
+				Expect(env.Create(context.Background(), secret)).To(Succeed())
+				handled = nil
+				Expect(env.Delete(context.Background(), secret)).To(Succeed())
+				Expect(handled).To(Equal([]string{"delete/created"}))
+			})
+			It("should retry the delete handlers of deleted objects until they succeed", func() {
+				o, err := operator.New(&v1.Secret{}, env.WithManager())
+				Expect(err).NotTo(HaveOccurred())
+				failing := true
+				o.OnDelete(func(ctx context.Context, object client.Object, cl lotClient.Client, scheme *runtime.Scheme) error {
+					handled = append(handled, "delete/"+string(object.GetUID()))
+					if failing {
+						return errors.New("failure")
+					}
+					return nil
+				})
+				o.OnCreateOrUpdate(func(ctx context.Context, object client.Object, cl lotClient.Client, scheme *runtime.Scheme) error {
+					handled = append(handled, "createOrUpdate/"+string(object.GetUID()))
+					return nil
+				})
+				Expect(env.Build(o)).To(Succeed())
+
+				secret := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "biz", Name: "baz", UID: "first"}}
+				Expect(env.Create(context.Background(), secret)).To(Succeed())
+				Expect(env.Delete(context.Background(), secret)).To(Succeed())
+				Expect(env.Results()[1].Err).To(HaveOccurred())
+
+				// the object is recreated before the delete handlers of the old one succeeded
+				failing = false
+				handled = nil
+				recreated := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "biz", Name: "baz", UID: "second"}}
+				Expect(env.Create(context.Background(), recreated)).To(Succeed())
+				Expect(handled).To(Equal([]string{"delete/first", "createOrUpdate/second"}))
+			})
+			It("should only run the handlers whose selector matches the current object", func() {
+				o, err := operator.New(&v1.Secret{}, env.WithManager())
+				Expect(err).NotTo(HaveOccurred())
+				o.OnCreateOrUpdate(record("foo"), operator.WithLabels(map[string]string{"foo": "bar"}))
+				Expect(env.Build(o)).To(Succeed())
+
+				secret := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "biz", Name: "baz", Labels: map[string]string{"foo": "bar", "state": "created"}}}
+				Expect(env.Create(context.Background(), secret)).To(Succeed())
+				Expect(handled).To(Equal([]string{"foo/created"}))
+
+				// the update is accepted as the old object matches, but the handler does not match anymore
+				handled = nil
+				secret.Labels = map[string]string{"state": "updated"}
+				Expect(env.Update(context.Background(), secret)).To(Succeed())
+				Expect(env.Events()[1].Accepted).To(BeTrue())
+				Expect(handled).To(BeEmpty())
+			})
+		})
 		Describe("with handlers", func() {
 			var o operator.Operator
 			var err error
+			noop := func(ctx context.Context, object client.Object, cl lotClient.Client, scheme *runtime.Scheme) error {
+				return nil
+			}
 			BeforeEach(func() {
 				o, err = operator.New(&v1.Secret{}, disableHealthAndMetricEndpoint)
 				Expect(err).NotTo(HaveOccurred())
@@ -79,14 +1403,21 @@ var _ = Describe("operator", func() {
 					}
 					o.OnCreateOrUpdate(hdl)
 				})
-				It("should accept a nil handler function", func() {
+				It("should reject a nil handler function", func() {
 					o.OnCreateOrUpdate(nil)
+					Expect(o.Build()).To(MatchError(ContainSubstring("OnCreateOrUpdate(...) requires a handler function")))
 				})
 				It("should accept the WithAnnotations option", func() {
-					o.OnCreateOrUpdate(nil, operator.WithAnnotations(map[string]string{"key": "value"}))
+					o.OnCreateOrUpdate(noop, operator.WithAnnotations(map[string]string{"key": "value"}))
 				})
 				It("should accept the WithLabels option", func() {
-					o.OnCreateOrUpdate(nil, operator.WithLabels(map[string]string{"key": "value"}))
+					o.OnCreateOrUpdate(noop, operator.WithLabels(map[string]string{"key": "value"}))
+				})
+				It("should reject the WithSelector option combined with WithLabels", func() {
+					sel, err := selector.NewReloadable(map[string]string{"key": "value"}, nil)
+					Expect(err).NotTo(HaveOccurred())
+					o.OnCreateOrUpdate(noop, operator.WithSelector(sel), operator.WithLabels(map[string]string{"key": "value"}))
+					Expect(o.StartWithContext(context.Background())).To(MatchError(ContainSubstring("cannot be combined")))
 				})
 			})
 			Describe("when defining a Delete handler", func() {
@@ -98,18 +1429,18 @@ var _ = Describe("operator", func() {
 					err := o.Build()
 					Expect(err).ToNot(HaveOccurred())
 				})
-				It("should accept a nil handler function", func() {
+				It("should reject a nil handler function", func() {
 					o.OnDelete(nil)
 					err := o.Build()
-					Expect(err).ToNot(HaveOccurred())
+					Expect(err).To(MatchError(ContainSubstring("OnDelete(...) requires a handler function")))
 				})
 				It("should accept the WithAnnotations option", func() {
-					o.OnDelete(nil, operator.WithAnnotations(map[string]string{"key": "value"}))
+					o.OnDelete(noop, operator.WithAnnotations(map[string]string{"key": "value"}))
 					err := o.Build()
 					Expect(err).ToNot(HaveOccurred())
 				})
 				It("should accept the WithLabels option", func() {
-					o.OnDelete(nil, operator.WithLabels(map[string]string{"key": "value"}))
+					o.OnDelete(noop, operator.WithLabels(map[string]string{"key": "value"}))
 					err := o.Build()
 					Expect(err).ToNot(HaveOccurred())
 				})
@@ -118,6 +1449,9 @@ var _ = Describe("operator", func() {
 		Describe("with predicates", func() {
 			var o operator.Operator
 			var prct predicate.Predicate
+			noop := func(ctx context.Context, object client.Object, cl lotClient.Client, scheme *runtime.Scheme) error {
+				return nil
+			}
 			var testPod, otherPod *v1.Pod
 			var err error
 			var testLabels, testAnnotations, otherLabels, otherAnnotations map[string]string
@@ -172,7 +1506,7 @@ var _ = Describe("operator", func() {
 			Describe("when only defining a CreateOrUpdate handler", func() {
 				var opts []operator.HandlerOption
 				JustBeforeEach(func() {
-					o.OnCreateOrUpdate(nil, opts...)
+					o.OnCreateOrUpdate(noop, opts...)
 					err := o.Build()
 					Expect(err).ToNot(HaveOccurred())
 					prct = o.Predicate()
@@ -243,7 +1577,7 @@ var _ = Describe("operator", func() {
 			Describe("when only defining a Delete handler", func() {
 				var opts []operator.HandlerOption
 				JustBeforeEach(func() {
-					o.OnDelete(nil, opts...)
+					o.OnDelete(noop, opts...)
 					err := o.Build()
 					Expect(err).ToNot(HaveOccurred())
 					prct = o.Predicate()
@@ -314,8 +1648,8 @@ var _ = Describe("operator", func() {
 			Describe("when defining a CreateOrUpdate and Delete handler", func() {
 				var opts []operator.HandlerOption
 				JustBeforeEach(func() {
-					o.OnCreateOrUpdate(nil, opts...)
-					o.OnDelete(nil, opts...)
+					o.OnCreateOrUpdate(noop, opts...)
+					o.OnDelete(noop, opts...)
 					err := o.Build()
 					Expect(err).ToNot(HaveOccurred())
 					prct = o.Predicate()
@@ -400,8 +1734,8 @@ var _ = Describe("operator", func() {
 					o, err = operator.New(&v1.Secret{}, operator.WithCustomPredicate(customPrct), disableHealthAndMetricEndpoint)
 					Expect(err).NotTo(HaveOccurred())
 					Expect(o).NotTo(BeNil())
-					o.OnCreateOrUpdate(nil, operator.WithLabels(testLabels), operator.WithAnnotations(testAnnotations))
-					o.OnDelete(nil, operator.WithLabels(testLabels), operator.WithAnnotations(testAnnotations))
+					o.OnCreateOrUpdate(noop, operator.WithLabels(testLabels), operator.WithAnnotations(testAnnotations))
+					o.OnDelete(noop, operator.WithLabels(testLabels), operator.WithAnnotations(testAnnotations))
 					err := o.Build()
 					Expect(err).ToNot(HaveOccurred())
 					prct = o.Predicate()
@@ -1,17 +1,58 @@
 package operator
 
 import (
+	"context"
 	"fmt"
+	"strings"
+	"time"
 
+	"github.com/SchweizerischeBundesbahnen/lot/pkg/clusters"
+	"github.com/SchweizerischeBundesbahnen/lot/pkg/reconcile"
+	"github.com/SchweizerischeBundesbahnen/lot/pkg/selector"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/time/rate"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/util/workqueue"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/cluster"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/ratelimiter"
 )
 
 type constructorOptions struct {
-	mgrOpts    *manager.Options
-	predicates []predicate.Predicate
-	ownsInput  []OwnsInput
+	mgr              manager.Manager
+	mgrOpts          *manager.Options
+	predicates       []predicate.Predicate
+	ownsInput        []OwnsInput
+	trackedOwnsInput []OwnsInput
+	recordPath       string
+	leaderElection   bool
+	drainTimeout     time.Duration
+	controllerOpts   controller.Options
+	defaultTimeout   time.Duration
+	middlewares      []reconcile.Middleware
+	tracerProvider   trace.TracerProvider
+	shutdownTracing  func(context.Context) error
+	parallelHandlers bool
+	webhook          webhookOptions
+	addToScheme      []func(*runtime.Scheme) error
+	crds             []*unstructured.Unstructured
+	statusUpdates    bool
+	// discoveryInterval is only used by NewDynamic
+	discoveryInterval time.Duration
+	clusters          []remoteCluster
+	clusterSet        clusters.Set
+	namespaces        []string
+	ownNamespace      bool
+	rbacRules         []rbacv1.PolicyRule
+	dryRun            bool
+	offline           bool
 }
 
 type OwnsInput struct {
@@ -40,6 +81,27 @@ func WithManagerOptions(mgrOpts *manager.Options) ConstructorOption {
 	}
 }
 
+// WithManager makes the Operator use an existing manager.Manager, e.g. to run several operators in one manager.
+// It cannot be combined with WithManagerOptions.
+func WithManager(mgr manager.Manager) ConstructorOption {
+	return func(opts *constructorOptions) error {
+		if opts.mgr != nil {
+			return fmt.Errorf("WithManager(...) should only be called once")
+		}
+		opts.mgr = mgr
+		return nil
+	}
+}
+
+// WithOfflineManager creates the Operator with a manager which never connects to a cluster, so that its RBAC manifests
+// can be computed with RBAC() without a kubeconfig, e.g. by the command lot-rbac. Such an operator cannot be started.
+func WithOfflineManager() ConstructorOption {
+	return func(opts *constructorOptions) error {
+		opts.offline = true
+		return nil
+	}
+}
+
 func WithOwns(object client.Object, filter predicate.Predicate) ConstructorOption {
 	return func(opts *constructorOptions) error {
 		input := OwnsInput{object: object, predicate: filter}
@@ -48,13 +110,313 @@ func WithOwns(object client.Object, filter predicate.Predicate) ConstructorOptio
 	}
 }
 
+// WithTrackedOwns watches children marked with ownership.SetOwner, which may be in another namespace or cluster-scoped.
+// Their events are mapped to their owner, and they are deleted once their owner is gone.
+func WithTrackedOwns(object client.Object, filter predicate.Predicate) ConstructorOption {
+	return func(opts *constructorOptions) error {
+		input := OwnsInput{object: object, predicate: filter}
+		opts.trackedOwnsInput = append(opts.trackedOwnsInput, input)
+		return nil
+	}
+}
+
+// WithEventRecording appends the events of the primary resources with snapshots of the objects as JSON lines to the
+// file at path while the operator runs, see lottest.Environment.ReplayFile. The snapshots include the data of Secrets.
+func WithEventRecording(path string) ConstructorOption {
+	return func(opts *constructorOptions) error {
+		if opts.recordPath != "" {
+			return fmt.Errorf("WithEventRecording(...) should only be called once")
+		}
+		opts.recordPath = path
+		return nil
+	}
+}
+
+// WithLeaderElection runs the handlers on one replica at a time, unless they are marked with WithAllReplicas.
+// It cannot be combined with WithManager.
+func WithLeaderElection() ConstructorOption {
+	return func(opts *constructorOptions) error {
+		opts.leaderElection = true
+		return nil
+	}
+}
+
+// WithDrainTimeout sets the time handlers in flight are given to finish when the operator is stopped, after which
+// they are cancelled and logged as abandoned. By default handlers are cancelled right away.
+func WithDrainTimeout(timeout time.Duration) ConstructorOption {
+	return func(opts *constructorOptions) error {
+		if timeout < 0 {
+			return fmt.Errorf("WithDrainTimeout(...) requires a positive timeout")
+		}
+		opts.drainTimeout = timeout
+		return nil
+	}
+}
+
+// WithMaxConcurrentReconciles sets the number of objects which are reconciled in parallel, which is 1 by default.
+// Each object is still only reconciled by one worker at a time.
+func WithMaxConcurrentReconciles(n int) ConstructorOption {
+	return func(opts *constructorOptions) error {
+		if n < 1 {
+			return fmt.Errorf("WithMaxConcurrentReconciles(...) requires at least one worker")
+		}
+		opts.controllerOpts.MaxConcurrentReconciles = n
+		return nil
+	}
+}
+
+// WithRateLimiter sets the rate limiter of the work queue. With several rate limiters the longest delay is used.
+func WithRateLimiter(limiters ...ratelimiter.RateLimiter) ConstructorOption {
+	return func(opts *constructorOptions) error {
+		if len(limiters) == 0 {
+			return fmt.Errorf("WithRateLimiter(...) requires at least one rate limiter")
+		}
+		if opts.controllerOpts.RateLimiter != nil {
+			return fmt.Errorf("WithRateLimiter(...) should only be called once")
+		}
+		if len(limiters) == 1 {
+			opts.controllerOpts.RateLimiter = limiters[0]
+			return nil
+		}
+		var queueLimiters []workqueue.RateLimiter
+		for _, l := range limiters {
+			queueLimiters = append(queueLimiters, l)
+		}
+		opts.controllerOpts.RateLimiter = workqueue.NewMaxOfRateLimiter(queueLimiters...)
+		return nil
+	}
+}
+
+// WithDefaultTimeout sets the timeout of all handlers which are not given a timeout with WithTimeout.
+func WithDefaultTimeout(timeout time.Duration) ConstructorOption {
+	return func(opts *constructorOptions) error {
+		if timeout <= 0 {
+			return fmt.Errorf("WithDefaultTimeout(...) requires a positive timeout")
+		}
+		opts.defaultTimeout = timeout
+		return nil
+	}
+}
+
+// WithMiddleware wraps all handlers with the given middlewares, the first one being the outermost. Calling it multiple
+// times appends the middlewares, which run outside of the ones given with WithHandlerMiddleware.
+func WithMiddleware(middlewares ...reconcile.Middleware) ConstructorOption {
+	return func(opts *constructorOptions) error {
+		opts.middlewares = append(opts.middlewares, middlewares...)
+		return nil
+	}
+}
+
+// WithTracerProvider sets the TracerProvider used to create the spans of the reconciles, handlers and client calls
+// of the Operator. By default the global TracerProvider of OpenTelemetry is used.
+func WithTracerProvider(tp trace.TracerProvider) ConstructorOption {
+	return func(opts *constructorOptions) error {
+		if opts.tracerProvider != nil {
+			return fmt.Errorf("WithTracerProvider(...) or WithTraceExporter(...) should only be called once")
+		}
+		opts.tracerProvider = tp
+		return nil
+	}
+}
+
+// WithTraceExporter exports the spans of the Operator in batches with the given exporter, e.g. an OTLP exporter.
+// It cannot be combined with WithTracerProvider.
+func WithTraceExporter(exporter sdktrace.SpanExporter) ConstructorOption {
+	return func(opts *constructorOptions) error {
+		tp := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter))
+		if err := WithTracerProvider(tp)(opts); err != nil {
+			return err
+		}
+		opts.shutdownTracing = tp.Shutdown
+		return nil
+	}
+}
+
+// WithParallelHandlers runs the matching handlers of an event type concurrently instead of one after the
+// other in the order they have been registered. Each handler is passed its own copy of the object.
+func WithParallelHandlers() ConstructorOption {
+	return func(opts *constructorOptions) error {
+		opts.parallelHandlers = true
+		return nil
+	}
+}
+
+// WithWebhookPort sets the port the webhook server of the manager serves the webhooks added with OnValidate and
+// OnMutate on. By default the port 9443 is used.
+func WithWebhookPort(port int) ConstructorOption {
+	return func(opts *constructorOptions) error {
+		if port <= 0 || port > 65535 {
+			return fmt.Errorf("WithWebhookPort(...) requires a valid port")
+		}
+		opts.webhook.port = port
+		return nil
+	}
+}
+
+// WithWebhookCertificates sets the directory and the file names of the certificate and key of the webhook server.
+// Empty file names default to tls.crt and tls.key.
+func WithWebhookCertificates(certDir, certName, keyName string) ConstructorOption {
+	return func(opts *constructorOptions) error {
+		if certDir == "" {
+			return fmt.Errorf("WithWebhookCertificates(...) requires a directory")
+		}
+		opts.webhook.certDir, opts.webhook.certName, opts.webhook.keyName = certDir, certName, keyName
+		return nil
+	}
+}
+
+// WithScheme adds types to the scheme of the manager with the given functions, e.g. the AddToScheme functions of
+// API packages.
+func WithScheme(addToScheme ...func(*runtime.Scheme) error) ConstructorOption {
+	return func(opts *constructorOptions) error {
+		opts.addToScheme = append(opts.addToScheme, addToScheme...)
+		return nil
+	}
+}
+
+// WithCRDs installs or updates the CustomResourceDefinitions of the given YAML manifests when the Operator is started,
+// and waits for them to be established.
+func WithCRDs(manifests ...[]byte) ConstructorOption {
+	return func(opts *constructorOptions) error {
+		crds, err := decodeCRDs(manifests)
+		if err != nil {
+			return fmt.Errorf("WithCRDs(...) requires CustomResourceDefinitions: %w", err)
+		}
+		opts.crds = append(opts.crds, crds...)
+		return nil
+	}
+}
+
+// WithStatusUpdates writes the status and its observedGeneration after the create or update handlers, if they changed
+// it. The resource needs a status subresource, and it cannot be combined with WithParallelHandlers.
+func WithStatusUpdates() ConstructorOption {
+	return func(opts *constructorOptions) error {
+		opts.statusUpdates = true
+		return nil
+	}
+}
+
+// WithNamespaces restricts the operator to the objects of the given namespaces. The primary resource and the kinds
+// given with WithOwns have to be namespaced.
+func WithNamespaces(namespaces ...string) ConstructorOption {
+	return func(opts *constructorOptions) error {
+		if len(namespaces) == 0 {
+			return fmt.Errorf("WithNamespaces(...) requires at least one namespace")
+		}
+		for _, ns := range namespaces {
+			if errs := validation.IsDNS1123Label(ns); len(errs) > 0 {
+				return fmt.Errorf("WithNamespaces(...) requires valid namespaces, got %q: %s", ns, strings.Join(errs, ", "))
+			}
+		}
+		opts.namespaces = append(opts.namespaces, namespaces...)
+		return nil
+	}
+}
+
+// WithOwnNamespace restricts the operator to the objects of the namespace it is running in, like WithNamespaces.
+// The namespace is taken from the environment variable POD_NAMESPACE or the service account mounted into the pod.
+func WithOwnNamespace() ConstructorOption {
+	return func(opts *constructorOptions) error {
+		opts.ownNamespace = true
+		return nil
+	}
+}
+
+// WithRBACRules adds rules to the RBAC manifests of the operator, see Operator.RBAC, e.g. for resources which are
+// read or written by the handlers but neither watched as the primary resource nor with WithOwns
+func WithRBACRules(rules ...rbacv1.PolicyRule) ConstructorOption {
+	return func(opts *constructorOptions) error {
+		for _, rule := range rules {
+			if len(rule.Verbs) == 0 || len(rule.APIGroups) == 0 && len(rule.NonResourceURLs) == 0 {
+				return fmt.Errorf("WithRBACRules(...) requires verbs and API groups or non-resource URLs for every rule")
+			}
+		}
+		opts.rbacRules = append(opts.rbacRules, rules...)
+		return nil
+	}
+}
+
+// WithClusters reconciles the primary resource in the clusters of the set in addition to the cluster of the manager,
+// see reconcile.ClusterFromContext. Triggers, schedules, webhooks and WithAllReplicas only apply to the latter.
+func WithClusters(set clusters.Set) ConstructorOption {
+	return func(opts *constructorOptions) error {
+		if opts.clusterSet == nil {
+			opts.clusterSet = clusters.Set{}
+		}
+		for id, config := range set {
+			if id == "" || config == nil {
+				return fmt.Errorf("WithClusters(...) requires an identifier and a config for every cluster")
+			}
+			if _, ok := opts.clusterSet[id]; ok {
+				return fmt.Errorf("WithClusters(...) requires unique identifiers, got %q twice", id)
+			}
+			opts.clusterSet[id] = config
+		}
+		return nil
+	}
+}
+
+// WithCluster reconciles the primary resource in an existing cluster.Cluster in addition to the cluster of the
+// manager, see WithClusters. The cluster is started with the manager and has to use the same scheme.
+func WithCluster(id string, c cluster.Cluster) ConstructorOption {
+	return func(opts *constructorOptions) error {
+		if id == "" || c == nil {
+			return fmt.Errorf("WithCluster(...) requires an identifier and a cluster")
+		}
+		opts.clusters = append(opts.clusters, remoteCluster{id: id, cluster: c})
+		return nil
+	}
+}
+
+// WithDiscoveryInterval sets the interval in which an operator created with NewDynamic looks for CRDs of the selected
+// kinds, which is 30 seconds by default.
+func WithDiscoveryInterval(interval time.Duration) ConstructorOption {
+	return func(opts *constructorOptions) error {
+		if interval <= 0 {
+			return fmt.Errorf("WithDiscoveryInterval(...) requires a positive interval")
+		}
+		opts.discoveryInterval = interval
+		return nil
+	}
+}
+
+// WithDryRun sends all writes with the dry-run option and logs them as diffs, see lot_client.WithDryRun, e.g. to run a
+// shadow deployment. The CRDs given with WithCRDs have to be installed, and the lease gets the suffix "-dry-run".
+func WithDryRun() ConstructorOption {
+	return func(opts *constructorOptions) error {
+		opts.dryRun = true
+		return nil
+	}
+}
+
 type handlerOptions struct {
 	labels      map[string]string
 	annotations map[string]string
+	selector    selector.Selector
+	allReplicas bool
+	concurrency int
+	rateLimit   rate.Limit
+	burst       int
+	timeout     *time.Duration
+	middlewares []reconcile.Middleware
+	name        string
+	jitter      *time.Duration
 }
 
 type HandlerOption func(options *handlerOptions) error
 
+// WithName registers the handler under the given name, which has to be unique among all handlers. By default handlers
+// are named after their event type, e.g. "delete", followed by a number from the second handler on.
+func WithName(name string) HandlerOption {
+	return func(opts *handlerOptions) error {
+		if name == "" {
+			return fmt.Errorf("WithName(...) requires a name")
+		}
+		opts.name = name
+		return nil
+	}
+}
+
 // WithAnnotations sets the annotations used to filter events
 // for the handler. Calling it multiple times merges the values.
 func WithAnnotations(annotations map[string]string) HandlerOption {
@@ -82,3 +444,92 @@ func WithLabels(labels map[string]string) HandlerOption {
 		return nil
 	}
 }
+
+// WithSelector filters the events for the handler with s instead of WithLabels and WithAnnotations. All objects are
+// resynced once a selector.Reloadable changes, see the package config.
+func WithSelector(s selector.Selector) HandlerOption {
+	return func(opts *handlerOptions) error {
+		if s == nil {
+			return fmt.Errorf("WithSelector(...) requires a selector")
+		}
+		opts.selector = s
+		return nil
+	}
+}
+
+// newSelector returns the selector given with WithSelector, or a selector matching the labels and annotations
+func (opts handlerOptions) newSelector() (selector.Selector, error) {
+	if opts.selector == nil {
+		return selector.NewSelector(opts.labels, opts.annotations)
+	}
+	if len(opts.labels) > 0 || len(opts.annotations) > 0 {
+		return nil, fmt.Errorf("WithSelector(...) cannot be combined with WithLabels(...) or WithAnnotations(...)")
+	}
+	return opts.selector, nil
+}
+
+// WithMaxConcurrency limits the number of parallel runs of the handler and requeues the object at the limit.
+func WithMaxConcurrency(n int) HandlerOption {
+	return func(opts *handlerOptions) error {
+		if n < 1 {
+			return fmt.Errorf("WithMaxConcurrency(...) requires a limit of at least 1")
+		}
+		opts.concurrency = n
+		return nil
+	}
+}
+
+// WithRateLimit limits the runs of the handler to qps per second with the given burst. When the limit is
+// reached, the object is requeued once the handler may run again instead of blocking a worker.
+func WithRateLimit(qps float64, burst int) HandlerOption {
+	return func(opts *handlerOptions) error {
+		if qps <= 0 || burst < 1 {
+			return fmt.Errorf("WithRateLimit(...) requires a positive rate and a burst of at least 1")
+		}
+		opts.rateLimit = rate.Limit(qps)
+		opts.burst = burst
+		return nil
+	}
+}
+
+// WithTimeout cancels the context passed to the handler after the given timeout, counted in lot_handler_timeouts_total.
+// A timeout of 0 disables the timeout given with WithDefaultTimeout.
+func WithTimeout(timeout time.Duration) HandlerOption {
+	return func(opts *handlerOptions) error {
+		if timeout < 0 {
+			return fmt.Errorf("WithTimeout(...) requires a positive timeout")
+		}
+		opts.timeout = &timeout
+		return nil
+	}
+}
+
+// WithJitter spreads the objects enqueued for a handler added with OnSchedule randomly over the given duration after
+// the schedule is due. A jitter of 0 enqueues all objects at once.
+func WithJitter(jitter time.Duration) HandlerOption {
+	return func(opts *handlerOptions) error {
+		if jitter < 0 {
+			return fmt.Errorf("WithJitter(...) requires a positive jitter")
+		}
+		opts.jitter = &jitter
+		return nil
+	}
+}
+
+// WithHandlerMiddleware wraps the handler with the given middlewares. The first middleware is the outermost one.
+// Calling it multiple times appends the middlewares.
+func WithHandlerMiddleware(middlewares ...reconcile.Middleware) HandlerOption {
+	return func(opts *handlerOptions) error {
+		opts.middlewares = append(opts.middlewares, middlewares...)
+		return nil
+	}
+}
+
+// WithAllReplicas marks the handler as safe to run on all replicas of the operator at the same time.
+// When using WithLeaderElection, the handler is also run by replicas which are not the leader.
+func WithAllReplicas() HandlerOption {
+	return func(opts *handlerOptions) error {
+		opts.allReplicas = true
+		return nil
+	}
+}
@@ -0,0 +1,21 @@
+package operator
+
+import (
+	"time"
+
+	"golang.org/x/time/rate"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/ratelimiter"
+)
+
+// ExponentialRateLimiter returns a rate limiter for WithRateLimiter which delays the requeue of each
+// object exponentially, starting at base and up to max, after every failed reconcile
+func ExponentialRateLimiter(base, max time.Duration) ratelimiter.RateLimiter {
+	return workqueue.NewItemExponentialFailureRateLimiter(base, max)
+}
+
+// TokenBucketRateLimiter returns a rate limiter for WithRateLimiter which limits the overall
+// rate of reconciles to qps with the given burst
+func TokenBucketRateLimiter(qps float64, burst int) ratelimiter.RateLimiter {
+	return &workqueue.BucketRateLimiter{Limiter: rate.NewLimiter(rate.Limit(qps), burst)}
+}
@@ -0,0 +1,221 @@
+package operator
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/yaml"
+)
+
+var (
+	readVerbs  = []string{"get", "list", "watch"}
+	writeVerbs = []string{"create", "update", "patch", "delete"}
+)
+
+// errOffline is returned when an operator created with WithOfflineManager is started
+var errOffline = errors.New("an operator created with WithOfflineManager() cannot be started, its RBAC manifests are returned by RBAC()")
+
+// policy collects the verbs needed for the resources of API groups
+type policy map[schema.GroupResource]map[string]bool
+
+func (p policy) add(gr schema.GroupResource, verbs ...string) {
+	if p[gr] == nil {
+		p[gr] = map[string]bool{}
+	}
+	for _, v := range verbs {
+		p[gr][v] = true
+	}
+}
+
+// rules returns a rule for every API group and set of verbs, listing all resources of the group needing these verbs
+func (p policy) rules() []rbacv1.PolicyRule {
+	type key struct{ group, verbs string }
+	resources := map[key][]string{}
+	for gr, set := range p {
+		var verbs []string
+		for v := range set {
+			verbs = append(verbs, v)
+		}
+		sort.Strings(verbs)
+		k := key{group: gr.Group, verbs: strings.Join(verbs, ",")}
+		resources[k] = append(resources[k], gr.Resource)
+	}
+	var rules []rbacv1.PolicyRule
+	for k, res := range resources {
+		sort.Strings(res)
+		rules = append(rules, rbacv1.PolicyRule{APIGroups: []string{k.group}, Resources: res, Verbs: strings.Split(k.verbs, ",")})
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		if rules[i].APIGroups[0] != rules[j].APIGroups[0] {
+			return rules[i].APIGroups[0] < rules[j].APIGroups[0]
+		}
+		return strings.Join(rules[i].Resources, ",") < strings.Join(rules[j].Resources, ",")
+	})
+	return rules
+}
+
+// RBAC returns the RBAC manifests granting the permissions the operator needs: a ClusterRole, or a Role in every
+// namespace given with WithNamespaces together with a ClusterRole for cluster-scoped resources, and a Role for the
+// leader election lease. The rules cover the primary resource and the kinds given with WithOwns and WithTrackedOwns,
+// events, CRDs installed with WithCRDs and the rules given with WithRBACRules, which are needed for any other resource
+// the handlers use. Rules for non-resource URLs are only added to the ClusterRole. The scope of a kind is read from its
+// CRD given with WithCRDs or from the API server, and kinds whose scope is unknown are an error. The bindings to the
+// service account of the operator are not included.
+func (o *operator) RBAC() ([]client.Object, error) {
+	gvk, err := apiutil.GVKForObject(o.object, o.manager.GetScheme())
+	if err != nil {
+		return nil, err
+	}
+	return o.rbac(func(namespaced, cluster policy) error {
+		resource, scope, err := o.resourceOf(gvk)
+		if err != nil {
+			return err
+		}
+		p := namespaced
+		if scope == meta.RESTScopeNameRoot {
+			p = cluster
+		}
+		p.add(resource, append(readVerbs, "update", "patch")...)
+		if o.statusUpdates {
+			p.add(schema.GroupResource{Group: resource.Group, Resource: resource.Resource + "/status"}, "update", "patch")
+		}
+		// children with a controller reference block the deletion of their owner, which requires this permission
+		if len(o.ownsInput) > 0 {
+			p.add(schema.GroupResource{Group: resource.Group, Resource: resource.Resource + "/finalizers"}, "update")
+		}
+
+		for _, input := range append(append([]OwnsInput(nil), o.ownsInput...), o.trackedOwnsInput...) {
+			ownedGVK, err := apiutil.GVKForObject(input.object, o.manager.GetScheme())
+			if err != nil {
+				return err
+			}
+			resource, scope, err := o.resourceOf(ownedGVK)
+			if err != nil {
+				return err
+			}
+			p := namespaced
+			if scope == meta.RESTScopeNameRoot {
+				p = cluster
+			}
+			p.add(resource, append(readVerbs, writeVerbs...)...)
+		}
+		return nil
+	})
+}
+
+// rbac returns the RBAC manifests with the rules added by primary and the rules every operator needs
+func (o *operator) rbac(primary func(namespaced, cluster policy) error) ([]client.Object, error) {
+	namespaced, cluster := policy{}, policy{}
+	if err := primary(namespaced, cluster); err != nil {
+		return nil, err
+	}
+	namespaced.add(schema.GroupResource{Resource: "events"}, "create", "patch")
+	if len(o.crds) > 0 {
+		cluster.add(schema.GroupResource{Group: crdGVK.Group, Resource: "customresourcedefinitions"}, "get", "create", "update")
+	}
+
+	// a Role cannot grant access to non-resource URLs
+	var resourceRules, nonResourceRules []rbacv1.PolicyRule
+	for _, r := range o.rbacRules {
+		if len(r.NonResourceURLs) > 0 {
+			nonResourceRules = append(nonResourceRules, r)
+		} else {
+			resourceRules = append(resourceRules, r)
+		}
+	}
+
+	name := o.rbacName()
+	var objs []client.Object
+	if len(o.namespaces) == 0 {
+		rules := append(namespaced.rules(), cluster.rules()...)
+		objs = append(objs, &rbacv1.ClusterRole{
+			TypeMeta:   metav1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "ClusterRole"},
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Rules:      append(append(rules, resourceRules...), nonResourceRules...),
+		})
+	} else {
+		if len(cluster) > 0 || len(nonResourceRules) > 0 {
+			objs = append(objs, &rbacv1.ClusterRole{
+				TypeMeta:   metav1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "ClusterRole"},
+				ObjectMeta: metav1.ObjectMeta{Name: name},
+				Rules:      append(cluster.rules(), nonResourceRules...),
+			})
+		}
+		for _, ns := range o.namespaces {
+			objs = append(objs, &rbacv1.Role{
+				TypeMeta:   metav1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "Role"},
+				ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: name},
+				Rules:      append(namespaced.rules(), resourceRules...),
+			})
+		}
+	}
+
+	if o.leaseName != "" {
+		lease := policy{}
+		lease.add(schema.GroupResource{Group: "coordination.k8s.io", Resource: "leases"}, "get", "create", "update")
+		lease.add(schema.GroupResource{Resource: "events"}, "create", "patch")
+		objs = append(objs, &rbacv1.Role{
+			TypeMeta:   metav1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "Role"},
+			ObjectMeta: metav1.ObjectMeta{Namespace: o.leaseNamespace, Name: name + "-leader-election"},
+			Rules:      lease.rules(),
+		})
+	}
+	return objs, nil
+}
+
+// rbacName returns the name of the roles, which is derived from the kind, e.g. "lot-secret" or "lot-deployment.apps"
+func (o *operator) rbacName() string {
+	gvk := o.object.GetObjectKind().GroupVersionKind()
+	if gvk.Empty() {
+		gvk, _ = apiutil.GVKForObject(o.object, o.manager.GetScheme())
+	}
+	return strings.ToLower(strings.TrimSuffix("lot-"+gvk.Kind+"."+gvk.Group, "."))
+}
+
+// resourceOf returns the resource and the scope of the kind, read from its CRD given with WithCRDs or from the
+// RESTMapper
+func (o *operator) resourceOf(gvk schema.GroupVersionKind) (schema.GroupResource, meta.RESTScopeName, error) {
+	if crd := o.crdOf(gvk); crd != nil {
+		plural, _, _ := unstructured.NestedString(crd.Object, "spec", "names", "plural")
+		scope, _, _ := unstructured.NestedString(crd.Object, "spec", "scope")
+		if plural == "" || scope == "" {
+			return schema.GroupResource{}, "", fmt.Errorf("the CRD of %s given with WithCRDs(...) requires spec.names.plural and spec.scope", gvk.GroupKind())
+		}
+		if scope == "Cluster" {
+			return schema.GroupResource{Group: gvk.Group, Resource: plural}, meta.RESTScopeNameRoot, nil
+		}
+		return schema.GroupResource{Group: gvk.Group, Resource: plural}, meta.RESTScopeNameNamespace, nil
+	}
+	mapping, err := o.manager.GetRESTMapper().RESTMapping(gvk.GroupKind(), gvk.Version)
+	if meta.IsNoMatchError(err) {
+		return schema.GroupResource{}, "", fmt.Errorf("the scope of %s is unknown, add its CRD with WithCRDs(...)", gvk.GroupKind())
+	}
+	if err != nil {
+		return schema.GroupResource{}, "", err
+	}
+	return mapping.Resource.GroupResource(), mapping.Scope.Name(), nil
+}
+
+// WriteRBAC writes the manifests returned by RBAC as YAML documents to w
+func WriteRBAC(w io.Writer, objs []client.Object) error {
+	for _, obj := range objs {
+		data, err := yaml.Marshal(obj)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "---\n%s", data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
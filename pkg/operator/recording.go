@@ -0,0 +1,48 @@
+package operator
+
+import (
+	"errors"
+	"os"
+	"sync"
+)
+
+// recording is the file given with WithEventRecording, which is only open while the operator runs
+type recording struct {
+	path string
+	mu   sync.Mutex
+	file *os.File
+}
+
+// open opens the file, appending to the events recorded before
+func (r *recording) open() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	file, err := os.OpenFile(r.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	r.file = file
+	return nil
+}
+
+// Write appends p to the file, which fails unless the operator is running
+func (r *recording) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return 0, errors.New("the event recording is only written while the operator is running")
+	}
+	return r.file.Write(p)
+}
+
+// Close closes the file once the operator has stopped
+func (r *recording) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
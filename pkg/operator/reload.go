@@ -0,0 +1,68 @@
+package operator
+
+import (
+	"context"
+
+	lot_client "github.com/SchweizerischeBundesbahnen/lot/pkg/lot-client"
+	"github.com/SchweizerischeBundesbahnen/lot/pkg/selector"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+// changeNotifier is implemented by selectors which change at runtime, e.g. selector.Reloadable
+type changeNotifier interface {
+	Changed() <-chan struct{}
+}
+
+var _ changeNotifier = &selector.Reloadable{}
+
+var _ manager.LeaderElectionRunnable = &resyncOnChange{}
+
+// resyncOnChange enqueues all primary resources whenever a selector given with WithSelector changes, so that the
+// objects which only match the selector after the change are passed to the handlers. It runs wherever the controller
+// runs, as the controller receives the events.
+type resyncOnChange struct {
+	client      lot_client.Client
+	object      client.Object
+	selectors   []changeNotifier
+	events      chan<- event.GenericEvent
+	allReplicas bool
+}
+
+func (r *resyncOnChange) NeedLeaderElection() bool {
+	return !r.allReplicas
+}
+
+func (r *resyncOnChange) Start(ctx context.Context) error {
+	log := logf.Log.WithName("Selector")
+	changed := make(chan struct{})
+	for _, s := range r.selectors {
+		go func(s changeNotifier) {
+			for {
+				select {
+				case <-s.Changed():
+				case <-ctx.Done():
+					return
+				}
+				select {
+				case changed <- struct{}{}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}(s)
+	}
+	for {
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return nil
+		}
+		log.Info("Selector changed, resyncing all objects")
+		if err := enqueueAll(ctx, r.client, r.object, r.events); err != nil && ctx.Err() == nil {
+			log.Error(err, "unable to resync the objects")
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package operator
+
+import (
+	"context"
+	"math/rand"
+	"sort"
+	"time"
+
+	"github.com/SchweizerischeBundesbahnen/lot/internal/kinds"
+	lot_client "github.com/SchweizerischeBundesbahnen/lot/pkg/lot-client"
+	"github.com/SchweizerischeBundesbahnen/lot/pkg/reconcile"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/robfig/cron/v3"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var scheduleLag = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "lot_schedule_lag_seconds",
+	Help:    "Time between the moment an object was scheduled for a scheduled handler and the moment the handler started",
+	Buckets: []float64{0.01, 0.1, 0.5, 1, 5, 10, 30, 60, 300, 900},
+}, []string{"controller", "handler"})
+
+func init() {
+	metrics.Registry.MustRegister(scheduleLag)
+}
+
+// defaultJitterFraction is the fraction of the schedule's period over which the objects are spread by default
+const defaultJitterFraction = 10
+
+// parseSchedule parses an interval like "30m" or a cron expression like "0 * * * *" or "@hourly"
+func parseSchedule(spec string) (cron.Schedule, error) {
+	if d, err := time.ParseDuration(spec); err == nil && d > 0 {
+		return interval(d), nil
+	}
+	return cron.ParseStandard(spec)
+}
+
+// interval is a cron.Schedule which is due every time the duration has passed, unlike cron.Every it is not
+// rounded to seconds
+type interval time.Duration
+
+func (i interval) Next(t time.Time) time.Time {
+	return t.Add(time.Duration(i))
+}
+
+// jitterFor returns the jitter given with WithJitter, or a tenth of the period of the schedule
+func jitterFor(schedule cron.Schedule, options handlerOptions) time.Duration {
+	if options.jitter != nil {
+		return *options.jitter
+	}
+	next := schedule.Next(time.Now())
+	return schedule.Next(next).Sub(next) / defaultJitterFraction
+}
+
+// withScheduleLag wraps a scheduled handler so that the time it started after the object was due is observed
+func (o *operator) withScheduleLag(fn reconcile.Handler, handler string) reconcile.Handler {
+	if fn == nil {
+		return nil
+	}
+	return func(ctx context.Context, obj client.Object, cl lot_client.Client, scheme *runtime.Scheme) error {
+		if due, ok := reconcile.TriggerPayload[time.Time](ctx); ok {
+			scheduleLag.WithLabelValues(o.name, handler).Observe(time.Since(due).Seconds())
+		}
+		return fn(ctx, obj, cl, scheme)
+	}
+}
+
+// scheduler enqueues all objects matching a scheduled handler whenever its schedule is due. It is only
+// started on the leader, as it does not implement manager.LeaderElectionRunnable.
+type scheduler struct {
+	handler  reconcile.NamedHandler
+	schedule cron.Schedule
+	jitter   time.Duration
+	client   lot_client.Client
+	object   client.Object
+	send     func(ctx context.Context, trigger reconcile.Trigger) error
+}
+
+func (s *scheduler) Start(ctx context.Context) error {
+	log := logf.Log.WithName("Scheduler").WithValues("handler", s.handler.Name)
+	for {
+		due := s.schedule.Next(time.Now())
+		select {
+		case <-time.After(time.Until(due)):
+		case <-ctx.Done():
+			return nil
+		}
+		if err := s.enqueue(ctx, due); err != nil && ctx.Err() == nil {
+			log.Error(err, "unable to enqueue the scheduled objects")
+		}
+	}
+}
+
+// enqueue sends a trigger for every object matching the handler, spread randomly over the jitter after the
+// schedule was due. The trigger's payload is the time the object was due.
+func (s *scheduler) enqueue(ctx context.Context, due time.Time) error {
+	list, err := kinds.NewListFor(s.object, s.client.Scheme())
+	if err != nil {
+		return err
+	}
+	if err := s.client.List(ctx, list); err != nil {
+		return err
+	}
+
+	type scheduled struct {
+		obj client.Object
+		due time.Time
+	}
+	var objs []scheduled
+	if err := meta.EachListItem(list, func(o runtime.Object) error {
+		obj := o.(client.Object)
+		if !s.handler.Matches(obj) {
+			return nil
+		}
+		var offset time.Duration
+		if s.jitter > 0 {
+			offset = time.Duration(rand.Int63n(int64(s.jitter)))
+		}
+		objs = append(objs, scheduled{obj: obj, due: due.Add(offset)})
+		return nil
+	}); err != nil {
+		return err
+	}
+	sort.Slice(objs, func(i, j int) bool { return objs[i].due.Before(objs[j].due) })
+
+	for _, o := range objs {
+		select {
+		case <-time.After(time.Until(o.due)):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		trigger := reconcile.Trigger{Key: client.ObjectKeyFromObject(o.obj), Payload: o.due, Handler: s.handler.Name}
+		if err := s.send(ctx, trigger); err != nil {
+			return err
+		}
+	}
+	return nil
+}
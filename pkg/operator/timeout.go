@@ -0,0 +1,44 @@
+package operator
+
+import (
+	"context"
+
+	lot_client "github.com/SchweizerischeBundesbahnen/lot/pkg/lot-client"
+	"github.com/SchweizerischeBundesbahnen/lot/pkg/reconcile"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var handlerTimeouts = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "lot_handler_timeouts_total",
+	Help: "Number of handler runs which exceeded their timeout",
+}, []string{"controller", "handler"})
+
+func init() {
+	metrics.Registry.MustRegister(handlerTimeouts)
+}
+
+// withTimeout wraps the handler with the timeout given with WithTimeout or WithDefaultTimeout.
+// Timeouts are logged and counted separately from other errors.
+func (o *operator) withTimeout(fn reconcile.Handler, handler string, options handlerOptions) reconcile.Handler {
+	timeout := o.defaultTimeout
+	if options.timeout != nil {
+		timeout = *options.timeout
+	}
+	if fn == nil || timeout == 0 {
+		return fn
+	}
+	fn = reconcile.WithTimeout(fn, timeout)
+	return func(ctx context.Context, obj client.Object, cl lot_client.Client, scheme *runtime.Scheme) error {
+		err := fn(ctx, obj, cl, scheme)
+		if reconcile.IsTimeout(err) {
+			logf.FromContext(ctx).Info("Handler timed out", "handler", handler, "timeout", timeout.String(),
+				"name", obj.GetName(), "namespace", obj.GetNamespace())
+			handlerTimeouts.WithLabelValues(o.name, handler).Inc()
+		}
+		return err
+	}
+}
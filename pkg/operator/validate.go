@@ -0,0 +1,192 @@
+package operator
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/SchweizerischeBundesbahnen/lot/pkg/reconcile"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// ValidationError is returned by Build and Start if the definition of the Operator is invalid. It reports all problems
+// found at once, e.g. invalid handler options, handlers without a function or kinds which are neither registered in the
+// scheme nor known to the API server. Problems found in the definition are *field.Error, whose field is the part of the
+// definition, e.g. "owns[0].predicate" or "handlers[delete]".
+type ValidationError struct {
+	Problems []error
+}
+
+// Error lists all problems of the definition
+func (e *ValidationError) Error() string {
+	msgs := make([]string, 0, len(e.Problems))
+	for _, p := range e.Problems {
+		msgs = append(msgs, p.Error())
+	}
+	return fmt.Sprintf("invalid operator definition: %s", strings.Join(msgs, "; "))
+}
+
+// Unwrap returns the problems, so that they can be matched with errors.Is and errors.As
+func (e *ValidationError) Unwrap() []error {
+	return e.Problems
+}
+
+// newValidationError returns a *ValidationError reporting the problems, or nil if there are none
+func newValidationError(problems []error) error {
+	if len(problems) == 0 {
+		return nil
+	}
+	return &ValidationError{Problems: problems}
+}
+
+// validate checks the whole definition of the operator, i.e. its handlers, its primary resource and its owned resources
+func (o *operator) validate() error {
+	problems := o.validateHandlers()
+
+	gvk, kindProblems := o.validateKind(field.NewPath("object"), o.object)
+	kindProblems = append(kindProblems, o.validateOwns()...)
+	problems = append(problems, kindProblems...)
+
+	// the scopes can only be checked once all kinds are known
+	if len(kindProblems) == 0 {
+		problems = append(problems, flatten(o.validateScopes(gvk))...)
+	}
+	return newValidationError(problems)
+}
+
+// validateOwns returns the problems of the kinds given with WithOwns and WithTrackedOwns
+func (o *operator) validateOwns() []error {
+	var problems []error
+	for _, owns := range []struct {
+		option string
+		path   *field.Path
+		inputs []OwnsInput
+	}{
+		{"WithOwns(...)", field.NewPath("owns"), o.ownsInput},
+		{"WithTrackedOwns(...)", field.NewPath("trackedOwns"), o.trackedOwnsInput},
+	} {
+		seen := map[schema.GroupVersionKind]bool{}
+		for i, input := range owns.inputs {
+			path := owns.path.Index(i)
+			if input.predicate == nil {
+				problems = append(problems, field.Required(path.Child("predicate"), owns.option+" requires a predicate, e.g. predicate.Funcs{}"))
+			}
+			if input.object == nil {
+				problems = append(problems, field.Required(path.Child("object"), owns.option+" requires an object"))
+				continue
+			}
+			ownedGVK, errs := o.validateKind(path.Child("object"), input.object)
+			problems = append(problems, errs...)
+			if len(errs) > 0 {
+				continue
+			}
+			if seen[ownedGVK] {
+				problems = append(problems, field.Duplicate(path.Child("object"), ownedGVK.String()))
+			}
+			seen[ownedGVK] = true
+		}
+	}
+	return problems
+}
+
+// validateHandlers returns the errors of the handler options and the handlers registered without a function.
+// Scheduled handlers may be nil, as they simply resync the objects. Parallel handlers change copies of the object,
+// whose status could not be written.
+func (o *operator) validateHandlers() []error {
+	problems := flatten(o.errs)
+	if o.statusUpdates && o.reconcileHandlers.Parallel {
+		problems = append(problems, field.Forbidden(field.NewPath("statusUpdates"), "WithStatusUpdates() cannot be combined with WithParallelHandlers()"))
+	}
+	path := field.NewPath("handlers")
+	for _, handlers := range []struct {
+		method string
+		names  []string
+	}{
+		{"OnCreateOrUpdate(...)", nilHandlers(o.reconcileHandlers.CreateOrUpdateHandlers)},
+		{"OnDelete(...)", nilHandlers(o.reconcileHandlers.DeleteHandlers)},
+		{"OnTrigger(...)", nilHandlers(o.reconcileHandlers.TriggerHandlers)},
+	} {
+		for _, name := range handlers.names {
+			problems = append(problems, field.Required(path.Key(name), handlers.method+" requires a handler function"))
+		}
+	}
+	for _, v := range o.validators {
+		if v.Validator == nil {
+			problems = append(problems, field.Required(path.Key(v.Name), "OnValidate(...) requires a validator"))
+		}
+	}
+	for _, m := range o.mutators {
+		if m.Mutator == nil {
+			problems = append(problems, field.Required(path.Key(m.Name), "OnMutate(...) requires a mutator"))
+		}
+	}
+	return problems
+}
+
+// nilHandlers returns the names of the handlers without a function
+func nilHandlers(handlers []reconcile.NamedHandler) []string {
+	var names []string
+	for _, h := range handlers {
+		if h.Handler == nil {
+			names = append(names, h.Name)
+		}
+	}
+	return names
+}
+
+// validateKind returns the kind of obj and the problems if typed objects are not registered in the scheme, or if the
+// kind is not known to the API server. Kinds defined by the CRDs given with WithCRDs are not known before the
+// operator is started, and the RESTMapper of an operator created with WithOfflineManager only knows built-in kinds.
+func (o *operator) validateKind(path *field.Path, obj client.Object) (schema.GroupVersionKind, []error) {
+	gvk, err := apiutil.GVKForObject(obj, o.manager.GetScheme())
+	if err != nil {
+		if _, ok := obj.(runtime.Unstructured); ok {
+			return gvk, []error{field.Invalid(path, obj.GetObjectKind().GroupVersionKind().String(), err.Error())}
+		}
+		return gvk, []error{field.Invalid(path, fmt.Sprintf("%T", obj), "type is not registered in the scheme, add it with WithScheme(...)")}
+	}
+	if o.crdOf(gvk) != nil {
+		return gvk, nil
+	}
+	_, err = o.manager.GetRESTMapper().RESTMapping(gvk.GroupKind(), gvk.Version)
+	if meta.IsNoMatchError(err) {
+		return gvk, []error{field.NotFound(path, gvk.String())}
+	}
+	if err != nil {
+		return gvk, []error{field.InternalError(path, err)}
+	}
+	return gvk, nil
+}
+
+// crdOf returns the CRD given with WithCRDs which defines the kind, or nil
+func (o *operator) crdOf(gvk schema.GroupVersionKind) *unstructured.Unstructured {
+	for _, crd := range o.crds {
+		group, _, _ := unstructured.NestedString(crd.Object, "spec", "group")
+		kind, _, _ := unstructured.NestedString(crd.Object, "spec", "names", "kind")
+		if group == gvk.Group && kind == gvk.Kind {
+			return crd
+		}
+	}
+	return nil
+}
+
+// flatten returns the errors joined in err, e.g. with errors.Join
+func flatten(err error) []error {
+	if err == nil {
+		return nil
+	}
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		return []error{err}
+	}
+	var errs []error
+	for _, e := range joined.Unwrap() {
+		errs = append(errs, flatten(e)...)
+	}
+	return errs
+}
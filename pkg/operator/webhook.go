@@ -0,0 +1,67 @@
+package operator
+
+import (
+	"github.com/SchweizerischeBundesbahnen/lot/pkg/admission"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// webhookOptions configure the webhook server of the manager, zero values keep its defaults
+type webhookOptions struct {
+	port     int
+	certDir  string
+	certName string
+	keyName  string
+}
+
+// OnValidate adds a validator which is run by the validating admission webhook of the Operator for objects matching
+// its labels and annotations. All matching validators are run, and the request is denied if any of them fails.
+// The webhook is served by the webhook server of the manager at admission.ValidatePath, see WithWebhookPort and
+// WithWebhookCertificates, and has to be registered with a ValidatingWebhookConfiguration. Of the handler options
+// only WithLabels, WithAnnotations and WithName apply. Validators are run on all replicas.
+func (o *operator) OnValidate(fn admission.Validator, opts ...HandlerOption) {
+	options, sel := o.handlerOptions(opts)
+	name := o.handlerName("validate", len(o.validators), options)
+	o.validators = append(o.validators, admission.NamedValidator{Name: name, Validator: fn, Selector: sel})
+}
+
+// OnMutate adds a mutator which is run by the mutating admission webhook of the Operator for objects matching its
+// labels and annotations when they are created or updated. The mutators are run in the order they have been added.
+// The webhook is served at admission.MutatePath and has to be registered with a MutatingWebhookConfiguration,
+// see OnValidate.
+func (o *operator) OnMutate(fn admission.Mutator, opts ...HandlerOption) {
+	options, sel := o.handlerOptions(opts)
+	name := o.handlerName("mutate", len(o.mutators), options)
+	o.mutators = append(o.mutators, admission.NamedMutator{Name: name, Mutator: fn, Selector: sel})
+}
+
+// registerWebhooks registers the validating and mutating webhook with the webhook server of the manager, which is
+// only set up if there are any validators or mutators
+func (o *operator) registerWebhooks(gvk schema.GroupVersionKind) error {
+	if len(o.validators) == 0 && len(o.mutators) == 0 {
+		return nil
+	}
+	srv := o.manager.GetWebhookServer()
+	if o.webhook.port != 0 {
+		srv.Port = o.webhook.port
+	}
+	if o.webhook.certDir != "" {
+		srv.CertDir, srv.CertName, srv.KeyName = o.webhook.certDir, o.webhook.certName, o.webhook.keyName
+	}
+
+	if len(o.validators) > 0 {
+		h, err := admission.NewValidatingHandler(o.object, o.client, o.validators...)
+		if err != nil {
+			return err
+		}
+		srv.Register(admission.ValidatePath(gvk), &webhook.Admission{Handler: h})
+	}
+	if len(o.mutators) > 0 {
+		h, err := admission.NewMutatingHandler(o.object, o.client, o.mutators...)
+		if err != nil {
+			return err
+		}
+		srv.Register(admission.MutatePath(gvk), &webhook.Admission{Handler: h})
+	}
+	return nil
+}
@@ -0,0 +1,185 @@
+package ownership
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/SchweizerischeBundesbahnen/lot/internal/kinds"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// Owner references only work for children in the same namespace as their owner. LOT therefore tracks
+// owners of children in other namespaces or of cluster-scoped children with a label and annotations.
+// The UID is stored in a label, so that all tracked children of a kind can be listed efficiently,
+// while the remaining values could exceed the length limit of label values and are stored in annotations.
+const (
+	OwnerUIDLabel             = "lot.sbb.ch/owner-uid"
+	OwnerAPIVersionAnnotation = "lot.sbb.ch/owner-api-version"
+	OwnerKindAnnotation       = "lot.sbb.ch/owner-kind"
+	OwnerNamespaceAnnotation  = "lot.sbb.ch/owner-namespace"
+	OwnerNameAnnotation       = "lot.sbb.ch/owner-name"
+)
+
+// Reference identifies the owner of a tracked child
+type Reference struct {
+	GroupVersionKind schema.GroupVersionKind
+	Namespace        string
+	Name             string
+	UID              types.UID
+}
+
+// ReferenceTo returns the Reference to the given owner
+func ReferenceTo(owner client.Object, scheme *runtime.Scheme) (Reference, error) {
+	gvk, err := apiutil.GVKForObject(owner, scheme)
+	if err != nil {
+		return Reference{}, err
+	}
+	return Reference{
+		GroupVersionKind: gvk,
+		Namespace:        owner.GetNamespace(),
+		Name:             owner.GetName(),
+		UID:              owner.GetUID(),
+	}, nil
+}
+
+// SetOwner marks child as owned by owner. In contrast to owner references, the owner may be
+// in another namespace than the child and the child may be cluster-scoped. It returns an
+// error if child is already owned by another object.
+func SetOwner(owner, child client.Object, scheme *runtime.Scheme) error {
+	ref, err := ReferenceTo(owner, scheme)
+	if err != nil {
+		return err
+	}
+	if ref.UID == "" {
+		return fmt.Errorf("owner %s/%s has no UID, it has to be read from the cluster first", ref.Namespace, ref.Name)
+	}
+	if existing, ok := OwnerOf(child); ok && existing.UID != ref.UID {
+		return fmt.Errorf("object %s/%s is already owned by %s %s/%s",
+			child.GetNamespace(), child.GetName(), existing.GroupVersionKind.Kind, existing.Namespace, existing.Name)
+	}
+
+	labels := child.GetLabels()
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	labels[OwnerUIDLabel] = string(ref.UID)
+	child.SetLabels(labels)
+
+	annotations := child.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	apiVersion, kind := ref.GroupVersionKind.ToAPIVersionAndKind()
+	annotations[OwnerAPIVersionAnnotation] = apiVersion
+	annotations[OwnerKindAnnotation] = kind
+	annotations[OwnerNamespaceAnnotation] = ref.Namespace
+	annotations[OwnerNameAnnotation] = ref.Name
+	child.SetAnnotations(annotations)
+	return nil
+}
+
+// RemoveOwner removes the ownership set by SetOwner from child
+func RemoveOwner(child client.Object) {
+	labels := child.GetLabels()
+	delete(labels, OwnerUIDLabel)
+	child.SetLabels(labels)
+
+	annotations := child.GetAnnotations()
+	for _, k := range []string{OwnerAPIVersionAnnotation, OwnerKindAnnotation, OwnerNamespaceAnnotation, OwnerNameAnnotation} {
+		delete(annotations, k)
+	}
+	child.SetAnnotations(annotations)
+}
+
+// OwnerOf returns the owner set by SetOwner. The second return value is false if child
+// is not owned.
+func OwnerOf(child client.Object) (Reference, bool) {
+	uid, ok := child.GetLabels()[OwnerUIDLabel]
+	if !ok {
+		return Reference{}, false
+	}
+	annotations := child.GetAnnotations()
+	kind, ok := annotations[OwnerKindAnnotation]
+	if !ok {
+		return Reference{}, false
+	}
+	name, ok := annotations[OwnerNameAnnotation]
+	if !ok {
+		return Reference{}, false
+	}
+	return Reference{
+		GroupVersionKind: schema.FromAPIVersionAndKind(annotations[OwnerAPIVersionAnnotation], kind),
+		Namespace:        annotations[OwnerNamespaceAnnotation],
+		Name:             name,
+		UID:              types.UID(uid),
+	}, true
+}
+
+// IsOwnedBy returns true if child is owned by the object identified by owner. The version of the
+// owner kind is not compared, and neither is the UID if it is not set in owner.
+func IsOwnedBy(child client.Object, owner Reference) bool {
+	ref, ok := OwnerOf(child)
+	if !ok {
+		return false
+	}
+	if ref.GroupVersionKind.GroupKind() != owner.GroupVersionKind.GroupKind() {
+		return false
+	}
+	if ref.Namespace != owner.Namespace || ref.Name != owner.Name {
+		return false
+	}
+	return owner.UID == "" || ref.UID == owner.UID
+}
+
+// EnqueueOwner returns an event handler which enqueues a reconcile request for the owner
+// of tracked children, if the owner is of the given kind.
+func EnqueueOwner(ownerGVK schema.GroupVersionKind) handler.EventHandler {
+	return handler.EnqueueRequestsFromMapFunc(func(child client.Object) []reconcile.Request {
+		ref, ok := OwnerOf(child)
+		if !ok || ref.GroupVersionKind.GroupKind() != ownerGVK.GroupKind() {
+			return nil
+		}
+		return []reconcile.Request{{NamespacedName: types.NamespacedName{Namespace: ref.Namespace, Name: ref.Name}}}
+	})
+}
+
+// Cleanup deletes all children of the given kinds which are owned by owner. If the UID of owner is set, the children
+// are listed with its UID label, otherwise all tracked children are listed and matched by the kind, namespace and name
+// of owner. The kinds are given as objects, e.g. &v1.ConfigMap{}, and are listed in all namespaces.
+func Cleanup(ctx context.Context, cl client.Client, owner Reference, childKinds ...client.Object) error {
+	var selector client.ListOption = client.HasLabels{OwnerUIDLabel}
+	if owner.UID != "" {
+		selector = client.MatchingLabels{OwnerUIDLabel: string(owner.UID)}
+	}
+	var errs error
+	for _, kind := range childKinds {
+		list, err := kinds.NewListFor(kind, cl.Scheme())
+		if err != nil {
+			errs = errors.Join(errs, err)
+			continue
+		}
+		if err := cl.List(ctx, list, selector); err != nil {
+			errs = errors.Join(errs, err)
+			continue
+		}
+		err = meta.EachListItem(list, func(o runtime.Object) error {
+			child := o.(client.Object)
+			if !IsOwnedBy(child, owner) {
+				return nil
+			}
+			err := cl.Delete(ctx, child, client.PropagationPolicy(metav1.DeletePropagationBackground))
+			return client.IgnoreNotFound(err)
+		})
+		errs = errors.Join(errs, err)
+	}
+	return errs
+}
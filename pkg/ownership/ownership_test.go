@@ -0,0 +1,127 @@
+package ownership_test
+
+import (
+	"context"
+
+	"github.com/SchweizerischeBundesbahnen/lot/pkg/ownership"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllertest"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+var _ = Describe("ownership", func() {
+	var owner *v1.Secret
+	var child *rbacv1.ClusterRoleBinding
+	BeforeEach(func() {
+		owner = &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "biz", Name: "baz", UID: "uid-1"}}
+		child = &rbacv1.ClusterRoleBinding{ObjectMeta: metav1.ObjectMeta{Name: "biz-baz"}}
+	})
+
+	Describe("When setting an owner", func() {
+		It("should mark the child as owned", func() {
+			Expect(ownership.SetOwner(owner, child, scheme.Scheme)).To(Succeed())
+			ref, ok := ownership.OwnerOf(child)
+			Expect(ok).To(BeTrue())
+			Expect(ref.GroupVersionKind).To(Equal(v1.SchemeGroupVersion.WithKind("Secret")))
+			Expect(ref.Namespace).To(Equal("biz"))
+			Expect(ref.Name).To(Equal("baz"))
+			Expect(ref.UID).To(BeEquivalentTo("uid-1"))
+		})
+		It("should fail for an owner without UID", func() {
+			owner.UID = ""
+			Expect(ownership.SetOwner(owner, child, scheme.Scheme)).ToNot(Succeed())
+		})
+		It("should fail if the child is owned by another object", func() {
+			Expect(ownership.SetOwner(owner, child, scheme.Scheme)).To(Succeed())
+			other := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "biz", Name: "other", UID: "uid-2"}}
+			Expect(ownership.SetOwner(other, child, scheme.Scheme)).ToNot(Succeed())
+		})
+		It("should be removable", func() {
+			Expect(ownership.SetOwner(owner, child, scheme.Scheme)).To(Succeed())
+			ownership.RemoveOwner(child)
+			_, ok := ownership.OwnerOf(child)
+			Expect(ok).To(BeFalse())
+			Expect(child.GetLabels()).To(BeEmpty())
+			Expect(child.GetAnnotations()).To(BeEmpty())
+		})
+	})
+
+	Describe("When mapping events of children", func() {
+		var q controllertest.Queue
+		BeforeEach(func() {
+			q = controllertest.Queue{Interface: workqueue.New()}
+		})
+		It("should enqueue the owner of the configured kind", func() {
+			Expect(ownership.SetOwner(owner, child, scheme.Scheme)).To(Succeed())
+			ownership.EnqueueOwner(v1.SchemeGroupVersion.WithKind("Secret")).Create(event.CreateEvent{Object: child}, q)
+			Expect(q.Len()).To(Equal(1))
+			item, _ := q.Get()
+			Expect(item).To(Equal(reconcile.Request{NamespacedName: client.ObjectKeyFromObject(owner)}))
+		})
+		It("should ignore children owned by another kind", func() {
+			Expect(ownership.SetOwner(owner, child, scheme.Scheme)).To(Succeed())
+			ownership.EnqueueOwner(v1.SchemeGroupVersion.WithKind("ConfigMap")).Create(event.CreateEvent{Object: child}, q)
+			Expect(q.Len()).To(Equal(0))
+		})
+		It("should ignore children without owner", func() {
+			ownership.EnqueueOwner(v1.SchemeGroupVersion.WithKind("Secret")).Create(event.CreateEvent{Object: child}, q)
+			Expect(q.Len()).To(Equal(0))
+		})
+	})
+
+	Describe("When cleaning up children", func() {
+		var cl client.Client
+		var ctx context.Context
+		var stale, unrelated *rbacv1.ClusterRoleBinding
+		var shared *v1.ConfigMap
+		BeforeEach(func() {
+			ctx = context.Background()
+			Expect(ownership.SetOwner(owner, child, scheme.Scheme)).To(Succeed())
+
+			previous := owner.DeepCopy()
+			previous.UID = "uid-0"
+			stale = &rbacv1.ClusterRoleBinding{ObjectMeta: metav1.ObjectMeta{Name: "stale"}}
+			Expect(ownership.SetOwner(previous, stale, scheme.Scheme)).To(Succeed())
+
+			other := &v1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "biz", Name: "other", UID: "uid-2"}}
+			unrelated = &rbacv1.ClusterRoleBinding{ObjectMeta: metav1.ObjectMeta{Name: "unrelated"}}
+			Expect(ownership.SetOwner(other, unrelated, scheme.Scheme)).To(Succeed())
+
+			shared = &v1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "shared", Name: "biz-baz"}}
+			Expect(ownership.SetOwner(owner, shared, scheme.Scheme)).To(Succeed())
+
+			cl = fake.NewClientBuilder().WithObjects(child, stale, unrelated, shared).Build()
+		})
+		exists := func(obj client.Object) bool {
+			return cl.Get(ctx, client.ObjectKeyFromObject(obj), obj) == nil
+		}
+		It("should delete all children of a deleted owner", func() {
+			ref, err := ownership.ReferenceTo(owner, scheme.Scheme)
+			Expect(err).ToNot(HaveOccurred())
+			ref.UID = ""
+			Expect(ownership.Cleanup(ctx, cl, ref, &rbacv1.ClusterRoleBinding{}, &v1.ConfigMap{})).To(Succeed())
+			Expect(exists(child)).To(BeFalse())
+			Expect(exists(stale)).To(BeFalse())
+			Expect(exists(shared)).To(BeFalse())
+			Expect(exists(unrelated)).To(BeTrue())
+		})
+		It("should only delete the children of the owner with its UID", func() {
+			ref, err := ownership.ReferenceTo(owner, scheme.Scheme)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(ownership.Cleanup(ctx, cl, ref, &rbacv1.ClusterRoleBinding{}, &v1.ConfigMap{})).To(Succeed())
+			Expect(exists(child)).To(BeFalse())
+			Expect(exists(stale)).To(BeTrue())
+			Expect(exists(shared)).To(BeFalse())
+			Expect(exists(unrelated)).To(BeTrue())
+		})
+	})
+})
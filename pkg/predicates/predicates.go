@@ -15,6 +15,12 @@ func CreateOrUpdateByMetadata(labels map[string]string, annotations map[string]s
 	if err != nil {
 		return nil, err
 	}
+	return CreateOrUpdateBySelector(s), nil
+}
+
+// CreateOrUpdateBySelector returns a predicate like CreateOrUpdateByMetadata, which matches the objects with
+// the given selector, e.g. a selector.Reloadable.
+func CreateOrUpdateBySelector(s selector.Selector) predicate.Predicate {
 	result := predicate.Funcs{
 		CreateFunc: func(event event.CreateEvent) bool {
 			var labels, annotations map[string]string
@@ -43,7 +49,7 @@ func CreateOrUpdateByMetadata(labels map[string]string, annotations map[string]s
 			return s.Matches(oldLabels, oldAnnotations) || s.Matches(newLabels, newAnnotations)
 		},
 	}
-	return result, nil
+	return result
 }
 
 // DeleteByMetadata returns a predicate that filters Delete events based
@@ -53,6 +59,12 @@ func DeleteByMetadata(labels map[string]string, annotations map[string]string) (
 	if err != nil {
 		return nil, err
 	}
+	return DeleteBySelector(s), nil
+}
+
+// DeleteBySelector returns a predicate like DeleteByMetadata, which matches the objects with
+// the given selector, e.g. a selector.Reloadable.
+func DeleteBySelector(s selector.Selector) predicate.Predicate {
 	result := predicate.Funcs{
 		DeleteFunc: func(event event.DeleteEvent) bool {
 			var labels, annotations map[string]string
@@ -65,7 +77,41 @@ func DeleteByMetadata(labels map[string]string, annotations map[string]string) (
 			return s.Matches(labels, annotations)
 		},
 	}
-	return result, nil
+	return result
+}
+
+// TerminatingByMetadata returns a predicate that filters Update events of objects which are
+// terminating, i.e. waiting for their finalizers to be removed, based on labels or annotations.
+func TerminatingByMetadata(labels map[string]string, annotations map[string]string) (predicate.Predicate, error) {
+	s, err := selector.NewSelector(labels, annotations)
+	if err != nil {
+		return nil, err
+	}
+	return TerminatingBySelector(s), nil
+}
+
+// TerminatingBySelector returns a predicate like TerminatingByMetadata, which matches the objects with
+// the given selector, e.g. a selector.Reloadable.
+func TerminatingBySelector(s selector.Selector) predicate.Predicate {
+	result := predicate.Funcs{
+		CreateFunc:  func(event.CreateEvent) bool { return false },
+		DeleteFunc:  func(event.DeleteEvent) bool { return false },
+		GenericFunc: func(event.GenericEvent) bool { return false },
+		UpdateFunc: func(event event.UpdateEvent) bool {
+			if event.ObjectNew.GetDeletionTimestamp() == nil {
+				return false
+			}
+			var labels, annotations map[string]string
+			if labels = event.ObjectNew.GetLabels(); labels == nil {
+				labels = map[string]string{}
+			}
+			if annotations = event.ObjectNew.GetAnnotations(); annotations == nil {
+				annotations = map[string]string{}
+			}
+			return s.Matches(labels, annotations)
+		},
+	}
+	return result
 }
 
 // Log returns a predicate that adds a logger to the given predicates so
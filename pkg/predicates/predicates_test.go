@@ -753,6 +753,45 @@ var _ = Describe("Predicates", func() {
 				})
 			})
 		})
+		Describe("when checking a TerminatingByMetadata predicate", func() {
+			var instance predicate.Predicate
+			var terminating *corev1.Pod
+			BeforeEach(func() {
+				var err error
+				instance, err = predicates.TerminatingByMetadata(testLabels, map[string]string{})
+				Expect(instance).ToNot(BeNil())
+				Expect(err).ToNot(HaveOccurred())
+				now := metav1.Now()
+				terminating = &corev1.Pod{
+					ObjectMeta: metav1.ObjectMeta{
+						Namespace:         "biz",
+						Name:              "baz",
+						Labels:            testLabels,
+						DeletionTimestamp: &now,
+						Finalizers:        []string{"foo"},
+					},
+				}
+			})
+			It("should return true for update events of terminating objects with the expected labels", func() {
+				evt := event.UpdateEvent{ObjectOld: &corev1.Pod{}, ObjectNew: terminating}
+				Expect(instance.Update(evt)).To(BeTrue())
+			})
+			It("should return false for update events of terminating objects missing the expected labels", func() {
+				terminating.Labels = otherLabels
+				evt := event.UpdateEvent{ObjectOld: &corev1.Pod{}, ObjectNew: terminating}
+				Expect(instance.Update(evt)).To(BeFalse())
+			})
+			It("should return false for update events of objects which are not terminating", func() {
+				terminating.DeletionTimestamp = nil
+				evt := event.UpdateEvent{ObjectOld: &corev1.Pod{}, ObjectNew: terminating}
+				Expect(instance.Update(evt)).To(BeFalse())
+			})
+			It("should return false for all create/delete/generic events", func() {
+				Expect(instance.Create(event.CreateEvent{Object: terminating})).To(BeFalse())
+				Expect(instance.Delete(event.DeleteEvent{Object: terminating})).To(BeFalse())
+				Expect(instance.Generic(event.GenericEvent{Object: terminating})).To(BeFalse())
+			})
+		})
 	})
 	Describe("When checking a Log predicate", func() {
 		var pod *corev1.Pod
@@ -0,0 +1,89 @@
+package predicates
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// RecordedEvent is a single event written by Record
+type RecordedEvent struct {
+	Time      time.Time                  `json:"time"`
+	Type      string                     `json:"type"`
+	Accepted  bool                       `json:"accepted"`
+	Object    *unstructured.Unstructured `json:"object"`
+	OldObject *unstructured.Unstructured `json:"oldObject,omitempty"`
+}
+
+// Record returns a predicate that writes all events, accepted and ignored ones, together with
+// a snapshot of the objects to w as JSON lines. The scheme is used to determine the kind of typed
+// objects. The return value of the input predicates is not changed. Generic events are not recorded, as they do
+// not describe a change of the object and cannot be replayed.
+// Note that the snapshots contain the complete objects, including the data of Secrets.
+func Record(w io.Writer, scheme *runtime.Scheme, p ...predicate.Predicate) predicate.Predicate {
+	combined := predicate.And(p...)
+	log := logf.Log.WithName("EventRecorder")
+	encoder := json.NewEncoder(w)
+	var mu sync.Mutex
+
+	record := func(action string, decision bool, o client.Object, old client.Object) bool {
+		evt := RecordedEvent{Time: time.Now().UTC(), Type: action, Accepted: decision}
+		var err error
+		if evt.Object, err = snapshot(o, scheme); err != nil {
+			log.Error(err, "Failed to record event", "event", action, "name", o.GetName(), "namespace", o.GetNamespace())
+			return decision
+		}
+		if old != nil {
+			if evt.OldObject, err = snapshot(old, scheme); err != nil {
+				log.Error(err, "Failed to record event", "event", action, "name", o.GetName(), "namespace", o.GetNamespace())
+				return decision
+			}
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		if err := encoder.Encode(evt); err != nil {
+			log.Error(err, "Failed to record event", "event", action, "name", o.GetName(), "namespace", o.GetNamespace())
+		}
+		return decision
+	}
+
+	return predicate.Funcs{
+		CreateFunc: func(e event.CreateEvent) bool {
+			return record("CREATE", combined.Create(e), e.Object, nil)
+		},
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			return record("UPDATE", combined.Update(e), e.ObjectNew, e.ObjectOld)
+		},
+		DeleteFunc: func(e event.DeleteEvent) bool {
+			return record("DELETE", combined.Delete(e), e.Object, nil)
+		},
+		GenericFunc: func(e event.GenericEvent) bool {
+			return combined.Generic(e)
+		},
+	}
+}
+
+// snapshot converts obj into an unstructured object which includes its kind
+func snapshot(obj client.Object, scheme *runtime.Scheme) (*unstructured.Unstructured, error) {
+	gvk, err := apiutil.GVKForObject(obj, scheme)
+	if err != nil {
+		return nil, err
+	}
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil, err
+	}
+	u := &unstructured.Unstructured{Object: content}
+	u.SetGroupVersionKind(gvk)
+	return u, nil
+}
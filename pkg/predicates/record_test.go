@@ -0,0 +1,72 @@
+package predicates_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+
+	"github.com/SchweizerischeBundesbahnen/lot/pkg/predicates"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+var _ = Describe("Record", func() {
+	var buf *bytes.Buffer
+	var pod, oldPod *corev1.Pod
+	var instance predicate.Predicate
+	BeforeEach(func() {
+		buf = &bytes.Buffer{}
+		pod = &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "biz", Name: "baz", Labels: map[string]string{"foo": "bar"}}}
+		oldPod = pod.DeepCopy()
+		oldPod.Labels = nil
+		accept := predicate.NewPredicateFuncs(func(o client.Object) bool {
+			return o.GetLabels()["foo"] == "bar"
+		})
+		instance = predicates.Record(buf, scheme.Scheme, accept)
+	})
+	recorded := func() []predicates.RecordedEvent {
+		var events []predicates.RecordedEvent
+		scanner := bufio.NewScanner(buf)
+		for scanner.Scan() {
+			var evt predicates.RecordedEvent
+			Expect(json.Unmarshal(scanner.Bytes(), &evt)).To(Succeed())
+			events = append(events, evt)
+		}
+		return events
+	}
+
+	It("should not change the decision of the input predicates", func() {
+		Expect(instance.Create(event.CreateEvent{Object: pod})).To(BeTrue())
+		Expect(instance.Create(event.CreateEvent{Object: oldPod})).To(BeFalse())
+	})
+	It("should record accepted and ignored events as JSON lines", func() {
+		instance.Create(event.CreateEvent{Object: pod})
+		instance.Delete(event.DeleteEvent{Object: oldPod})
+		events := recorded()
+		Expect(events).To(HaveLen(2))
+		Expect(events[0].Type).To(Equal("CREATE"))
+		Expect(events[0].Accepted).To(BeTrue())
+		Expect(events[1].Type).To(Equal("DELETE"))
+		Expect(events[1].Accepted).To(BeFalse())
+	})
+	It("should not record generic events", func() {
+		Expect(instance.Generic(event.GenericEvent{Object: pod})).To(BeTrue())
+		Expect(recorded()).To(BeEmpty())
+	})
+	It("should record snapshots including the kind of typed objects", func() {
+		instance.Update(event.UpdateEvent{ObjectOld: oldPod, ObjectNew: pod})
+		events := recorded()
+		Expect(events).To(HaveLen(1))
+		Expect(events[0].Object.GetKind()).To(Equal("Pod"))
+		Expect(events[0].Object.GetAPIVersion()).To(Equal("v1"))
+		Expect(events[0].Object.GetLabels()).To(HaveKeyWithValue("foo", "bar"))
+		Expect(events[0].OldObject).ToNot(BeNil())
+		Expect(events[0].OldObject.GetLabels()).To(BeEmpty())
+	})
+})
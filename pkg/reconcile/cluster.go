@@ -0,0 +1,17 @@
+package reconcile
+
+import "context"
+
+type clusterKey struct{}
+
+// ContextWithCluster returns a copy of ctx which carries the identifier of the cluster an object is reconciled in
+func ContextWithCluster(ctx context.Context, cluster string) context.Context {
+	return context.WithValue(ctx, clusterKey{}, cluster)
+}
+
+// ClusterFromContext returns the identifier of the cluster the handler is run for, or an empty string for the
+// cluster of the operator's manager
+func ClusterFromContext(ctx context.Context) string {
+	cluster, _ := ctx.Value(clusterKey{}).(string)
+	return cluster
+}
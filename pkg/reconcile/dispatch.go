@@ -0,0 +1,92 @@
+package reconcile
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	lot_client "github.com/SchweizerischeBundesbahnen/lot/pkg/lot-client"
+	"go.opentelemetry.io/otel/trace"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// dispatch runs the handlers whose selector matches obj. A failing handler stops all following handlers,
+// whereas a throttled handler does not keep the following handlers from running and the object is requeued
+// for it once all handlers have run.
+func dispatch(ctx context.Context, tracer trace.Tracer, handlers []NamedHandler, parallel bool, obj client.Object, cl lot_client.Client, scheme *runtime.Scheme) (reconcile.Result, error) {
+	matching := matchingHandlers(ctx, handlers, obj)
+	var errs []error
+	if parallel {
+		errs = runParallel(ctx, tracer, matching, obj, cl, scheme)
+	} else {
+		errs = runSequential(ctx, tracer, matching, obj, cl, scheme)
+	}
+
+	var failures []error
+	var requeue error
+	for _, err := range errs {
+		var r requeuer
+		switch {
+		case err == nil:
+		case errors.As(err, &r):
+			requeue = earliest(requeue, err)
+		default:
+			failures = append(failures, err)
+		}
+	}
+	if len(failures) > 0 {
+		return result(ctx, errors.Join(failures...))
+	}
+	if requeue != nil {
+		return result(ctx, requeue)
+	}
+	return reconcile.Result{}, nil
+}
+
+// matchingHandlers returns the handlers whose selector matches obj
+func matchingHandlers(ctx context.Context, handlers []NamedHandler, obj client.Object) []NamedHandler {
+	var matching []NamedHandler
+	for _, h := range handlers {
+		if h.Handler == nil {
+			continue
+		}
+		if !h.Matches(obj) {
+			logf.FromContext(ctx).V(1).Info("skipping handler, the object does not match its selector", "handler", h.Name)
+			continue
+		}
+		matching = append(matching, h)
+	}
+	return matching
+}
+
+// runSequential runs the handlers one after the other until a handler returns an error
+// other than a ThrottledError
+func runSequential(ctx context.Context, tracer trace.Tracer, handlers []NamedHandler, obj client.Object, cl lot_client.Client, scheme *runtime.Scheme) []error {
+	var errs []error
+	for _, h := range handlers {
+		err := runTraced(ctx, tracer, h, obj, cl, scheme)
+		errs = append(errs, err)
+		if err != nil && !IsThrottled(err) {
+			break
+		}
+	}
+	return errs
+}
+
+// runParallel runs all handlers concurrently, each with its own copy of obj
+func runParallel(ctx context.Context, tracer trace.Tracer, handlers []NamedHandler, obj client.Object, cl lot_client.Client, scheme *runtime.Scheme) []error {
+	errs := make([]error, len(handlers))
+	var wg sync.WaitGroup
+	for i, h := range handlers {
+		wg.Add(1)
+		go func(i int, h NamedHandler) {
+			defer wg.Done()
+			errs[i] = runTraced(ctx, tracer, h, obj.DeepCopyObject().(client.Object), cl, scheme)
+		}(i, h)
+	}
+	wg.Wait()
+	return errs
+}
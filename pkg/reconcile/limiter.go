@@ -0,0 +1,89 @@
+package reconcile
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	lot_client "github.com/SchweizerischeBundesbahnen/lot/pkg/lot-client"
+	"golang.org/x/time/rate"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// concurrencyRetryInterval is the delay after which an object is requeued when all
+// concurrency slots of a handler are taken
+const concurrencyRetryInterval = time.Second
+
+// ThrottledError is returned by handlers wrapped with a Limiter which are not run because their
+// concurrency or rate limit has been reached. The object is requeued after the given duration
+// instead of blocking a worker of the controller.
+type ThrottledError struct {
+	Reason string
+	After  time.Duration
+}
+
+func (e *ThrottledError) Error() string {
+	return fmt.Sprintf("handler throttled: %s", e.Reason)
+}
+
+// RequeueAfter returns the duration after which the throttled handler should be retried
+func (e *ThrottledError) RequeueAfter() time.Duration {
+	return e.After
+}
+
+// IsThrottled returns true if the error or any error it wraps is a ThrottledError
+func IsThrottled(err error) bool {
+	var throttled *ThrottledError
+	return errors.As(err, &throttled)
+}
+
+// Limiter limits the number of concurrent runs and the rate of a single Handler, so that a slow
+// handler does not take up all workers of the controller
+type Limiter struct {
+	slots chan struct{}
+	rate  *rate.Limiter
+}
+
+// NewLimiter returns a Limiter which allows up to concurrency parallel runs and limit runs per
+// second with the given burst. A concurrency of 0 or a limit of rate.Inf disables the respective limit.
+func NewLimiter(concurrency int, limit rate.Limit, burst int) *Limiter {
+	l := &Limiter{}
+	if concurrency > 0 {
+		l.slots = make(chan struct{}, concurrency)
+	}
+	if limit != rate.Inf {
+		l.rate = rate.NewLimiter(limit, burst)
+	}
+	return l
+}
+
+// Wrap returns a Handler running fn within the limits of the Limiter. When a limit has been
+// reached, fn is not run and a ThrottledError is returned.
+func (l *Limiter) Wrap(fn Handler) Handler {
+	if fn == nil {
+		return nil
+	}
+	return func(ctx context.Context, obj client.Object, cl lot_client.Client, scheme *runtime.Scheme) error {
+		if l.slots != nil {
+			select {
+			case l.slots <- struct{}{}:
+				defer func() { <-l.slots }()
+			default:
+				return &ThrottledError{Reason: "concurrency limit reached", After: concurrencyRetryInterval}
+			}
+		}
+		if l.rate != nil {
+			reservation := l.rate.Reserve()
+			if !reservation.OK() {
+				return fmt.Errorf("rate limit with a burst of %d never allows the handler to run", l.rate.Burst())
+			}
+			if delay := reservation.Delay(); delay > 0 {
+				reservation.Cancel()
+				return &ThrottledError{Reason: "rate limit reached", After: delay}
+			}
+		}
+		return fn(ctx, obj, cl, scheme)
+	}
+}
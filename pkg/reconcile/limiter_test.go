@@ -0,0 +1,70 @@
+package reconcile_test
+
+import (
+	"context"
+	"time"
+
+	lot_client "github.com/SchweizerischeBundesbahnen/lot/pkg/lot-client"
+	"github.com/SchweizerischeBundesbahnen/lot/pkg/reconcile"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"golang.org/x/time/rate"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("Limiter", func() {
+	var runs int
+	handler := func(ctx context.Context, object client.Object, cl lot_client.Client, scheme *runtime.Scheme) error {
+		runs++
+		return nil
+	}
+	BeforeEach(func() {
+		runs = 0
+	})
+
+	It("should requeue instead of exceeding the concurrency", func() {
+		running, release := make(chan struct{}), make(chan struct{})
+		blocking := reconcile.NewLimiter(1, rate.Inf, 0).Wrap(func(ctx context.Context, object client.Object, cl lot_client.Client, scheme *runtime.Scheme) error {
+			close(running)
+			<-release
+			return nil
+		})
+		done := make(chan error, 1)
+		go func() {
+			done <- blocking(context.Background(), &v1.Secret{}, nil, nil)
+		}()
+		Eventually(running).Should(BeClosed())
+
+		err := blocking(context.Background(), &v1.Secret{}, nil, nil)
+		Expect(reconcile.IsThrottled(err)).To(BeTrue())
+		Expect(err.(*reconcile.ThrottledError).RequeueAfter()).To(BeNumerically(">", 0))
+
+		close(release)
+		Eventually(done).Should(Receive(BeNil()))
+	})
+
+	It("should requeue once the rate limit allows the next run", func() {
+		limited := reconcile.NewLimiter(0, rate.Every(time.Hour), 2).Wrap(handler)
+		Expect(limited(context.Background(), &v1.Secret{}, nil, nil)).To(Succeed())
+		Expect(limited(context.Background(), &v1.Secret{}, nil, nil)).To(Succeed())
+
+		err := limited(context.Background(), &v1.Secret{}, nil, nil)
+		Expect(reconcile.IsThrottled(err)).To(BeTrue())
+		Expect(err.(*reconcile.ThrottledError).RequeueAfter()).To(BeNumerically("~", time.Hour, time.Minute))
+		Expect(runs).To(Equal(2))
+	})
+
+	It("should not limit without limits", func() {
+		unlimited := reconcile.NewLimiter(0, rate.Inf, 0).Wrap(handler)
+		for i := 0; i < 10; i++ {
+			Expect(unlimited(context.Background(), &v1.Secret{}, nil, nil)).To(Succeed())
+		}
+		Expect(runs).To(Equal(10))
+	})
+
+	It("should keep a nil handler", func() {
+		Expect(reconcile.NewLimiter(1, rate.Inf, 0).Wrap(nil)).To(BeNil())
+	})
+})
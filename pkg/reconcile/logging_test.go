@@ -0,0 +1,57 @@
+package reconcile_test
+
+import (
+	"context"
+
+	lot_client "github.com/SchweizerischeBundesbahnen/lot/pkg/lot-client"
+	"github.com/SchweizerischeBundesbahnen/lot/pkg/reconcile"
+	"github.com/go-logr/logr"
+	"github.com/go-logr/logr/funcr"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	crreconcile "sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+var _ = Describe("logging", func() {
+	var r reconcile.Reconciler
+	var request crreconcile.Request
+	var handlerLog logr.Logger
+	BeforeEach(func() {
+		deployment := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Namespace: "biz", Name: "baz"}}
+		cl := lot_client.New(fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(deployment).Build())
+		request = crreconcile.Request{NamespacedName: client.ObjectKeyFromObject(deployment)}
+		r = reconcile.WithClient(cl, &appsv1.Deployment{}, scheme.Scheme, &reconcile.HandlerFuncs{
+			CreateOrUpdateHandlers: []reconcile.NamedHandler{{Name: "create_or_update", Handler: func(ctx context.Context, object client.Object, cl lot_client.Client, scheme *runtime.Scheme) error {
+				handlerLog = logf.FromContext(ctx)
+				handlerLog.Info("handled")
+				return nil
+			}}},
+		}, reconcile.WithCluster("prod"))
+	})
+
+	It("should pass a logger identifying the object and the handler to the handlers", func() {
+		var logs []string
+		log := funcr.New(func(prefix, args string) { logs = append(logs, args) }, funcr.Options{})
+		_, err := r.Reconcile(logf.IntoContext(context.Background(), log.WithValues("reconcileID", "42")), request)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(logs).To(ContainElement(And(
+			ContainSubstring(`"reconcileID"="42"`),
+			ContainSubstring(`"gvk"="apps/v1, Kind=Deployment"`),
+			ContainSubstring(`"cluster"="prod"`),
+			ContainSubstring(`"handler"="create_or_update"`),
+			ContainSubstring(`"msg"="handled"`))))
+	})
+
+	It("should pass a logger to the handlers without a logger of the controller", func() {
+		_, err := r.Reconcile(context.Background(), request)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(handlerLog.GetSink()).NotTo(BeNil())
+	})
+})
@@ -0,0 +1,111 @@
+package reconcile
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	lot_client "github.com/SchweizerischeBundesbahnen/lot/pkg/lot-client"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// Middleware wraps a Handler in order to add behaviour shared by several handlers, such as logging,
+// metrics or authentication to external systems
+type Middleware func(Handler) Handler
+
+// Chain wraps fn with the given middlewares. The first middleware is the outermost one, i.e. it is
+// called first and sees the result of all others.
+func Chain(fn Handler, middlewares ...Middleware) Handler {
+	if fn == nil {
+		return nil
+	}
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		fn = middlewares[i](fn)
+	}
+	return fn
+}
+
+type handlerNameKey struct{}
+
+// ContextWithHandlerName returns a copy of ctx carrying the name of the handler which is run with it
+func ContextWithHandlerName(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, handlerNameKey{}, name)
+}
+
+// HandlerNameFromContext returns the name of the handler run with ctx, e.g. "create_or_update"
+// or "delete" for handlers of an operator, or an empty string if it is unknown
+func HandlerNameFromContext(ctx context.Context) string {
+	name, _ := ctx.Value(handlerNameKey{}).(string)
+	return name
+}
+
+// Recover returns a Middleware which turns a panic of the handler into an error, so that the object
+// is requeued with backoff instead of the operator crashing. The stack of the panic is logged.
+func Recover() Middleware {
+	return func(fn Handler) Handler {
+		return func(ctx context.Context, obj client.Object, cl lot_client.Client, scheme *runtime.Scheme) (err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					logf.FromContext(ctx).Error(fmt.Errorf("%v", r), "Handler panicked", "stack", string(debug.Stack()))
+					err = fmt.Errorf("handler panicked: %v", r)
+				}
+			}()
+			return fn(ctx, obj, cl, scheme)
+		}
+	}
+}
+
+// Logging returns a Middleware which logs the start and the result of each handler run at debug level, with the
+// logger of the context identifying the object and the handler
+func Logging() Middleware {
+	return func(fn Handler) Handler {
+		return func(ctx context.Context, obj client.Object, cl lot_client.Client, scheme *runtime.Scheme) error {
+			log := logf.FromContext(ctx)
+			log.V(1).Info("Handler started")
+			start := time.Now()
+			err := fn(ctx, obj, cl, scheme)
+			log.V(1).Info("Handler finished", "duration", time.Since(start).String(), "error", err)
+			return err
+		}
+	}
+}
+
+var handlerDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Name: "lot_handler_duration_seconds",
+	Help: "Duration of handler runs by handler and result",
+}, []string{"handler", "result"})
+
+func init() {
+	metrics.Registry.MustRegister(handlerDuration)
+}
+
+// Metrics returns a Middleware which records the duration of each handler run in the histogram
+// lot_handler_duration_seconds, labelled with the handler and its result: success, requeue or error
+func Metrics() Middleware {
+	return func(fn Handler) Handler {
+		return func(ctx context.Context, obj client.Object, cl lot_client.Client, scheme *runtime.Scheme) error {
+			start := time.Now()
+			err := fn(ctx, obj, cl, scheme)
+			handlerDuration.WithLabelValues(HandlerNameFromContext(ctx), resultLabel(err)).Observe(time.Since(start).Seconds())
+			return err
+		}
+	}
+}
+
+func resultLabel(err error) string {
+	var r requeuer
+	switch {
+	case err == nil:
+		return "success"
+	case errors.As(err, &r):
+		return "requeue"
+	default:
+		return "error"
+	}
+}
@@ -0,0 +1,100 @@
+package reconcile_test
+
+import (
+	"context"
+	"errors"
+
+	lot_client "github.com/SchweizerischeBundesbahnen/lot/pkg/lot-client"
+	"github.com/SchweizerischeBundesbahnen/lot/pkg/reconcile"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var _ = Describe("Middleware", func() {
+	var calls []string
+	handler := func(ctx context.Context, object client.Object, cl lot_client.Client, scheme *runtime.Scheme) error {
+		calls = append(calls, "handler")
+		return nil
+	}
+	trace := func(name string) reconcile.Middleware {
+		return func(fn reconcile.Handler) reconcile.Handler {
+			return func(ctx context.Context, object client.Object, cl lot_client.Client, scheme *runtime.Scheme) error {
+				calls = append(calls, name+" before")
+				err := fn(ctx, object, cl, scheme)
+				calls = append(calls, name+" after")
+				return err
+			}
+		}
+	}
+	BeforeEach(func() {
+		calls = nil
+	})
+
+	It("should chain middlewares with the first one outermost", func() {
+		fn := reconcile.Chain(handler, trace("outer"), trace("inner"))
+		Expect(fn(context.Background(), &v1.Secret{}, nil, nil)).To(Succeed())
+		Expect(calls).To(Equal([]string{"outer before", "inner before", "handler", "inner after", "outer after"}))
+	})
+
+	It("should keep a nil handler", func() {
+		Expect(reconcile.Chain(nil, trace("outer"))).To(BeNil())
+	})
+
+	It("should pass the handler name within the context", func() {
+		ctx := reconcile.ContextWithHandlerName(context.Background(), "delete")
+		Expect(reconcile.HandlerNameFromContext(ctx)).To(Equal("delete"))
+		Expect(reconcile.HandlerNameFromContext(context.Background())).To(BeEmpty())
+	})
+
+	Describe("Recover", func() {
+		It("should turn a panic into an error", func() {
+			fn := reconcile.Chain(func(ctx context.Context, object client.Object, cl lot_client.Client, scheme *runtime.Scheme) error {
+				panic("boom")
+			}, reconcile.Recover())
+			err := fn(context.Background(), &v1.Secret{}, nil, nil)
+			Expect(err).To(MatchError(ContainSubstring("boom")))
+		})
+		It("should pass on the result without panic", func() {
+			Expect(reconcile.Chain(handler, reconcile.Recover())(context.Background(), &v1.Secret{}, nil, nil)).To(Succeed())
+			Expect(calls).To(Equal([]string{"handler"}))
+		})
+	})
+
+	Describe("Logging", func() {
+		It("should pass on the result", func() {
+			failure := errors.New("failure")
+			fn := reconcile.Chain(func(ctx context.Context, object client.Object, cl lot_client.Client, scheme *runtime.Scheme) error {
+				return failure
+			}, reconcile.Logging())
+			Expect(fn(context.Background(), &v1.Secret{}, nil, nil)).To(Equal(failure))
+		})
+	})
+
+	Describe("Metrics", func() {
+		It("should record the duration by handler and result", func() {
+			ctx := reconcile.ContextWithHandlerName(context.Background(), "metrics-test")
+			Expect(reconcile.Chain(handler, reconcile.Metrics())(ctx, &v1.Secret{}, nil, nil)).To(Succeed())
+
+			families, err := metrics.Registry.Gather()
+			Expect(err).NotTo(HaveOccurred())
+			var labels []map[string]string
+			for _, family := range families {
+				if family.GetName() != "lot_handler_duration_seconds" {
+					continue
+				}
+				for _, m := range family.GetMetric() {
+					l := map[string]string{}
+					for _, pair := range m.GetLabel() {
+						l[pair.GetName()] = pair.GetValue()
+					}
+					labels = append(labels, l)
+				}
+			}
+			Expect(labels).To(ContainElement(Equal(map[string]string{"handler": "metrics-test", "result": "success"})))
+		})
+	})
+})
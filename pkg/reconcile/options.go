@@ -0,0 +1,63 @@
+package reconcile
+
+import (
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type options struct {
+	tracerProvider trace.TracerProvider
+	tombstones     *Tombstones
+	triggers       *Triggers
+	statusUpdates  bool
+	cluster        string
+}
+
+// Option configures a Reconciler created with WithClient
+type Option func(*options)
+
+// WithTracerProvider sets the TracerProvider used to create a span for every reconcile and handler run.
+// By default the global TracerProvider of OpenTelemetry is used.
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(opts *options) {
+		opts.tracerProvider = tp
+	}
+}
+
+// WithTombstones passes objects kept by t to the delete handlers when they are gone by the time they are reconciled.
+// The predicate of the controller has to be wrapped with t.Track.
+func WithTombstones(t *Tombstones) Option {
+	return func(opts *options) {
+		opts.tombstones = t
+	}
+}
+
+// WithTriggers passes the triggers kept by t to the trigger handlers when their object is reconciled
+func WithTriggers(t *Triggers) Option {
+	return func(opts *options) {
+		opts.triggers = t
+	}
+}
+
+// WithStatusUpdates writes the status of an object after the create or update handlers have run, if they changed it.
+// Once all handlers succeeded, the observedGeneration of the status is set to the generation of the object.
+func WithStatusUpdates() Option {
+	return func(opts *options) {
+		opts.statusUpdates = true
+	}
+}
+
+// WithCluster passes the identifier of the cluster the client belongs to to the handlers, see ClusterFromContext
+func WithCluster(cluster string) Option {
+	return func(opts *options) {
+		opts.cluster = cluster
+	}
+}
+
+func newOptions(opts []Option) options {
+	o := options{tracerProvider: otel.GetTracerProvider()}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
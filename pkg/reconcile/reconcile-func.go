@@ -2,16 +2,30 @@ package reconcile
 
 import (
 	"context"
+	"errors"
 	lot_client "github.com/SchweizerischeBundesbahnen/lot/pkg/lot-client"
+	"github.com/go-logr/logr"
+	"go.opentelemetry.io/otel/trace"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/uuid"
 	"reflect"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sync/atomic"
+	"time"
 )
 
+// requeuer is implemented by errors which request a delayed reconcile instead of failing it,
+// such as the lot_client.NotReadyError
+type requeuer interface {
+	RequeueAfter() time.Duration
+}
+
 // Reconciler is nests the reconciler.Reconciler interface of the controller-runtime library. It is used in order to hide
 // the underlaying library within the operator package
 type Reconciler interface {
@@ -20,8 +34,21 @@ type Reconciler interface {
 
 // WithClient is a function that returns a Reconciler with an opinionated reconcile method which can pass to the event
 // handler functions not only its context but also a client.Client and the runtime.Scheme of the Operator's manager.Manager
-func WithClient(cl lot_client.Client, obj client.Object, scheme *runtime.Scheme, fn *HandlerFuncs) Reconciler {
-	return reconcile.Func(func(ctx context.Context, request reconcile.Request) (reconcile.Result, error) {
+// Every reconcile and handler run is traced with a span, see WithTracerProvider.
+// Objects which are terminating are passed to the delete handlers, all others to the create or update handlers.
+// Objects which are already gone are only passed to the delete handlers when kept by the Tombstones given
+// with WithTombstones. Triggers kept by the Triggers given with WithTriggers are passed to the trigger handlers
+// before the create or update handlers are run, and discarded for objects which are terminating or gone.
+// With WithStatusUpdates, the status changed by the trigger or create or update handlers is written afterwards,
+// unless one of them was skipped with SkipStatus.
+// The identifier of the cluster given with WithCluster is passed to the handlers in their context.
+// The handlers get a logger from their context with logf.FromContext, which logs the kind, namespace and name of the
+// object, the identifier of the reconcile, the name of the handler and the cluster.
+func WithClient(cl lot_client.Client, obj client.Object, scheme *runtime.Scheme, fn *HandlerFuncs, opts ...Option) Reconciler {
+	options := newOptions(opts)
+	tracer, tombstones, triggers := options.tracerProvider.Tracer(instrumentationName), options.tombstones, options.triggers
+	gvk, _ := apiutil.GVKForObject(obj, scheme)
+	r := reconcile.Func(func(ctx context.Context, request reconcile.Request) (reconcile.Result, error) {
 		var o client.Object
 		switch reflect.TypeOf(obj).String() {
 		case "*unstructured.Unstructured":
@@ -30,30 +57,134 @@ func WithClient(cl lot_client.Client, obj client.Object, scheme *runtime.Scheme,
 			o = copyTypedObject(obj)
 		}
 
-		log := logf.Log.WithName("ReconcileFunc")
+		log := reconcileLogger(ctx, request, gvk).WithName("ReconcileFunc")
+		if options.cluster != "" {
+			log = log.WithValues("cluster", options.cluster)
+			ctx = ContextWithCluster(ctx, options.cluster)
+		}
 		ctx = logf.IntoContext(ctx, log)
-		log.V(1).Info("event received for", "namespaceName", request.NamespacedName, "kind", o.GetObjectKind())
+		log.V(1).Info("event received")
 
 		err := cl.Get(ctx, request.NamespacedName, o)
+		if client.IgnoreNotFound(err) != nil {
+			return reconcile.Result{}, err
+		}
+
+		// an object which has been deleted since the last reconcile, and possibly recreated with the same
+		// name, is passed to the delete handlers in the state it had when it was deleted
+		if tombstone := tombstones.Get(request.NamespacedName); tombstone != nil {
+			if err == nil && tombstone.GetUID() == o.GetUID() {
+				tombstones.forget(tombstone)
+			} else {
+				res, err := dispatch(ctx, tracer, fn.DeleteHandlers, fn.Parallel, tombstone, cl, scheme)
+				if err != nil || !res.IsZero() {
+					return res, err
+				}
+				tombstones.forget(tombstone)
+			}
+		}
 		if err != nil {
 			log.Info("object not found", "resource", request.NamespacedName)
-			return reconcile.Result{}, client.IgnoreNotFound(err)
+			discardTriggers(ctx, triggers, request.NamespacedName)
+			return reconcile.Result{}, nil
 		}
 
-		if fn.DeleteHandler != nil {
-			if err := fn.DeleteHandler(ctx, o, cl, scheme); err != nil {
-				return reconcile.Result{}, err
-			}
+		// objects waiting for their finalizers are passed to the delete handlers only
+		if o.GetDeletionTimestamp() != nil {
+			discardTriggers(ctx, triggers, request.NamespacedName)
+			return dispatch(ctx, tracer, fn.DeleteHandlers, fn.Parallel, o, cl, scheme)
 		}
 
-		if fn.CreateOrUpdateHandler != nil {
-			if err := fn.CreateOrUpdateHandler(ctx, o, cl, scheme); err != nil {
+		// the status is compared before and after the handlers, so that it is only written if they changed it
+		var before interface{}
+		var skipped *atomic.Bool
+		if options.statusUpdates {
+			if before, err = statusOf(o); err != nil {
 				return reconcile.Result{}, err
 			}
+			ctx, skipped = contextWithSkipped(ctx)
 		}
-
-		return reconcile.Result{}, nil
+		res, err := dispatchTriggers(ctx, tracer, fn, triggers, o, cl, scheme)
+		if err == nil && res.IsZero() {
+			res, err = dispatch(ctx, tracer, fn.CreateOrUpdateHandlers, fn.Parallel, o, cl, scheme)
+		}
+		if options.statusUpdates && !skipped.Load() {
+			err = errors.Join(err, writeStatus(ctx, cl, o, before, err == nil && res.IsZero()))
+		}
+		return res, err
 	})
+	return traced(tracer, obj, scheme, r)
+}
+
+// reconcileLogger returns the logger of a reconcile with the kind of the object. The controller passes a logger with
+// the namespace and name of the object and the identifier of the reconcile in the context, which are added if the
+// reconciler is called without, e.g. in tests.
+func reconcileLogger(ctx context.Context, request reconcile.Request, gvk schema.GroupVersionKind) logr.Logger {
+	log, err := logr.FromContext(ctx)
+	if err != nil {
+		log = logf.Log.WithValues("namespace", request.Namespace, "name", request.Name, "reconcileID", uuid.NewUUID())
+	}
+	return log.WithValues("gvk", gvk.String())
+}
+
+// dispatchTriggers passes the pending triggers of obj one after the other to the trigger handlers, or to the scheduled
+// handler they are meant for. If the handlers fail or request a requeue, the trigger and the ones following it are
+// kept for the next reconcile.
+func dispatchTriggers(ctx context.Context, tracer trace.Tracer, fn *HandlerFuncs, triggers *Triggers, obj client.Object, cl lot_client.Client, scheme *runtime.Scheme) (reconcile.Result, error) {
+	key := client.ObjectKeyFromObject(obj)
+	pending := triggers.take(key)
+	for i, t := range pending {
+		handlers := fn.TriggerHandlers
+		if t.Handler != "" {
+			handlers = scheduledHandler(fn.ScheduledHandlers, t.Handler)
+		}
+		res, err := dispatch(ContextWithTrigger(ctx, t), tracer, handlers, fn.Parallel, obj, cl, scheme)
+		if err != nil || !res.IsZero() {
+			triggers.restore(key, pending[i:])
+			return res, err
+		}
+	}
+	return reconcile.Result{}, nil
+}
+
+// scheduledHandler returns the scheduled handler with the given name, or no handler if there is none
+func scheduledHandler(handlers []NamedHandler, name string) []NamedHandler {
+	for _, h := range handlers {
+		if h.Name == name {
+			return []NamedHandler{h}
+		}
+	}
+	return nil
+}
+
+// discardTriggers drops the pending triggers of an object which is terminating or gone
+func discardTriggers(ctx context.Context, triggers *Triggers, key types.NamespacedName) {
+	if discarded := triggers.take(key); len(discarded) > 0 {
+		logf.FromContext(ctx).Info("discarding triggers of deleted object", "resource", key, "triggers", len(discarded))
+	}
+}
+
+// result converts the error of a handler into the result of the reconcile. Errors requesting
+// a requeue are not passed on, so that they are not logged as failure and do not increase the backoff.
+func result(ctx context.Context, err error) (reconcile.Result, error) {
+	var r requeuer
+	if errors.As(err, &r) {
+		logf.FromContext(ctx).V(1).Info("requeue requested", "reason", err.Error(), "requeueAfter", r.RequeueAfter())
+		return reconcile.Result{RequeueAfter: r.RequeueAfter()}, nil
+	}
+	return reconcile.Result{}, err
+}
+
+// earliest returns the requeue error of a and b which requests the shorter delay
+func earliest(a, b error) error {
+	var ra, rb requeuer
+	if !errors.As(a, &ra) {
+		return b
+	}
+	if errors.As(b, &rb) && rb.RequeueAfter() < ra.RequeueAfter() {
+		return b
+	}
+	return a
 }
 
 // copyTypedObject is used in order to provide an Operator for typed objects (GVK)
@@ -3,6 +3,7 @@ package reconcile
 import (
 	"context"
 	"github.com/SchweizerischeBundesbahnen/lot/pkg/lot-client"
+	"github.com/SchweizerischeBundesbahnen/lot/pkg/selector"
 	"k8s.io/apimachinery/pkg/runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 )
@@ -10,8 +11,27 @@ import (
 // Handler is a function type which performs specific logic within a reconcile.Reconciler reconcile Func.
 type Handler func(ctx context.Context, object client.Object, cl lot_client.Client, scheme *runtime.Scheme) error
 
-// HandlerFuncs is a struct which contains the only two types of supported handlers
+// NamedHandler is a Handler registered under a unique name, which is only run for objects matching its Selector.
+// A nil Selector matches all objects.
+type NamedHandler struct {
+	Name     string
+	Handler  Handler
+	Selector selector.Selector
+}
+
+// Matches returns true if the labels and annotations of obj match the Selector of the handler
+func (h NamedHandler) Matches(obj client.Object) bool {
+	return selector.MatchesObject(h.Selector, obj)
+}
+
+// HandlerFuncs is a struct which contains the handlers of all supported event types in the order they are run
 type HandlerFuncs struct {
-	CreateOrUpdateHandler Handler
-	DeleteHandler         Handler
+	CreateOrUpdateHandlers []NamedHandler
+	DeleteHandlers         []NamedHandler
+	// TriggerHandlers are run once for every Trigger of an object before its create or update handlers
+	TriggerHandlers []NamedHandler
+	// ScheduledHandlers are only run for the triggers which name them, see Trigger.Handler
+	ScheduledHandlers []NamedHandler
+	// Parallel runs the matching handlers of an event type concurrently instead of one after the other
+	Parallel bool
 }
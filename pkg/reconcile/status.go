@@ -0,0 +1,87 @@
+package reconcile
+
+import (
+	"context"
+	"reflect"
+	"sync/atomic"
+
+	lot_client "github.com/SchweizerischeBundesbahnen/lot/pkg/lot-client"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+type skippedKey struct{}
+
+// contextWithSkipped returns a copy of ctx in which SkipStatus records that a handler was skipped
+func contextWithSkipped(ctx context.Context) (context.Context, *atomic.Bool) {
+	skipped := &atomic.Bool{}
+	return context.WithValue(ctx, skippedKey{}, skipped), skipped
+}
+
+// SkipStatus records that a handler run with ctx was skipped, e.g. on a replica which is not the leader, so that
+// neither the status nor the observedGeneration of the object are written by the reconcile, see WithStatusUpdates
+func SkipStatus(ctx context.Context) {
+	if skipped, ok := ctx.Value(skippedKey{}).(*atomic.Bool); ok {
+		skipped.Store(true)
+	}
+}
+
+// statusOf returns a copy of the status of obj, or nil if it has none
+func statusOf(obj client.Object) (interface{}, error) {
+	var content map[string]interface{}
+	if u, ok := obj.(runtime.Unstructured); ok {
+		content = u.UnstructuredContent()
+	} else {
+		var err error
+		if content, err = runtime.DefaultUnstructuredConverter.ToUnstructured(obj); err != nil {
+			return nil, err
+		}
+	}
+	status, found := content["status"]
+	if !found {
+		return nil, nil
+	}
+	return runtime.DeepCopyJSONValue(status), nil
+}
+
+// setObservedGeneration sets the observedGeneration of the status of obj to its generation. Typed objects
+// need a field Status.ObservedGeneration of type int64, which is the convention of kubebuilder, while
+// unstructured objects get the field status.observedGeneration.
+func setObservedGeneration(obj client.Object) error {
+	if u, ok := obj.(*unstructured.Unstructured); ok {
+		return unstructured.SetNestedField(u.Object, u.GetGeneration(), "status", "observedGeneration")
+	}
+	v := reflect.ValueOf(obj)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		return nil
+	}
+	status := v.Elem().FieldByName("Status")
+	if status.Kind() != reflect.Struct {
+		return nil
+	}
+	field := status.FieldByName("ObservedGeneration")
+	if field.Kind() == reflect.Int64 && field.CanSet() {
+		field.SetInt(obj.GetGeneration())
+	}
+	return nil
+}
+
+// writeStatus writes the status of obj if it differs from the status before the handlers were run. Once the handlers
+// succeeded, the observedGeneration of the status is updated as well.
+func writeStatus(ctx context.Context, cl lot_client.Client, obj client.Object, before interface{}, succeeded bool) error {
+	if succeeded {
+		if err := setObservedGeneration(obj); err != nil {
+			return err
+		}
+	}
+	after, err := statusOf(obj)
+	if err != nil {
+		return err
+	}
+	if reflect.DeepEqual(before, after) {
+		return nil
+	}
+	// the object may have been deleted by a handler
+	return client.IgnoreNotFound(cl.Status().Update(ctx, obj))
+}
@@ -0,0 +1,102 @@
+package reconcile_test
+
+import (
+	"context"
+	"errors"
+
+	lot_client "github.com/SchweizerischeBundesbahnen/lot/pkg/lot-client"
+	"github.com/SchweizerischeBundesbahnen/lot/pkg/reconcile"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	crreconcile "sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+var _ = Describe("WithStatusUpdates", func() {
+	var cl lot_client.Client
+	var deployment *appsv1.Deployment
+	var request crreconcile.Request
+	BeforeEach(func() {
+		deployment = &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Namespace: "biz", Name: "baz", Generation: 3}}
+		cl = lot_client.New(fake.NewClientBuilder().WithScheme(scheme.Scheme).WithObjects(deployment).Build())
+		request = crreconcile.Request{NamespacedName: client.ObjectKeyFromObject(deployment)}
+	})
+	reconciler := func(obj client.Object, handler reconcile.Handler) reconcile.Reconciler {
+		return reconcile.WithClient(cl, obj, scheme.Scheme, &reconcile.HandlerFuncs{
+			CreateOrUpdateHandlers: []reconcile.NamedHandler{{Name: "create_or_update", Handler: handler}},
+		}, reconcile.WithStatusUpdates())
+	}
+	current := func() *appsv1.Deployment {
+		d := &appsv1.Deployment{}
+		Expect(cl.Get(context.Background(), request.NamespacedName, d)).To(Succeed())
+		return d
+	}
+
+	It("should write the status and the observed generation once the handlers succeeded", func() {
+		r := reconciler(&appsv1.Deployment{}, func(ctx context.Context, object client.Object, cl lot_client.Client, scheme *runtime.Scheme) error {
+			object.(*appsv1.Deployment).Status.Replicas = 2
+			return nil
+		})
+		_, err := r.Reconcile(context.Background(), request)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(current().Status.Replicas).To(BeEquivalentTo(2))
+		Expect(current().Status.ObservedGeneration).To(BeEquivalentTo(3))
+	})
+	It("should write the status without the observed generation if a handler fails", func() {
+		r := reconciler(&appsv1.Deployment{}, func(ctx context.Context, object client.Object, cl lot_client.Client, scheme *runtime.Scheme) error {
+			object.(*appsv1.Deployment).Status.Replicas = 2
+			return errors.New("failure")
+		})
+		_, err := r.Reconcile(context.Background(), request)
+		Expect(err).To(MatchError("failure"))
+		Expect(current().Status.Replicas).To(BeEquivalentTo(2))
+		Expect(current().Status.ObservedGeneration).To(BeZero())
+	})
+	It("should write neither the status nor the observed generation if a handler was skipped", func() {
+		r := reconcile.WithClient(cl, &appsv1.Deployment{}, scheme.Scheme, &reconcile.HandlerFuncs{
+			CreateOrUpdateHandlers: []reconcile.NamedHandler{
+				{Name: "all_replicas", Handler: func(ctx context.Context, object client.Object, cl lot_client.Client, scheme *runtime.Scheme) error {
+					object.(*appsv1.Deployment).Status.Replicas = 2
+					return nil
+				}},
+				{Name: "leader_only", Handler: func(ctx context.Context, object client.Object, cl lot_client.Client, scheme *runtime.Scheme) error {
+					reconcile.SkipStatus(ctx)
+					return nil
+				}},
+			},
+		}, reconcile.WithStatusUpdates())
+		_, err := r.Reconcile(context.Background(), request)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(current().Status.Replicas).To(BeZero())
+		Expect(current().Status.ObservedGeneration).To(BeZero())
+	})
+	It("should not write an unchanged status", func() {
+		deployment = current()
+		deployment.Status.ObservedGeneration = 3
+		Expect(cl.Status().Update(context.Background(), deployment)).To(Succeed())
+		resourceVersion := current().ResourceVersion
+
+		r := reconciler(&appsv1.Deployment{}, func(ctx context.Context, object client.Object, cl lot_client.Client, scheme *runtime.Scheme) error {
+			return nil
+		})
+		_, err := r.Reconcile(context.Background(), request)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(current().ResourceVersion).To(Equal(resourceVersion))
+	})
+	It("should set the observed generation of unstructured objects", func() {
+		obj := &unstructured.Unstructured{}
+		obj.SetGroupVersionKind(appsv1.SchemeGroupVersion.WithKind("Deployment"))
+		r := reconciler(obj, func(ctx context.Context, object client.Object, cl lot_client.Client, scheme *runtime.Scheme) error {
+			return nil
+		})
+		_, err := r.Reconcile(context.Background(), request)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(current().Status.ObservedGeneration).To(BeEquivalentTo(3))
+	})
+})
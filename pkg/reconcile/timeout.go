@@ -0,0 +1,54 @@
+package reconcile
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	lot_client "github.com/SchweizerischeBundesbahnen/lot/pkg/lot-client"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// TimeoutError is returned by handlers wrapped with WithTimeout which did not finish in time.
+// It is passed on as reconcile error, so that the object is requeued with the backoff of the work queue.
+type TimeoutError struct {
+	Timeout time.Duration
+	Err     error
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("handler timed out after %s: %v", e.Timeout, e.Err)
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// IsTimeout returns true if the error or any error it wraps is a TimeoutError
+func IsTimeout(err error) bool {
+	var timeout *TimeoutError
+	return errors.As(err, &timeout)
+}
+
+// WithTimeout returns a Handler which runs fn with a context that is cancelled after timeout.
+// Errors of fn caused by the deadline are returned as TimeoutError. The handler has to respect
+// the context, e.g. by passing it on to the client, in order to be stopped by the deadline.
+func WithTimeout(fn Handler, timeout time.Duration) Handler {
+	if fn == nil || timeout <= 0 {
+		return fn
+	}
+	return func(ctx context.Context, obj client.Object, cl lot_client.Client, scheme *runtime.Scheme) error {
+		handlerCtx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		err := fn(handlerCtx, obj, cl, scheme)
+		if errors.Is(handlerCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil {
+			if err == nil {
+				err = handlerCtx.Err()
+			}
+			return &TimeoutError{Timeout: timeout, Err: err}
+		}
+		return err
+	}
+}
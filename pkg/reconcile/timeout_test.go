@@ -0,0 +1,61 @@
+package reconcile_test
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	lot_client "github.com/SchweizerischeBundesbahnen/lot/pkg/lot-client"
+	"github.com/SchweizerischeBundesbahnen/lot/pkg/reconcile"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("WithTimeout", func() {
+	waitForDeadline := func(ctx context.Context, object client.Object, cl lot_client.Client, scheme *runtime.Scheme) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+
+	It("should cancel the handler after the timeout", func() {
+		err := reconcile.WithTimeout(waitForDeadline, 10*time.Millisecond)(context.Background(), &v1.Secret{}, nil, nil)
+		Expect(reconcile.IsTimeout(err)).To(BeTrue())
+		Expect(errors.Is(err, context.DeadlineExceeded)).To(BeTrue())
+		Expect(err.Error()).To(ContainSubstring("10ms"))
+	})
+
+	It("should report a timeout when the handler ignores the deadline error", func() {
+		fn := func(ctx context.Context, object client.Object, cl lot_client.Client, scheme *runtime.Scheme) error {
+			<-ctx.Done()
+			return nil
+		}
+		err := reconcile.WithTimeout(fn, 10*time.Millisecond)(context.Background(), &v1.Secret{}, nil, nil)
+		Expect(reconcile.IsTimeout(err)).To(BeTrue())
+	})
+
+	It("should not report a timeout when the parent context is cancelled", func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		err := reconcile.WithTimeout(waitForDeadline, time.Hour)(ctx, &v1.Secret{}, nil, nil)
+		Expect(err).To(MatchError(context.Canceled))
+		Expect(reconcile.IsTimeout(err)).To(BeFalse())
+	})
+
+	It("should pass on the result of handlers finishing in time", func() {
+		failure := errors.New("failure")
+		fn := func(ctx context.Context, object client.Object, cl lot_client.Client, scheme *runtime.Scheme) error {
+			_, ok := ctx.Deadline()
+			Expect(ok).To(BeTrue())
+			return failure
+		}
+		err := reconcile.WithTimeout(fn, time.Hour)(context.Background(), &v1.Secret{}, nil, nil)
+		Expect(err).To(Equal(failure))
+	})
+
+	It("should keep handlers without timeout", func() {
+		Expect(reconcile.WithTimeout(nil, time.Second)).To(BeNil())
+	})
+})
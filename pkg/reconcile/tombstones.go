@@ -0,0 +1,76 @@
+package reconcile
+
+import (
+	"sync"
+
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// Tombstones keeps the last known state of deleted objects until they have been reconciled, so that
+// the delete handlers can be run for objects which are already gone when the reconcile starts.
+// A nil *Tombstones keeps nothing.
+type Tombstones struct {
+	mu      sync.Mutex
+	objects map[types.NamespacedName]client.Object
+}
+
+// NewTombstones returns an empty Tombstones
+func NewTombstones() *Tombstones {
+	return &Tombstones{objects: map[types.NamespacedName]client.Object{}}
+}
+
+// Track returns a predicate deciding like p, which keeps the objects of the delete events accepted by p.
+// Objects which were terminating before are not kept, as the delete handlers have been run for them
+// while they were waiting for their finalizers.
+func (t *Tombstones) Track(p predicate.Predicate) predicate.Predicate {
+	return predicate.Funcs{
+		CreateFunc:  p.Create,
+		UpdateFunc:  p.Update,
+		GenericFunc: p.Generic,
+		DeleteFunc: func(e event.DeleteEvent) bool {
+			accepted := p.Delete(e)
+			if accepted && e.Object.GetDeletionTimestamp() == nil {
+				t.add(e.Object)
+			}
+			return accepted
+		},
+	}
+}
+
+func (t *Tombstones) add(obj client.Object) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.objects[client.ObjectKeyFromObject(obj)] = obj.DeepCopyObject().(client.Object)
+}
+
+// Get returns a copy of the tombstone of the object with the given key, or nil if there is none
+func (t *Tombstones) Get(key types.NamespacedName) client.Object {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if obj, ok := t.objects[key]; ok {
+		return obj.DeepCopyObject().(client.Object)
+	}
+	return nil
+}
+
+// forget removes the tombstone of obj, unless it has been replaced by the tombstone of another object since
+func (t *Tombstones) forget(obj client.Object) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	key := client.ObjectKeyFromObject(obj)
+	if current, ok := t.objects[key]; ok && current.GetUID() == obj.GetUID() {
+		delete(t.objects, key)
+	}
+}
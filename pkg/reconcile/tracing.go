@@ -0,0 +1,59 @@
+package reconcile
+
+import (
+	"context"
+	"errors"
+
+	lot_client "github.com/SchweizerischeBundesbahnen/lot/pkg/lot-client"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// instrumentationName is the name of the tracer creating the spans of the reconciles and handlers
+const instrumentationName = "github.com/SchweizerischeBundesbahnen/lot/pkg/reconcile"
+
+// HandlerKey is the span attribute holding the name of the handler
+const HandlerKey = attribute.Key("lot.handler")
+
+// traced wraps r so that every reconcile runs within a span
+func traced(tracer trace.Tracer, obj client.Object, scheme *runtime.Scheme, r reconcile.Reconciler) reconcile.Reconciler {
+	var gvk string
+	if g, err := apiutil.GVKForObject(obj, scheme); err == nil {
+		gvk = g.String()
+	}
+	return reconcile.Func(func(ctx context.Context, request reconcile.Request) (reconcile.Result, error) {
+		ctx, span := tracer.Start(ctx, "Reconcile", trace.WithAttributes(lot_client.GVKKey.String(gvk),
+			lot_client.NamespaceKey.String(request.Namespace), lot_client.NameKey.String(request.Name)))
+		res, err := r.Reconcile(ctx, request)
+		if res.RequeueAfter > 0 {
+			span.SetAttributes(attribute.String("lot.requeue_after", res.RequeueAfter.String()))
+		}
+		lot_client.EndSpan(span, err)
+		return res, err
+	})
+}
+
+// runTraced runs the handler within a span which is a child of the reconcile span and passes
+// the name of the handler within the context and its logger
+func runTraced(ctx context.Context, tracer trace.Tracer, h NamedHandler, obj client.Object, cl lot_client.Client, scheme *runtime.Scheme) error {
+	ctx, span := tracer.Start(ctx, "Handler "+h.Name, trace.WithAttributes(HandlerKey.String(h.Name)))
+	ctx = logf.IntoContext(ctx, logf.FromContext(ctx).WithValues("handler", h.Name))
+	err := h.Handler(ContextWithHandlerName(ctx, h.Name), obj, cl, scheme)
+	endSpan(span, err)
+	return err
+}
+
+// endSpan ends the span, recording errors requesting a requeue as attribute instead of as failure
+func endSpan(span trace.Span, err error) {
+	var r requeuer
+	if errors.As(err, &r) {
+		span.SetAttributes(attribute.String("lot.requeue_after", r.RequeueAfter().String()))
+		err = nil
+	}
+	lot_client.EndSpan(span, err)
+}
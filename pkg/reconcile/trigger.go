@@ -0,0 +1,83 @@
+package reconcile
+
+import (
+	"context"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// Trigger is an external event, e.g. from a webhook or a message queue, which requests a reconcile of the
+// object with the given Key. The Payload is passed to the trigger handlers, see TriggerFromContext.
+type Trigger struct {
+	Key     types.NamespacedName
+	Payload interface{}
+	// Handler is the name of the scheduled handler the trigger is passed to instead of the trigger handlers
+	Handler string
+}
+
+type triggerKey struct{}
+
+// ContextWithTrigger returns a copy of ctx which carries the trigger passed to the trigger handlers
+func ContextWithTrigger(ctx context.Context, t Trigger) context.Context {
+	return context.WithValue(ctx, triggerKey{}, t)
+}
+
+// TriggerFromContext returns the trigger a trigger handler is run for, and false for all other handlers
+func TriggerFromContext(ctx context.Context) (Trigger, bool) {
+	t, ok := ctx.Value(triggerKey{}).(Trigger)
+	return t, ok
+}
+
+// TriggerPayload returns the payload of the trigger a trigger handler is run for, and false if there is
+// no trigger or its payload is not of type T
+func TriggerPayload[T any](ctx context.Context) (T, bool) {
+	t, _ := TriggerFromContext(ctx)
+	payload, ok := t.Payload.(T)
+	return payload, ok
+}
+
+// Triggers keeps the triggers which have been sent, but not yet passed to the trigger handlers. As the reconcile
+// requests of an object are merged while it is waiting to be reconciled, all triggers are kept in the order they
+// have been added. A nil *Triggers keeps nothing.
+type Triggers struct {
+	mu      sync.Mutex
+	pending map[types.NamespacedName][]Trigger
+}
+
+// NewTriggers returns an empty Triggers
+func NewTriggers() *Triggers {
+	return &Triggers{pending: map[types.NamespacedName][]Trigger{}}
+}
+
+// Add keeps trigger until the object it has been sent for is reconciled
+func (t *Triggers) Add(trigger Trigger) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.pending[trigger.Key] = append(t.pending[trigger.Key], trigger)
+}
+
+// take removes and returns the pending triggers of the object with the given key
+func (t *Triggers) take(key types.NamespacedName) []Trigger {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	triggers := t.pending[key]
+	delete(t.pending, key)
+	return triggers
+}
+
+// restore keeps triggers which have not been handled again, in front of the triggers added in the meantime
+func (t *Triggers) restore(key types.NamespacedName, triggers []Trigger) {
+	if t == nil || len(triggers) == 0 {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.pending[key] = append(append([]Trigger{}, triggers...), t.pending[key]...)
+}
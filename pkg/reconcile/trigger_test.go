@@ -0,0 +1,39 @@
+package reconcile_test
+
+import (
+	"context"
+
+	"github.com/SchweizerischeBundesbahnen/lot/pkg/reconcile"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var _ = Describe("Trigger", func() {
+	type payload struct {
+		Reason string
+	}
+	key := types.NamespacedName{Namespace: "biz", Name: "baz"}
+
+	It("should pass the trigger in the context", func() {
+		ctx := reconcile.ContextWithTrigger(context.Background(), reconcile.Trigger{Key: key, Payload: payload{Reason: "foo"}})
+		t, ok := reconcile.TriggerFromContext(ctx)
+		Expect(ok).To(BeTrue())
+		Expect(t.Key).To(Equal(key))
+
+		p, ok := reconcile.TriggerPayload[payload](ctx)
+		Expect(ok).To(BeTrue())
+		Expect(p.Reason).To(Equal("foo"))
+	})
+	It("should not return payloads of another type", func() {
+		ctx := reconcile.ContextWithTrigger(context.Background(), reconcile.Trigger{Key: key, Payload: "foo"})
+		_, ok := reconcile.TriggerPayload[payload](ctx)
+		Expect(ok).To(BeFalse())
+	})
+	It("should not return a trigger for contexts without one", func() {
+		_, ok := reconcile.TriggerFromContext(context.Background())
+		Expect(ok).To(BeFalse())
+		_, ok = reconcile.TriggerPayload[string](context.Background())
+		Expect(ok).To(BeFalse())
+	})
+})
@@ -0,0 +1,74 @@
+package selector
+
+import "sync"
+
+var _ Selector = &Reloadable{}
+
+// Reloadable is a Selector whose labels and annotations can be replaced while it is in use,
+// e.g. when they are reloaded from a configuration file. Nil labels or annotations match everything.
+type Reloadable struct {
+	mu       sync.RWMutex
+	selector Selector
+	changed  chan struct{}
+}
+
+// NewReloadable returns a new Reloadable that matches labels and annotations
+func NewReloadable(labels map[string]string, annotations map[string]string) (*Reloadable, error) {
+	r := &Reloadable{}
+	if err := r.Set(labels, annotations); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Set replaces the labels and annotations matched by the Reloadable. If they are invalid,
+// the previous ones are kept.
+func (r *Reloadable) Set(labels map[string]string, annotations map[string]string) error {
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	s, err := NewSelector(labels, annotations)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.selector = s
+	if r.changed != nil {
+		close(r.changed)
+	}
+	r.changed = make(chan struct{})
+	return nil
+}
+
+// Changed returns a channel which is closed once the labels and annotations are replaced by Set, e.g. to handle
+// the objects which only match the selector after the change
+func (r *Reloadable) Changed() <-chan struct{} {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.changed
+}
+
+// Matches returns true if the given labels and annotations satisfy the current requirements
+func (r *Reloadable) Matches(labels map[string]string, annotations map[string]string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.selector.Matches(labels, annotations)
+}
+
+// MatchesLabels returns true if the given labels satisfy the current label requirements
+func (r *Reloadable) MatchesLabels(labels map[string]string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.selector.MatchesLabels(labels)
+}
+
+// MatchesAnnotations returns true if the given annotations satisfy the current annotation requirements
+func (r *Reloadable) MatchesAnnotations(annotations map[string]string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.selector.MatchesAnnotations(annotations)
+}
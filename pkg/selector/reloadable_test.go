@@ -0,0 +1,45 @@
+package selector_test
+
+import (
+	"github.com/SchweizerischeBundesbahnen/lot/pkg/selector"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Reloadable", func() {
+	It("should match everything without labels and annotations", func() {
+		r, err := selector.NewReloadable(nil, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(r.Matches(map[string]string{"foo": "bar"}, map[string]string{})).To(BeTrue())
+	})
+	It("should match the labels and annotations it has been set to", func() {
+		r, err := selector.NewReloadable(map[string]string{"foo": "bar"}, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(r.MatchesLabels(map[string]string{"foo": "bar"})).To(BeTrue())
+
+		Expect(r.Set(map[string]string{"foo": "baz"}, map[string]string{"keep": selector.KeyAbsent()})).To(Succeed())
+		Expect(r.MatchesLabels(map[string]string{"foo": "bar"})).To(BeFalse())
+		Expect(r.Matches(map[string]string{"foo": "baz"}, map[string]string{})).To(BeTrue())
+		Expect(r.MatchesAnnotations(map[string]string{"keep": "true"})).To(BeFalse())
+	})
+	It("should notify about changes", func() {
+		r, err := selector.NewReloadable(map[string]string{"foo": "bar"}, nil)
+		Expect(err).NotTo(HaveOccurred())
+		changed := r.Changed()
+		Expect(r.Set(map[string]string{"foo": "%^="}, nil)).NotTo(Succeed())
+		Expect(changed).NotTo(BeClosed())
+
+		Expect(r.Set(map[string]string{"foo": "baz"}, nil)).To(Succeed())
+		Expect(changed).To(BeClosed())
+		Expect(r.Changed()).NotTo(BeClosed())
+	})
+	It("should keep the previous labels and annotations if the new ones are invalid", func() {
+		r, err := selector.NewReloadable(map[string]string{"foo": "bar"}, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(r.Set(map[string]string{"foo": "%^="}, nil)).NotTo(Succeed())
+		Expect(r.MatchesLabels(map[string]string{"foo": "bar"})).To(BeTrue())
+
+		_, err = selector.NewReloadable(map[string]string{"%^=": "bar"}, nil)
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"strings"
 
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/validation"
 	"k8s.io/apimachinery/pkg/util/validation/field"
 )
@@ -123,6 +124,21 @@ func matches(data map[string]string, sel map[string]string) bool {
 	return true
 }
 
+// MatchesObject returns true if the labels and annotations of obj match s. A nil Selector matches all objects.
+func MatchesObject(s Selector, obj metav1.Object) bool {
+	if s == nil {
+		return true
+	}
+	labels, annotations := obj.GetLabels(), obj.GetAnnotations()
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	return s.Matches(labels, annotations)
+}
+
 // source: https://github.com/kubernetes/apimachinery/blob/v0.26.1/pkg/labels/selector.go#L906
 func validateKey(k string, path *field.Path) *field.Error {
 	if errs := validation.IsQualifiedName(k); len(errs) != 0 {
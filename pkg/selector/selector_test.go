@@ -6,6 +6,7 @@ import (
 	"github.com/SchweizerischeBundesbahnen/lot/pkg/selector"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 var _ = Describe("Selector", func() {
@@ -369,4 +370,19 @@ var _ = Describe("Selector", func() {
 			})
 		})
 	})
+
+	Describe("When matching objects", func() {
+		It("should match the labels and annotations of the object", func() {
+			sel, err := selector.NewSelector(map[string]string{"foo": "bar"}, map[string]string{"baz": selector.KeyAbsent()})
+			Expect(err).NotTo(HaveOccurred())
+			obj := &metav1.ObjectMeta{Labels: map[string]string{"foo": "bar"}}
+			Expect(selector.MatchesObject(sel, obj)).To(BeTrue())
+			obj.Annotations = map[string]string{"baz": "qux"}
+			Expect(selector.MatchesObject(sel, obj)).To(BeFalse())
+			Expect(selector.MatchesObject(sel, &metav1.ObjectMeta{})).To(BeFalse())
+		})
+		It("should match all objects without a selector", func() {
+			Expect(selector.MatchesObject(nil, &metav1.ObjectMeta{})).To(BeTrue())
+		})
+	})
 })